@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+func NewAuditCommand() cli.Command {
+	return cli.Command{
+		Name:        "audit",
+		Usage:       "Report what a rebuild would produce from a netDb, without building or serving anything",
+		Description: "Scan the netDb with the filters of the reseed command and print how many routerInfos each profile includes and why the others are left out, to preview filter changes",
+		Action:      auditAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to the JSON config file of the reseed command, for its profiles and netDb options",
+			},
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "Your su3 signing ID, for the estimated su3 size",
+			},
+			cli.StringFlag{
+				Name:  "netdb",
+				Usage: "Path to NetDB directory containing routerInfos (comma separated to merge several)",
+			},
+			cli.StringFlag{
+				Name:  "netdbDb",
+				Usage: "Path to a bbolt database with routerInfos, instead of --netdb",
+			},
+			cli.IntFlag{
+				Name:  "numRi",
+				Value: 77,
+				Usage: "Number of routerInfos to include in each su3 file",
+			},
+			cli.IntFlag{
+				Name:  "numSu3",
+				Value: 0,
+				Usage: "Number of su3 files to build (0 = automatic based on size of netdb)",
+			},
+			cli.StringFlag{
+				Name:  "compression",
+				Value: reseed.COMPRESSION_DEFAULT,
+				Usage: "Compression of the routerInfos in the su3 files: 'deflate', 'best' or a deflate level from 0 to 9",
+			},
+			cli.BoolFlag{
+				Name:  "includeManifest",
+				Usage: "Add an info.json listing the router hashes to each su3 zip",
+			},
+			cli.BoolFlag{
+				Name:  "verifyRouterInfos",
+				Usage: "Check the signature of every routerInfo and skip invalid ones",
+			},
+			cli.BoolFlag{
+				Name:  "json",
+				Usage: "Print the report as JSON",
+			},
+		},
+	}
+}
+
+func auditAction(c *cli.Context) {
+	var profiles []reseed.Profile
+	if configFile := c.String("config"); configFile != "" {
+		config, err := readReseedConfig(configFile)
+		if nil != err {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		// the config is written for the reseed command, skip what audit doesn't use
+		known := make(map[string]bool)
+		for _, name := range c.FlagNames() {
+			known[name] = true
+		}
+		for name := range config.Flags {
+			if !known[name] {
+				delete(config.Flags, name)
+			}
+		}
+		if err := config.apply(c); nil != err {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		profiles = config.Profiles
+	}
+
+	netdbDir, netdbDb := c.String("netdb"), c.String("netdbDb")
+	if (netdbDir == "") == (netdbDb == "") {
+		fmt.Println("Either --netdb or --netdbDb is required")
+		os.Exit(1)
+	}
+
+	reseeder := reseed.NewReseeder(newNetDb(netdbDir, netdbDb))
+	reseeder.SignerId = []byte(c.String("signer"))
+	reseeder.NumRi = c.Int("numRi")
+	reseeder.NumSu3 = c.Int("numSu3")
+	reseeder.VerifyRouterInfos = c.Bool("verifyRouterInfos")
+	reseeder.IncludeManifest = c.Bool("includeManifest")
+	reseeder.Profiles = profiles
+	if err := reseeder.SetCompression(c.String("compression")); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	report, err := reseeder.Audit()
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if c.Bool("json") {
+		data, _ := json.MarshalIndent(report, "", "\t")
+		fmt.Println(string(data))
+		return
+	}
+
+	fmt.Printf("Total routerInfos:\t%d\n", report.Total)
+	if c.Bool("verifyRouterInfos") {
+		fmt.Printf("Invalid signature:\t%d\n", report.InvalidSignature)
+	}
+	fmt.Printf("Unused (25%% held back):\t%d\n\n", report.Unused)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "PROFILE\tINCLUDED\tBY AGE\tBY REACHABILITY\tBY FLOODFILL\tSU3 FILES\tSU3 SIZE\tTOTAL SIZE")
+	for _, pa := range report.Profiles {
+		if pa.Error != "" {
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", pa.Name, pa.Included, pa.ExcludedByAge, pa.ExcludedByReachability, pa.ExcludedByFloodfill, pa.Error)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t~%d\t~%d\n", pa.Name, pa.Included, pa.ExcludedByAge, pa.ExcludedByReachability, pa.ExcludedByFloodfill, pa.NumSu3, pa.EstimatedSu3Bytes, pa.NumSu3*pa.EstimatedSu3Bytes)
+	}
+	w.Flush()
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"log"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+// watchBundleAge logs and notifies the webhook once the served su3 files get
+// older than maxAge, and again when a rebuild brings them back
+func watchBundleAge(reseeder reseed.Reseeder, maxAge time.Duration, webhook *reseed.Webhook) {
+	stale := false
+	for _ = range time.Tick(maxAge / 4) {
+		built := reseeder.Stats().LastRebuild
+		if built.IsZero() {
+			continue
+		}
+
+		age := time.Since(built)
+		if age <= maxAge {
+			if stale {
+				log.Printf("The su3 files are up to date again, built %s ago\n", age.Round(time.Second))
+			}
+			stale = false
+			continue
+		}
+		if stale {
+			continue
+		}
+
+		stale = true
+		log.Printf("WARNING: the su3 files were built %s ago, more than --maxBundleAge %s. Are rebuilds failing?\n", age.Round(time.Second), maxAge)
+		if nil != webhook {
+			webhook.Notify(reseed.EVENT_BUNDLE_STALE, map[string]interface{}{
+				"built":  built,
+				"maxAge": maxAge.String(),
+			})
+		}
+	}
+}
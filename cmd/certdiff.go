@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+)
+
+func NewCertDiffCommand() cli.Command {
+	return cli.Command{
+		Name:        "certdiff",
+		Usage:       "Compare two certificates, ex. before and after a key rotation",
+		Description: "certdiff old.crt new.crt\n\n   Print the subject, validity, key, fingerprint and SANs of both certificates and mark the differences",
+		Action:      certDiffAction,
+	}
+}
+
+func certDiffAction(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		fmt.Println("Usage: certdiff <old.crt> <new.crt>")
+		os.Exit(1)
+	}
+
+	old, err := loadCertificate(c.Args().Get(0))
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	cert, err := loadCertificate(c.Args().Get(1))
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	diffs := 0
+	field := func(name, a, b string) {
+		if a == b {
+			fmt.Printf("  %-20s %s\n", name+":", a)
+			return
+		}
+		diffs++
+		fmt.Printf("! %-20s %s\n", name+":", a)
+		fmt.Printf("  %-20s %s\n", "", b)
+	}
+
+	field("Subject", old.Subject.String(), cert.Subject.String())
+	field("Issuer", old.Issuer.String(), cert.Issuer.String())
+	field("Serial", old.SerialNumber.String(), cert.SerialNumber.String())
+	field("Not before", old.NotBefore.UTC().Format(time.RFC3339), cert.NotBefore.UTC().Format(time.RFC3339))
+	field("Not after", old.NotAfter.UTC().Format(time.RFC3339), cert.NotAfter.UTC().Format(time.RFC3339))
+	field("Key", keyDescription(old.PublicKey), keyDescription(cert.PublicKey))
+	field("Signature algorithm", old.SignatureAlgorithm.String(), cert.SignatureAlgorithm.String())
+	field("Fingerprint", certFingerprint(old), certFingerprint(cert))
+	field("SANs", certSANs(old), certSANs(cert))
+	fmt.Printf("\n%d difference(s)\n", diffs)
+
+	// what usually goes wrong in a rotation
+	if bytes.Equal(old.RawSubjectPublicKeyInfo, cert.RawSubjectPublicKeyInfo) {
+		fmt.Println("Note: both certificates have the same key, it was not rotated")
+	}
+	if cert.NotAfter.Before(old.NotAfter) {
+		fmt.Println("Note: the new certificate expires before the old one")
+	}
+	if cert.NotAfter.Before(time.Now()) {
+		fmt.Println("Note: the new certificate is expired")
+	}
+}
+
+// keyDescription returns the type and size of a public key, ex. "RSA 4096"
+func keyDescription(pub crypto.PublicKey) string {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", pub.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + pub.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+
+	return fmt.Sprintf("%T", pub)
+}
+
+// certSANs lists the subject alternative names of a certificate
+func certSANs(cert *x509.Certificate) string {
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	sans = append(sans, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		sans = append(sans, u.String())
+	}
+	if len(sans) == 0 {
+		return "-"
+	}
+
+	return strings.Join(sans, ", ")
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/x509"
+	"log"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+type expiringCert struct {
+	name     string
+	certFile string
+	// issues a new self-signed certificate in place
+	renew func() error
+}
+
+// watchCertExpiry checks the certificates now and every interval afterwards.
+// Certificates expiring within window are logged and, if autoRenew is set and
+// they are self-signed, renewed.
+func watchCertExpiry(certs []expiringCert, window, interval time.Duration, autoRenew bool, webhook *reseed.Webhook) {
+	check := func() {
+		for _, ec := range certs {
+			checkCertExpiry(ec, window, autoRenew, webhook)
+		}
+	}
+
+	check()
+	go func() {
+		for _ = range time.Tick(interval) {
+			check()
+		}
+	}()
+}
+
+func checkCertExpiry(ec expiringCert, window time.Duration, autoRenew bool, webhook *reseed.Webhook) {
+	cert, err := loadCertificate(ec.certFile)
+	if nil != err {
+		log.Printf("Unable to check the expiry of the %s certificate: %s\n", ec.name, err)
+		return
+	}
+
+	left := time.Until(cert.NotAfter)
+	if left > window {
+		return
+	}
+
+	log.Printf("WARNING: %s certificate '%s' expires on %s (in %s)\n", ec.name, ec.certFile, cert.NotAfter.Format(time.RFC3339), left.Round(time.Minute))
+	if nil != webhook {
+		webhook.Notify(reseed.EVENT_CERT_EXPIRY, map[string]interface{}{
+			"cert":     ec.name,
+			"file":     ec.certFile,
+			"notAfter": cert.NotAfter,
+		})
+	}
+
+	if !autoRenew {
+		return
+	}
+	if !isSelfSigned(cert) {
+		log.Printf("Not renewing the %s certificate, it was not self-signed\n", ec.name)
+		return
+	}
+
+	if err := ec.renew(); nil != err {
+		log.Printf("Unable to renew the %s certificate: %s\n", ec.name, err)
+		return
+	}
+	log.Printf("Renewed the %s certificate '%s'\n", ec.name, ec.certFile)
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && nil == cert.CheckSignatureFrom(cert)
+}
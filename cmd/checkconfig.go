@@ -0,0 +1,389 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewCheckConfigCommand() cli.Command {
+	return cli.Command{
+		Name:        "check-config",
+		Usage:       "Validate a reseed config file without starting the server: check-config <file>",
+		Description: "Load the config file and the flags of the reseed command, check that the paths exist, the keys load and match their certificates, the listen addresses parse and the filters are sane, and list every problem found. No socket is bound and no bundle is built.",
+		Action:      checkConfigAction,
+		Flags:       NewReseedCommand().Flags,
+	}
+}
+
+func checkConfigAction(c *cli.Context) {
+	configFile := c.Args().First()
+	if configFile == "" {
+		configFile = c.String("config")
+	}
+	if configFile == "" {
+		fmt.Println("A config file is required")
+		os.Exit(1)
+	}
+
+	config, err := readReseedConfig(configFile)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := config.apply(c); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	problems := checkReseedConfig(c)
+	if len(problems) > 0 {
+		fmt.Printf("%s has %d problem(s):\n", configFile, len(problems))
+		for _, problem := range problems {
+			fmt.Println("  -", problem)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s is valid, %d profile(s)\n", configFile, len(config.Profiles))
+}
+
+// checkReseedConfig runs the checks of the reseed command on its flags and
+// returns every problem instead of stopping at the first one. Nothing is
+// created, prompted for or bound.
+func checkReseedConfig(c *cli.Context) []string {
+	var problems []string
+	fail := func(format string, a ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, a...))
+	}
+
+	bundleCacheRole := c.String("bundleCacheRole")
+	if bundleCacheRole != "builder" && bundleCacheRole != "server" {
+		fail("--bundleCacheRole must be 'builder' or 'server'")
+	}
+	following := c.String("bundleCache") != "" && bundleCacheRole == "server"
+	if following && c.Duration("bundleCachePoll") <= 0 {
+		fail("--bundleCachePoll must be positive")
+	}
+	if c.Bool("writeChecksum") && (c.String("bundleCache") == "" || following) {
+		fail("--writeChecksum requires --bundleCache with --bundleCacheRole=builder")
+	}
+	if _, err := newCertOptions(c); nil != err {
+		fail("%s", err)
+	}
+	if c.Duration("promptTimeout") < 0 {
+		fail("--promptTimeout can't be negative")
+	}
+
+	// netDb sources
+	netdbDir, netdbDb := c.String("netdb"), c.String("netdbDb")
+	switch {
+	case netdbDir == "" && netdbDb == "" && !following:
+		fail("--netdb or --netdbDb is required")
+	case netdbDir != "" && netdbDb != "":
+		fail("--netdb and --netdbDb can't be used together")
+	case netdbDir != "":
+		for _, dir := range strings.Split(netdbDir, ",") {
+			checkDir(fail, "--netdb", dir)
+		}
+	case netdbDb != "":
+		checkFile(fail, "--netdbDb", netdbDb)
+	}
+	for _, spec := range c.StringSlice("netdbFallback") {
+		switch {
+		case strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://"):
+			for _, u := range strings.Split(spec, ",") {
+				if _, err := url.Parse(u); nil != err {
+					fail("--netdbFallback: %s", err)
+				}
+			}
+			if certificates := c.String("fallbackCertificates"); certificates != "" {
+				checkDir(fail, "--fallbackCertificates", certificates)
+			}
+			if fallbackProxy := c.String("fallbackProxy"); fallbackProxy != "" {
+				if _, err := reseed.NewProxyTransport(fallbackProxy); nil != err {
+					fail("--fallbackProxy: %s", err)
+				}
+			}
+		case strings.HasPrefix(spec, BOLT_PREFIX):
+			checkFile(fail, "--netdbFallback", strings.TrimPrefix(spec, BOLT_PREFIX))
+		default:
+			checkDir(fail, "--netdbFallback", spec)
+		}
+	}
+	if c.Bool("embeddedFallback") {
+		if _, err := reseed.NewEmbeddedNetDb(); nil != err {
+			fail("--embeddedFallback: %s", err)
+		}
+	}
+	if c.Int("numRi") <= 0 {
+		fail("--numRi must be positive")
+	}
+	if c.Int("numSu3") < 0 {
+		fail("--numSu3 can't be negative")
+	}
+
+	// the signing key and its certificate
+	signerId := c.String("signer")
+	if signerId == "" {
+		fail("--signer is required")
+	} else if err := su3.CheckSignerId(signerId); nil != err {
+		fail("%s", err)
+	} else {
+		signerKey := c.String("key")
+		if signerKey == "" {
+			signerKey = signerFile(signerId) + ".pem"
+		}
+		if privKey, err := loadPrivateKey(signerKey); nil != err {
+			fail("--key: %s", err)
+		} else {
+			cert, err := loadCertificate(signerKey)
+			if nil != err {
+				cert, err = loadCertificate(signerFile(signerId) + ".crt")
+			}
+			if nil != err {
+				fail("No signing certificate for %s: %s", signerKey, err)
+			} else if err := checkCertKey(cert, &privKey.PublicKey); nil != err {
+				fail("Signing certificate: %s", err)
+			} else if expectedSigner := c.String("expectedSigner"); expectedSigner != "" {
+				if err := checkExpectedSigner(cert, signerId, expectedSigner); nil != err {
+					fail("%s", err)
+				}
+			}
+		}
+	}
+
+	// the TLS certificate
+	var tlsCert string
+	tlsHost := c.String("tlsHost")
+	if tlsBundle := c.String("tlsBundle"); tlsBundle != "" {
+		if _, err := reseed.LoadTLSBundle(tlsBundle); nil != err {
+			fail("--tlsBundle: %s", err)
+		}
+		tlsCert = tlsBundle
+	} else if tlsHost != "" {
+		if _, err := tlsHosts(tlsHost); nil != err {
+			fail("--tlsHost: %s", err)
+		}
+		tlsKey := c.String("tlsKey")
+		if tlsKey == "" {
+			tlsKey = tlsFile(tlsHost) + ".pem"
+		}
+		tlsCert = c.String("tlsCert")
+		if tlsCert == "" {
+			tlsCert = tlsFile(tlsHost) + ".crt"
+		}
+		if _, err := tls.LoadX509KeyPair(tlsCert, tlsKey); nil != err {
+			fail("TLS certificate %s and key %s: %s", tlsCert, tlsKey, err)
+		}
+	}
+	if caBundle := c.String("tlsCaBundle"); caBundle != "" {
+		if _, err := loadCertPool(caBundle); nil != err {
+			fail("--tlsCaBundle: %s", err)
+		}
+	}
+
+	// rebuilds
+	if _, err := time.ParseDuration(c.String("interval")); nil != err {
+		fail("'%s' is not a valid time interval", c.String("interval"))
+	}
+	if _, err := reseed.CompressionLevel(c.String("compression")); nil != err {
+		fail("%s", err)
+	}
+	if onOversize := c.String("onOversize"); onOversize != reseed.OVERSIZE_TRIM && onOversize != reseed.OVERSIZE_FAIL {
+		fail("--onOversize must be '%s' or '%s'", reseed.OVERSIZE_TRIM, reseed.OVERSIZE_FAIL)
+	}
+	if tempDir := c.String("tempDir"); tempDir != "" {
+		// created on startup if it doesn't exist
+		if info, err := os.Stat(tempDir); nil == err && !info.IsDir() {
+			fail("--tempDir: %s is not a directory", tempDir)
+		}
+	}
+	if c.Bool("refuseStaleBundle") && c.Duration("maxBundleAge") <= 0 {
+		fail("--refuseStaleBundle requires --maxBundleAge")
+	}
+
+	// listeners
+	publicUrl := c.String("publicUrl")
+	if publicUrl == "" {
+		publicUrl = defaultPublicUrl(tlsHost, tlsCert != "", c.String("ip"), c.String("port"), c.String("prefix"))
+	}
+	if _, err := reseed.ParsePublicURL(publicUrl); nil != err {
+		fail("--publicUrl: %s", err)
+	}
+	if names := c.StringSlice("tlsAllowedSni"); len(names) > 0 {
+		if err := checkAllowedSni(names, publicUrl); nil != err {
+			fail("--tlsAllowedSni: %s", err)
+		}
+	}
+	if err := checkListenAddr(net.JoinHostPort(c.String("ip"), c.String("port"))); nil != err {
+		fail("--ip/--port: %s", err)
+	}
+	if listenHttp := c.String("listenHttp"); listenHttp != "" {
+		if err := checkListenAddr(listenHttp); nil != err {
+			fail("--listenHttp: %s", err)
+		}
+	}
+	if socketMode, err := strconv.ParseUint(c.String("listenHttpMode"), 8, 32); nil != err || socketMode > 0777 {
+		fail("--listenHttpMode must be octal permissions like 0660, not '%s'", c.String("listenHttpMode"))
+	}
+	if sample := c.Float64("accessLogSample"); sample < 0 || sample > 1 {
+		fail("--accessLogSample must be between 0.0 and 1.0")
+	}
+	if c.Int("perConnBandwidth") < 0 {
+		fail("--perConnBandwidth can't be negative")
+	}
+	if c.Int("rebuildCpuLimit") < 1 || c.Duration("rebuildPause") < 0 {
+		fail("--rebuildCpuLimit must be at least 1 and --rebuildPause can't be negative")
+	}
+	if c.Bool("acceptRouterInfos") {
+		if following || c.Bool("verbatim") {
+			fail("--acceptRouterInfos requires building the su3 files, not following a --bundleCache or --verbatim")
+		}
+		if c.String("routerInfoSubmitToken") == "" {
+			fail("--acceptRouterInfos requires --routerInfoSubmitToken")
+		}
+		if c.String("routerInfoSubmitToken") == c.String("adminAuthToken") {
+			fail("--routerInfoSubmitToken must not be the admin token")
+		}
+		if c.Int("routerInfoSubmitLimit") <= 0 {
+			fail("--routerInfoSubmitLimit must be positive")
+		}
+	}
+	if err := setNotFound(&reseed.Server{}, c); nil != err {
+		fail("%s", err)
+	}
+	adminListen := c.String("adminListen")
+	pprofServed := adminListen != ""
+	if adminListen != "" {
+		if err := checkListenAddr(adminListen); nil != err {
+			fail("--adminListen: %s", err)
+		}
+	}
+	if listeners, err := parseListeners(c.StringSlice("listen")); nil != err {
+		fail("--listen: %s", err)
+	} else {
+		for _, l := range listeners {
+			if err := checkListenAddr(l.Addr); nil != err {
+				fail("--listen %s: %s", l.Addr, err)
+			}
+			if l.CertFile != "" && l.CertFile != l.KeyFile {
+				if _, err := tls.LoadX509KeyPair(l.CertFile, l.KeyFile); nil != err {
+					fail("--listen %s: %s", l.Addr, err)
+				}
+			} else if l.CertFile != "" {
+				if _, err := reseed.LoadTLSBundle(l.CertFile); nil != err {
+					fail("--listen %s: %s", l.Addr, err)
+				}
+			}
+			pprofServed = pprofServed || l.Handlers == reseed.HANDLERS_ADMIN
+		}
+	}
+	if c.Bool("pprof") && !pprofServed {
+		fail("--pprof requires --adminListen or an admin --listen")
+	}
+
+	// clients and operators
+	if c.Bool("requireClientCert") {
+		if tlsCert == "" {
+			fail("--requireClientCert requires TLS")
+		}
+		if c.String("listenHttp") != "" {
+			fail("--requireClientCert can't be used with --listenHttp")
+		}
+		if c.Bool("selfCheck") {
+			fail("--requireClientCert can't be used with --selfCheck")
+		}
+		if _, err := loadCertPool(c.String("clientCa")); nil != err {
+			fail("--clientCa: %s", err)
+		}
+	}
+	if basicAuth := c.String("adminBasicAuth"); basicAuth != "" {
+		if _, _, err := reseed.ParseBasicAuth(basicAuth); nil != err {
+			fail("--adminBasicAuth: %s", err)
+		}
+	}
+	adminCert, adminKey := c.String("adminTlsCert"), c.String("adminTlsKey")
+	if (adminCert == "") != (adminKey == "") {
+		fail("--adminTlsCert and --adminTlsKey must be used together")
+	} else if adminCert != "" {
+		if adminListen == "" {
+			fail("--adminTlsCert requires --adminListen")
+		}
+		if _, err := tls.LoadX509KeyPair(adminCert, adminKey); nil != err {
+			fail("--adminTlsCert: %s", err)
+		}
+	}
+	if c.Int("banThreshold") > 0 {
+		if c.Int("banMaxEntries") <= 0 || c.Duration("banTime") <= 0 || c.Duration("banMaxTime") < c.Duration("banTime") {
+			fail("--banMaxEntries and --banTime must be positive and --banMaxTime at least --banTime")
+		}
+	}
+	if blacklist := c.String("blacklist"); blacklist != "" {
+		checkFile(fail, "--blacklist", blacklist)
+	}
+	if crlPath := c.String("crlPath"); crlPath != "" {
+		if !strings.HasPrefix(crlPath, "/") {
+			fail("--crlPath must start with /")
+		}
+		revocations := c.String("revocations")
+		if revocations == "" {
+			revocations = signerFile(signerId) + ".revoked"
+		}
+		// created on the first revocation
+		if _, err := (&reseed.RevocationStore{Path: revocations}).Revoked(); nil != err {
+			fail("--revocations: %s", err)
+		}
+	}
+
+	return problems
+}
+
+// checkListenAddr parses a listen address without binding it
+func checkListenAddr(addr string) error {
+	if strings.HasPrefix(addr, "unix:") {
+		dir := filepath.Dir(strings.TrimPrefix(addr, "unix:"))
+		if info, err := os.Stat(dir); nil != err {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", dir)
+		}
+		return nil
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if nil != err {
+		return err
+	}
+	if _, err := net.LookupPort("tcp", port); nil != err {
+		return err
+	}
+
+	return nil
+}
+
+func checkDir(fail func(string, ...interface{}), flag, path string) {
+	if info, err := os.Stat(path); nil != err {
+		fail("%s: %s", flag, err)
+	} else if !info.IsDir() {
+		fail("%s: %s is not a directory", flag, path)
+	}
+}
+
+func checkFile(fail func(string, ...interface{}), flag, path string) {
+	if info, err := os.Stat(path); nil != err {
+		fail("%s: %s", flag, err)
+	} else if info.IsDir() {
+		fail("%s: %s is a directory", flag, path)
+	}
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+// reseedConfig is read from the --config file. Top level keys are the names
+// of reseed flags, flags given on the command line take precedence.
+//
+//	{
+//		"netdb": "/var/lib/i2p/netDb",
+//		"numRi": 77,
+//		"profiles": [
+//			{"name": "floodfill", "path": "/floodfill.su3", "floodfillOnly": true, "maxAge": "24h"}
+//		],
+//		"headers": {"Strict-Transport-Security": "max-age=31536000", "X-Frame-Options": ""}
+//	}
+type reseedConfig struct {
+	Flags    map[string]interface{}
+	Profiles []reseed.Profile
+	// response headers, an empty value removes a default header
+	Headers map[string]string
+}
+
+type profileConfig struct {
+	Name          string `json:"name"`
+	Path          string `json:"path"`
+	NumRi         int    `json:"numRi"`
+	NumSu3        int    `json:"numSu3"`
+	MaxAge        string `json:"maxAge"`
+	ReachableOnly bool   `json:"reachableOnly"`
+	FloodfillOnly bool   `json:"floodfillOnly"`
+}
+
+func readReseedConfig(path string) (*reseedConfig, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	config := &reseedConfig{Flags: make(map[string]interface{})}
+	for k, v := range raw {
+		if k == "profiles" || k == "headers" {
+			continue
+		}
+		var value interface{}
+		if err := json.Unmarshal(v, &value); nil != err {
+			return nil, fmt.Errorf("%s: %s: %s", path, k, err)
+		}
+		config.Flags[k] = value
+	}
+
+	if profiles, ok := raw["profiles"]; ok {
+		var pcs []profileConfig
+		if err := json.Unmarshal(profiles, &pcs); nil != err {
+			return nil, fmt.Errorf("%s: profiles: %s", path, err)
+		}
+
+		seen := make(map[string]bool)
+		for _, pc := range pcs {
+			profile := reseed.Profile{
+				Name:          pc.Name,
+				Path:          pc.Path,
+				NumRi:         pc.NumRi,
+				NumSu3:        pc.NumSu3,
+				ReachableOnly: pc.ReachableOnly,
+				FloodfillOnly: pc.FloodfillOnly,
+			}
+			if pc.MaxAge != "" {
+				if profile.MaxAge, err = time.ParseDuration(pc.MaxAge); nil != err {
+					return nil, fmt.Errorf("%s: profile '%s': %s", path, pc.Name, err)
+				}
+			}
+			if err := profile.Validate(); nil != err {
+				return nil, fmt.Errorf("%s: %s", path, err)
+			}
+			if seen[profile.Name] {
+				return nil, fmt.Errorf("%s: duplicate profile '%s'", path, profile.Name)
+			}
+			seen[profile.Name] = true
+
+			config.Profiles = append(config.Profiles, profile)
+		}
+	}
+
+	if headers, ok := raw["headers"]; ok {
+		if err := json.Unmarshal(headers, &config.Headers); nil != err {
+			return nil, fmt.Errorf("%s: headers: %s", path, err)
+		}
+	}
+
+	return config, nil
+}
+
+// apply sets the flags from the config file that were not given on the
+// command line
+func (config *reseedConfig) apply(c *cli.Context) error {
+	known := make(map[string]bool)
+	for _, name := range c.FlagNames() {
+		known[name] = true
+	}
+
+	// sorted so errors are reported in a stable order
+	var names []string
+	for name := range config.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !known[name] || name == "config" {
+			return fmt.Errorf("Unknown config option '%s'", name)
+		}
+		if c.IsSet(name) {
+			continue
+		}
+
+		// lists set flags that may be given several times
+		values, isList := config.Flags[name].([]interface{})
+		if !isList {
+			values = []interface{}{config.Flags[name]}
+		}
+
+		for _, v := range values {
+			value, err := configValue(name, v)
+			if nil != err {
+				return err
+			}
+			if err := c.Set(name, value); nil != err {
+				return fmt.Errorf("Config option '%s': %s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// configValue returns a config value in the form of a command line flag
+func configValue(name string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		// large numbers would be printed with an exponent
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+
+	return "", fmt.Errorf("Config option '%s' must be a string, number, boolean or a list of them", name)
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/codegangsta/cli"
+)
+
+// reason codes of RFC 5280, section 5.3.1
+var crlReasons = map[int]string{
+	0:  "unspecified",
+	1:  "keyCompromise",
+	2:  "cACompromise",
+	3:  "affiliationChanged",
+	4:  "superseded",
+	5:  "cessationOfOperation",
+	6:  "certificateHold",
+	8:  "removeFromCRL",
+	9:  "privilegeWithdrawn",
+	10: "aACompromise",
+}
+
+func NewCrlInfoCommand() cli.Command {
+	return cli.Command{
+		Name:        "crlinfo",
+		Usage:       "Show the contents of a CRL file",
+		Description: "Print the issuer, validity and revoked serials of a PEM or DER encoded CRL. Exits with status 1 if the CRL is expired.",
+		Action:      crlInfoAction,
+	}
+}
+
+func crlInfoAction(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		fmt.Println("Usage: crlinfo <path>")
+		os.Exit(1)
+	}
+
+	crl, err := loadCRL(path)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Issuer:     ", crl.Issuer)
+	if nil != crl.Number {
+		fmt.Println("CRL number: ", crl.Number)
+	}
+	fmt.Println("This update:", crl.ThisUpdate.UTC().Format(time.RFC3339))
+	fmt.Println("Next update:", crl.NextUpdate.UTC().Format(time.RFC3339))
+
+	fmt.Printf("Revoked:     %d\n", len(crl.RevokedCertificateEntries))
+	for _, entry := range crl.RevokedCertificateEntries {
+		reason, ok := crlReasons[entry.ReasonCode]
+		if !ok {
+			reason = fmt.Sprintf("unknown (%d)", entry.ReasonCode)
+		}
+		fmt.Printf("\tserial %s revoked %s reason %s\n", entry.SerialNumber, entry.RevocationTime.UTC().Format(time.RFC3339), reason)
+	}
+
+	// clients checking revocation reject an expired CRL
+	if crl.NextUpdate.IsZero() || crl.NextUpdate.Before(time.Now()) {
+		fmt.Println()
+		fmt.Println("!!! WARNING: this CRL is EXPIRED, clients checking revocation will reject it !!!")
+		os.Exit(1)
+	}
+}
+
+// loadCRL reads a PEM ("X509 CRL") or DER encoded CRL
+func loadCRL(path string) (*x509.RevocationList, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	if block, _ := pem.Decode(data); nil != block {
+		if block.Type != "X509 CRL" {
+			return nil, fmt.Errorf("%s: expected an X509 CRL, found %s", path, block.Type)
+		}
+		data = block.Bytes
+	}
+
+	crl, err := x509.ParseRevocationList(data)
+	if nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return crl, nil
+}
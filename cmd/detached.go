@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewSignDetachedCommand() cli.Command {
+	return cli.Command{
+		Name:        "sign-detached",
+		Usage:       "Sign a file with your su3 signing key, writing the signature to a separate file",
+		Description: "sign-detached mirrors.txt --signer you@mail.i2p [--out mirrors.txt.sig]",
+		Action:      signDetachedAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key",
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Usage: "Path to write the signature to (default the file with .sig appended)",
+			},
+		},
+	}
+}
+
+func NewVerifyDetachedCommand() cli.Command {
+	return cli.Command{
+		Name:        "verify-detached",
+		Usage:       "Verify a file signed with sign-detached",
+		Description: "verify-detached mirrors.txt [mirrors.txt.sig]",
+		Action:      verifyDetachedAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "certificates",
+				Value: "./certificates",
+				Usage: "Directory with the certificates of the signers",
+			},
+		},
+	}
+}
+
+func signDetachedAction(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		fmt.Println("Usage: sign-detached <file>")
+		os.Exit(1)
+	}
+
+	signerId := c.String("signer")
+	if signerId == "" {
+		fmt.Println("--signer is required")
+		os.Exit(1)
+	}
+
+	signerKey := c.String("key")
+	if signerKey == "" {
+		signerKey = signerFile(signerId) + ".pem"
+	}
+	privKey, err := loadPrivateKey(signerKey)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	sig, err := su3.SignDetached(privKey, signerId, content)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	data, _ := sig.MarshalBinary()
+
+	out := c.String("out")
+	if out == "" {
+		out = path + ".sig"
+	}
+	if err := reseed.WriteFileAtomic(out, data, 0644); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "Signature saved to:", out)
+}
+
+func verifyDetachedAction(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		fmt.Println("Usage: verify-detached <file> [signature]")
+		os.Exit(1)
+	}
+	sigPath := c.Args().Get(1)
+	if sigPath == "" {
+		sigPath = path + ".sig"
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	data, err := ioutil.ReadFile(sigPath)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	sig, err := su3.ParseDetached(data)
+	if nil != err {
+		fmt.Printf("%s: %s\n", sigPath, err)
+		os.Exit(1)
+	}
+
+	ks := reseed.KeyStore{Path: c.String("certificates")}
+	cert, err := ks.ReseederCertificate(sig.SignerId)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if err := sig.Verify(content, cert.PublicKey); nil != err {
+		fmt.Printf("%s: signature (type %d) of signer '%s' is invalid: %s\n", path, sig.SignatureType, sig.SignerId, err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Signature (type %d) is valid for signer '%s'\n", sig.SignatureType, sig.SignerId)
+}
@@ -0,0 +1,16 @@
+package cmd
+
+import (
+	"errors"
+)
+
+// errors of the key and certificate handling, possibly wrapped with more
+// details
+var (
+	// there is no signing key and none was generated
+	ErrNoSigningKey = errors.New("A signing key is required")
+	// a certificate doesn't belong to the key it is used with
+	ErrCertKeyMismatch = errors.New("The certificate doesn't match the key")
+	// a flag has an invalid value
+	ErrInvalidFlag = errors.New("Invalid flag value")
+)
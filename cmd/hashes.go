@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+const (
+	// netDb sources starting with this are bbolt databases
+	BOLT_PREFIX = "bolt:"
+)
+
+func NewHashesCommand() cli.Command {
+	return cli.Command{
+		Name:        "hashes",
+		Usage:       "List the router hashes of a netDb, one per line",
+		Description: "Print the base64 hash of every routerInfo the reseed command would use, to compare netDbs with other operators",
+		Action:      hashesAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "netdb",
+				Usage: "Path to NetDB directory containing routerInfos (comma separated to merge several)",
+			},
+			cli.StringFlag{
+				Name:  "netdbDb",
+				Usage: "Path to a bbolt database with routerInfos, instead of --netdb",
+			},
+			cli.BoolFlag{
+				Name:  "sort",
+				Usage: "Sort the hashes",
+			},
+		},
+	}
+}
+
+func hashesAction(c *cli.Context) {
+	netdbDir, netdbDb := c.String("netdb"), c.String("netdbDb")
+	if (netdbDir == "") == (netdbDb == "") {
+		fmt.Println("Either --netdb or --netdbDb is required")
+		os.Exit(1)
+	}
+
+	ris, err := newNetDb(netdbDir, netdbDb).RouterInfos()
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	var hashes []string
+	for _, ri := range ris {
+		hashes = append(hashes, strings.TrimSuffix(strings.TrimPrefix(ri.Name, "routerInfo-"), ".dat"))
+	}
+	if c.Bool("sort") {
+		sort.Strings(hashes)
+	}
+
+	for _, hash := range hashes {
+		fmt.Println(hash)
+	}
+}
+
+// newNetDb returns the provider for the --netdb or --netdbDb flags
+func newNetDb(netdbDir, netdbDb string) reseed.NetDbProvider {
+	if netdbDb != "" {
+		return reseed.NewBoltNetDb(netdbDb)
+	}
+	if netdbDirs := strings.Split(netdbDir, ","); len(netdbDirs) > 1 {
+		return reseed.NewMultiNetDb(netdbDirs)
+	}
+
+	return reseed.NewLocalNetDb(netdbDir)
+}
+
+// newNetDbSource returns the provider for a --netdbFallback source: su3 URLs,
+// fetched with transport if set, bolt:/path/to/db or netDb directories
+func newNetDbSource(spec, certificates string, transport http.RoundTripper) reseed.NetDbSource {
+	switch {
+	case strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://"):
+		remote := reseed.NewRemoteNetDb(strings.Split(spec, ","), certificates)
+		if nil != transport {
+			remote.Client.Transport = transport
+		}
+		return reseed.NetDbSource{Name: spec, NetDbProvider: remote}
+	case strings.HasPrefix(spec, BOLT_PREFIX):
+		return reseed.NetDbSource{Name: spec, NetDbProvider: reseed.NewBoltNetDb(strings.TrimPrefix(spec, BOLT_PREFIX))}
+	}
+
+	return reseed.NetDbSource{Name: spec, NetDbProvider: newNetDb(spec, "")}
+}
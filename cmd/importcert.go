@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewImportCertCommand() cli.Command {
+	return cli.Command{
+		Name:        "import-cert",
+		Usage:       "Adopt a signing certificate issued by another CA for a local key",
+		Description: "import-cert <cert> <key>\n\n   Check that the certificate and the RSA key pair, take the signer ID from the CommonName (or the email address) of the certificate and save both where the reseed command looks for them: <signer>.crt and <signer>.pem in the current dir",
+		Action:      importCertAction,
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:  "force",
+				Usage: "Overwrite an existing certificate and key of the signer",
+			},
+		},
+	}
+}
+
+func importCertAction(c *cli.Context) {
+	if len(c.Args()) != 2 {
+		fmt.Println("Usage: import-cert <cert> <key>")
+		os.Exit(1)
+	}
+
+	cert, err := loadCertificate(c.Args().Get(0))
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	key, err := loadImportKey(c.Args().Get(1))
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := checkCertKey(cert, &key.PublicKey); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	// su3 files can only be signed with some key sizes
+	if _, err := su3.RSASignatureType(&key.PublicKey); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	signerId := cert.Subject.CommonName
+	if signerId == "" && len(cert.EmailAddresses) > 0 {
+		signerId = cert.EmailAddresses[0]
+	}
+	if err := su3.CheckSignerId(signerId); nil != err {
+		fmt.Println("No signer ID in the certificate subject:", err)
+		os.Exit(1)
+	}
+
+	certFile, keyFile := signerFile(signerId)+".crt", signerFile(signerId)+".pem"
+	if !c.Bool("force") {
+		for _, file := range []string{certFile, keyFile} {
+			if _, err := os.Stat(file); nil == err {
+				fmt.Printf("%s already exists, use --force to overwrite it\n", file)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// the same layout as generated keys: the key with the certificate appended
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := reseed.WriteFileAtomic(certFile, certPem, 0644); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if err := reseed.WriteFileAtomic(keyFile, append(keyPem, certPem...), 0600); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Signer ID:", signerId)
+	fmt.Println("\tSigning certificate saved to:", certFile)
+	fmt.Println("\tSigning private key saved to:", keyFile)
+}
+
+// loadImportKey reads an RSA private key in PKCS#1 or PKCS#8 form, as CAs and
+// other tools write them
+func loadImportKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
+	}
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "RSA PRIVATE KEY":
+			return x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if nil != err {
+				return nil, err
+			}
+			if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+				return rsaKey, nil
+			}
+			return nil, fmt.Errorf("The key in '%s' is a %T, su3 files are signed with RSA keys", path, key)
+		}
+	}
+
+	return nil, fmt.Errorf("No RSA PRIVATE KEY or PRIVATE KEY found in '%s'", path)
+}
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+const (
+	// the config file written by init
+	INIT_CONFIG_FILE = "reseed.json"
+)
+
+func NewInitCommand() cli.Command {
+	return cli.Command{
+		Name:        "init",
+		Usage:       "Generate the keys, certificates and config file of a new reseed server",
+		Description: "init --signer=you@mail.i2p [--tlsHost=reseed.example.org] [--outputDir=/etc/i2p-tools]\n\n   Generate the su3 signing key and certificate, the TLS key and certificate if --tlsHost is given, and a reseed.json referencing them, without prompting. Existing files are never overwritten.",
+		Action:      initAction,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "su3 signing ID to generate a key and certificate for (ex. something@mail.i2p)",
+			},
+			cli.StringFlag{
+				Name:  "tlsHost",
+				Usage: "Host to generate a self-signed TLS certificate for, leave out behind a reverse proxy",
+			},
+			cli.StringFlag{
+				Name:  "outputDir",
+				Value: ".",
+				Usage: "Directory to write the keys, certificates and " + INIT_CONFIG_FILE + " to",
+			},
+			cli.StringFlag{
+				Name:  "netdb",
+				Value: "/home/i2p/.i2p/netDb",
+				Usage: "netDb directory of the local router to put in the config file",
+			},
+		}, certFlags()...),
+	}
+}
+
+func initAction(c *cli.Context) {
+	signerId, tlsHost := c.String("signer"), c.String("tlsHost")
+	if signerId == "" {
+		fmt.Println("--signer is required")
+		os.Exit(1)
+	}
+	if err := su3.CheckSignerId(signerId); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if tlsHost != "" {
+		if _, err := tlsHosts(tlsHost); nil != err {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+	}
+	opts, err := newCertOptions(c)
+	if nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	dir, err := filepath.Abs(c.String("outputDir"))
+	if nil != err {
+		log.Fatalln(err)
+	}
+	if err := os.MkdirAll(dir, 0700); nil != err {
+		log.Fatalln(err)
+	}
+
+	files := []string{signerFile(signerId) + ".crt", signerFile(signerId) + ".pem", INIT_CONFIG_FILE}
+	if tlsHost != "" {
+		files = append(files, tlsFile(tlsHost)+".crt", tlsFile(tlsHost)+".pem")
+	}
+	for _, file := range files {
+		if _, err := os.Stat(filepath.Join(dir, file)); nil == err {
+			fmt.Printf("%s already exists, init only sets up new servers\n", filepath.Join(dir, file))
+			os.Exit(1)
+		}
+	}
+
+	// the certificates are written to the current dir
+	if err := os.Chdir(dir); nil != err {
+		log.Fatalln(err)
+	}
+	if err := createSigningCertificate(signerId, opts); nil != err {
+		log.Fatalln(err)
+	}
+	config := map[string]interface{}{
+		"signer": signerId,
+		"key":    filepath.Join(dir, signerFile(signerId)+".pem"),
+		"netdb":  c.String("netdb"),
+	}
+	if tlsHost != "" {
+		if err := createTLSCertificate(tlsHost, opts); nil != err {
+			log.Fatalln(err)
+		}
+		config["tlsHost"] = tlsHost
+		config["tlsCert"] = filepath.Join(dir, tlsFile(tlsHost)+".crt")
+		config["tlsKey"] = filepath.Join(dir, tlsFile(tlsHost)+".pem")
+	} else {
+		// behind a reverse proxy on the same host
+		config["ip"] = "127.0.0.1"
+		config["port"] = "8443"
+		config["trustProxy"] = true
+	}
+
+	configJson, err := json.MarshalIndent(config, "", "  ")
+	if nil != err {
+		log.Fatalln(err)
+	}
+	configFile := filepath.Join(dir, INIT_CONFIG_FILE)
+	if err := reseed.WriteFileAtomic(configFile, append(configJson, '\n'), 0644); nil != err {
+		log.Fatalln(err)
+	}
+	fmt.Fprintln(os.Stderr, "\tConfig saved to:", configFile)
+
+	fmt.Printf(`
+Next steps:
+  1. Check the netdb path and the other options in %s
+  2. bin/i2p-tools check-config %s
+  3. bin/i2p-tools reseed --config=%s
+  4. Send %s to the I2P developers so routers trust your su3 files
+  5. Back up %s, routers can't verify su3 files signed with a new key
+`, configFile, configFile, configFile, filepath.Join(dir, signerFile(signerId)+".crt"), filepath.Join(dir, signerFile(signerId)+".pem"))
+	if tlsHost == "" {
+		fmt.Println("  6. Serve https://<your host>/i2pseeds.su3 from a reverse proxy forwarding to 127.0.0.1:8443")
+	}
+}
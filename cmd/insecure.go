@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+)
+
+// insecureReader is a deterministic stream of bytes derived from a seed. It
+// is only meant to create reproducible keys for tests, never use it for keys
+// that protect anything.
+type insecureReader struct {
+	seed    [sha256.Size]byte
+	counter uint64
+	buf     []byte
+}
+
+func newInsecureReader(seed string) *insecureReader {
+	return &insecureReader{seed: sha256.Sum256([]byte(seed))}
+}
+
+func (r *insecureReader) Read(p []byte) (int, error) {
+	for n := 0; n < len(p); {
+		if len(r.buf) == 0 {
+			block := make([]byte, len(r.seed)+8)
+			copy(block, r.seed[:])
+			binary.BigEndian.PutUint64(block[len(r.seed):], r.counter)
+			sum := sha256.Sum256(block)
+			r.buf = sum[:]
+			r.counter++
+		}
+
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+
+	return len(p), nil
+}
+
+// insecureRSAKey generates an RSA key from r. Unlike rsa.GenerateKey, the
+// same stream always produces the same key.
+func insecureRSAKey(r io.Reader, bits int) (*rsa.PrivateKey, error) {
+	e := big.NewInt(65537)
+	one := big.NewInt(1)
+
+	for {
+		p, err := insecurePrime(r, bits/2, e)
+		if nil != err {
+			return nil, err
+		}
+		q, err := insecurePrime(r, bits-bits/2, e)
+		if nil != err {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != bits {
+			continue
+		}
+
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if nil == d {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		key.Precompute()
+		if err := key.Validate(); nil != err {
+			return nil, fmt.Errorf("generated an invalid key: %s", err)
+		}
+
+		return key, nil
+	}
+}
+
+// insecurePrime reads candidates with the top two bits set until one is a
+// prime p with gcd(e, p-1) = 1
+func insecurePrime(r io.Reader, bits int, e *big.Int) (*big.Int, error) {
+	b := make([]byte, (bits+7)/8)
+	one := big.NewInt(1)
+
+	for {
+		if _, err := io.ReadFull(r, b); nil != err {
+			return nil, err
+		}
+
+		p := new(big.Int).SetBytes(b)
+		p.Rsh(p, uint(len(b)*8-bits))
+		p.SetBit(p, bits-1, 1)
+		p.SetBit(p, bits-2, 1)
+		p.SetBit(p, 0, 1)
+
+		if !p.ProbablyPrime(20) {
+			continue
+		}
+		if new(big.Int).GCD(nil, nil, e, new(big.Int).Sub(p, one)).Cmp(one) == 0 {
+			return p, nil
+		}
+	}
+}
+
+// insecureECDSAKey derives a private key on curve from r
+func insecureECDSAKey(r io.Reader, curve elliptic.Curve) (*ecdsa.PrivateKey, error) {
+	params := curve.Params()
+	size := (params.BitSize + 7) / 8
+
+	// 64 extra bits make the bias of the reduction negligible
+	b := make([]byte, size+8)
+	if _, err := io.ReadFull(r, b); nil != err {
+		return nil, err
+	}
+
+	// d in [1, N-1]
+	one := big.NewInt(1)
+	d := new(big.Int).SetBytes(b)
+	d.Mod(d, new(big.Int).Sub(params.N, one))
+	d.Add(d, one)
+
+	key := &ecdsa.PrivateKey{D: d}
+	key.Curve = curve
+	key.X, key.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, size)))
+
+	return key, nil
+}
+
+// the seeded certificates are dated within the year after it
+var insecureCertEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// insecureCertSerial derives the serial number and notBefore date of a
+// certificate from r, so the certificates of seeded keys are reproducible
+func insecureCertSerial(r io.Reader) (*big.Int, time.Time, error) {
+	b := make([]byte, 16+2)
+	if _, err := io.ReadFull(r, b); nil != err {
+		return nil, time.Time{}, err
+	}
+	serialNumber := new(big.Int).SetBytes(b[:16])
+	days := int(binary.BigEndian.Uint16(b[16:]) % 365)
+
+	return serialNumber, insecureCertEpoch.AddDate(0, 0, days), nil
+}
+
+// insecureECDSASigner signs with a nonce derived from the key and the digest
+// instead of crypto/rand, so certificates signed by seeded TLS keys are
+// reproducible. Like the keys, it is for tests only.
+type insecureECDSASigner struct {
+	*ecdsa.PrivateKey
+}
+
+func (s insecureECDSASigner) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	params := s.Curve.Params()
+	one := big.NewInt(1)
+
+	h := sha512.New()
+	h.Write(s.D.Bytes())
+	h.Write(digest)
+	k := new(big.Int).SetBytes(h.Sum(nil))
+	k.Mod(k, new(big.Int).Sub(params.N, one))
+	k.Add(k, one)
+
+	// e is the leftmost bits of the digest, as many as N has
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - params.N.BitLen(); excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+
+	x, _ := s.Curve.ScalarBaseMult(k.Bytes())
+	r := new(big.Int).Mod(x, params.N)
+	sig := new(big.Int).Mul(r, s.D)
+	sig.Add(sig, e)
+	sig.Mul(sig, new(big.Int).ModInverse(k, params.N))
+	sig.Mod(sig, params.N)
+	if r.Sign() == 0 || sig.Sign() == 0 {
+		return nil, errors.New("insecure ECDSA signature is zero")
+	}
+
+	return asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+}
@@ -0,0 +1,160 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewKeyBenchCommand() cli.Command {
+	return cli.Command{
+		Name:        "keybench",
+		Usage:       "Measure key generation, signing and verification time of the signing key types",
+		Description: "Generate a key of each type and time its generation, the signature of a digest and its verification, to weigh the cost of rebuilds against the cost for routers",
+		Action:      keyBenchAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "keys",
+				Value: "rsa2048,rsa4096,ecdsa256,ecdsa384,ecdsa521,ed25519",
+				Usage: "Comma separated key types to measure",
+			},
+			cli.IntFlag{
+				Name:  "iterations",
+				Value: 50,
+				Usage: "Number of signatures and verifications per key type",
+			},
+		},
+	}
+}
+
+// benchKey generates a key of a type and verifies its signatures
+type benchKey struct {
+	// su3 signature type, empty if su3 files can't be signed with the key
+	sigType  string
+	generate func() (crypto.Signer, error)
+	opts     crypto.SignerOpts
+	verify   func(pub crypto.PublicKey, digest, sig []byte) bool
+	// signature size in an su3 file, if it differs from what Sign returns
+	sigSize int
+}
+
+func rsaBenchKey(bits int) benchKey {
+	return benchKey{
+		sigType:  fmt.Sprint(su3.SIGTYPE_RSA_SHA512),
+		generate: func() (crypto.Signer, error) { return rsa.GenerateKey(rand.Reader, bits) },
+		// su3 RSA signatures are over the bare digest
+		opts: crypto.Hash(0),
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			return nil == rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.Hash(0), digest, sig)
+		},
+	}
+}
+
+func ecdsaBenchKey(curve elliptic.Curve, sigType uint16) benchKey {
+	return benchKey{
+		sigType:  fmt.Sprint(sigType),
+		generate: func() (crypto.Signer, error) { return ecdsa.GenerateKey(curve, rand.Reader) },
+		opts:     crypto.SHA512,
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			return ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest, sig)
+		},
+		// r and s without the ASN.1 encoding
+		sigSize: 2 * ((curve.Params().BitSize + 7) / 8),
+	}
+}
+
+var benchKeys = map[string]benchKey{
+	"rsa2048":  rsaBenchKey(2048),
+	"rsa4096":  rsaBenchKey(4096),
+	"ecdsa256": ecdsaBenchKey(elliptic.P256(), su3.SIGTYPE_ECDSA_SHA256),
+	"ecdsa384": ecdsaBenchKey(elliptic.P384(), su3.SIGTYPE_ECDSA_SHA384),
+	"ecdsa521": ecdsaBenchKey(elliptic.P521(), su3.SIGTYPE_ECDSA_SHA512),
+	"ed25519": {
+		generate: func() (crypto.Signer, error) {
+			_, priv, err := ed25519.GenerateKey(rand.Reader)
+			return priv, err
+		},
+		opts: crypto.Hash(0),
+		verify: func(pub crypto.PublicKey, digest, sig []byte) bool {
+			return ed25519.Verify(pub.(ed25519.PublicKey), digest, sig)
+		},
+	},
+}
+
+func keyBenchAction(c *cli.Context) {
+	iterations := c.Int("iterations")
+	if iterations <= 0 {
+		fmt.Println("--iterations must be positive")
+		os.Exit(1)
+	}
+
+	names := strings.Split(c.String("keys"), ",")
+	for _, name := range names {
+		if _, ok := benchKeys[name]; !ok {
+			fmt.Printf("Unknown key type '%s'\n", name)
+			os.Exit(1)
+		}
+	}
+
+	// the digest of a bundle, what a rebuild signs for every su3 file
+	digest := sha512.Sum512([]byte("i2pseeds.su3"))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "KEY\tSU3 SIGTYPE\tKEYGEN\tSIGN\tVERIFY\tSIGNATURE BYTES")
+	for _, name := range names {
+		bk := benchKeys[name]
+		fmt.Fprintf(os.Stderr, "Measuring %s...\n", name)
+
+		start := time.Now()
+		key, err := bk.generate()
+		if nil != err {
+			log.Fatalln(err)
+		}
+		keygen := time.Since(start)
+
+		var sig []byte
+		start = time.Now()
+		for i := 0; i < iterations; i++ {
+			if sig, err = key.Sign(rand.Reader, digest[:], bk.opts); nil != err {
+				log.Fatalln(err)
+			}
+		}
+		signing := time.Since(start) / time.Duration(iterations)
+
+		start = time.Now()
+		for i := 0; i < iterations; i++ {
+			if !bk.verify(key.Public(), digest[:], sig) {
+				log.Fatalf("%s: the signature doesn't verify\n", name)
+			}
+		}
+		verifying := time.Since(start) / time.Duration(iterations)
+
+		sigSize := len(sig)
+		if bk.sigSize > 0 {
+			sigSize = bk.sigSize
+		}
+		sigType := bk.sigType
+		if sigType == "" {
+			sigType = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\n", name, sigType, us(keygen), us(signing), us(verifying), sigSize)
+	}
+	w.Flush()
+}
+
+func us(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
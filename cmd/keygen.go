@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/su3"
 )
 
 func NewKeygenCommand() cli.Command {
@@ -11,16 +12,20 @@ func NewKeygenCommand() cli.Command {
 		Name:   "keygen",
 		Usage:  "Generate keys for reseed su3 signing and TLS serving.",
 		Action: keygenAction,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			cli.StringFlag{
 				Name:  "signer",
 				Usage: "Generate a private key and certificate for the given su3 signing ID (ex. something@mail.i2p)",
 			},
 			cli.StringFlag{
 				Name:  "tlsHost",
-				Usage: "Generate a self-signed TLS certificate and private key for the given host",
+				Usage: "Generate a self-signed TLS certificate and private key for the given host (comma separated, or @file with one host per line)",
 			},
-		},
+			cli.BoolFlag{
+				Name:  "reuseKey",
+				Usage: "Issue a new certificate for the existing private key in the current dir instead of generating a new key, ex. to renew an expired certificate while pinned keys keep working",
+			},
+		}, certFlags()...),
 	}
 }
 
@@ -33,15 +38,26 @@ func keygenAction(c *cli.Context) {
 		return
 	}
 
+	opts, err := newCertOptions(c)
+	if nil != err {
+		fmt.Println(err)
+		return
+	}
+	opts.reuseKey = c.Bool("reuseKey")
+
 	if signerId != "" {
-		if err := createSigningCertificate(signerId); nil != err {
+		if err := su3.CheckSignerId(signerId); nil != err {
+			fmt.Println(err)
+			return
+		}
+		if err := createSigningCertificate(signerId, opts); nil != err {
 			fmt.Println(err)
 			return
 		}
 	}
 
 	if tlsHost != "" {
-		if err := createTLSCertificate(tlsHost); nil != err {
+		if err := createTLSCertificate(tlsHost, opts); nil != err {
 			fmt.Println(err)
 			return
 		}
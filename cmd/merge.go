@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewMergeCommand() cli.Command {
+	return cli.Command{
+		Name:        "merge",
+		Usage:       "Merge the routerInfos of several su3 files into a new su3 signed with your key",
+		Description: "merge a.su3 b.su3 [...] --out merged.su3",
+		Action:      mergeAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out",
+				Value: "merged.su3",
+				Usage: "Path to write the merged su3 file to, - for stdout",
+			},
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key",
+			},
+			cli.StringFlag{
+				Name:  "certificates",
+				Value: "./certificates",
+				Usage: "Directory with the certificates of the signers of the input files",
+			},
+		},
+	}
+}
+
+func mergeAction(c *cli.Context) {
+	if c.NArg() < 2 {
+		fmt.Fprintln(os.Stderr, "At least two su3 files are required")
+		os.Exit(1)
+	}
+
+	signerId := c.String("signer")
+	if signerId == "" {
+		fmt.Fprintln(os.Stderr, "--signer is required")
+		os.Exit(1)
+	}
+	if err := su3.CheckSignerId(signerId); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	signerKey := c.String("key")
+	if signerKey == "" {
+		signerKey = signerFile(signerId) + ".pem"
+	}
+	privKey, err := loadPrivateKey(signerKey)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// only merge what the original signers vouched for
+	ks := reseed.KeyStore{Path: c.String("certificates")}
+	var zips [][]byte
+	for _, path := range c.Args() {
+		data, err := ioutil.ReadFile(path)
+		if nil != err {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		su3File, err := su3.Parse(data)
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			os.Exit(1)
+		}
+		if su3File.ContentType != su3.CONTENT_TYPE_RESEED || su3File.FileType != su3.FILE_TYPE_ZIP {
+			fmt.Fprintf(os.Stderr, "%s: not a reseed su3 file\n", path)
+			os.Exit(1)
+		}
+
+		cert, err := ks.ReseederCertificate(su3File.SignerId)
+		if nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			os.Exit(1)
+		}
+		if err := su3File.VerifySignature(cert); nil != err {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", path, err)
+			os.Exit(1)
+		}
+
+		zips = append(zips, su3File.Content)
+	}
+
+	merged, unique, duplicates, err := reseed.MergeSeeds(zips...)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	su3File := su3.NewSu3File()
+	su3File.FileType = su3.FILE_TYPE_ZIP
+	su3File.ContentType = su3.CONTENT_TYPE_RESEED
+	su3File.SignerId = []byte(signerId)
+	su3File.Content = merged
+	if err := su3File.Sign(privKey); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := writeOutput(c.String("out"), data, 0644); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Merged %d unique routerInfos (%d duplicates) into %s\n", unique, duplicates, c.String("out"))
+}
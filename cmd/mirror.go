@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/codegangsta/cli"
+)
+
+func NewMirrorCommand() cli.Command {
+	// the reseed flags, rebuilding more often as the upstream changes
+	var flags []cli.Flag
+	for _, flag := range NewReseedCommand().Flags {
+		if f, ok := flag.(cli.StringFlag); ok && f.Name == "interval" {
+			f.Value = "1h"
+			f.Usage = "Duration between fetches of the upstream su3 files (ex. 1h, 30m)"
+			flag = f
+		}
+		flags = append(flags, flag)
+	}
+
+	return cli.Command{
+		Name:        "mirror",
+		Usage:       "Start a reseed server republishing the su3 files of an upstream reseed",
+		Description: "mirror --upstream=https://reseed.example.org/i2pseeds.su3 --signer=you@mail.i2p [--verbatim] [reseed options]\n\n   Fetch the su3 files of the upstream every --interval, verify them with the certificates in --upstreamCertificates and serve their routerInfos in su3 files signed with your key, or the upstream files unchanged with --verbatim. No local router is needed.",
+		Action:      reseedAction,
+		Flags: append(flags,
+			cli.StringSliceFlag{
+				Name:  "upstream",
+				Usage: "URL of the su3 file of the upstream reseed, may be given several times",
+			},
+			cli.StringFlag{
+				Name:  "upstreamCertificates",
+				Value: "./certificates",
+				Usage: "Directory with the certificates of the upstream signers, pin one by keeping only its certificate here",
+			},
+			cli.StringFlag{
+				Name:  "upstreamProxy",
+				Usage: "Fetch --upstream through this socks5:// or http:// proxy, ex. socks5://127.0.0.1:9050 for Tor",
+			},
+			cli.BoolFlag{
+				Name:  "verbatim",
+				Usage: "Serve the upstream su3 files unchanged instead of signing new ones, --signer is still used for the served certificate",
+			},
+		),
+	}
+}
@@ -0,0 +1,235 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+// unsignedHeader is written next to the content by build-unsigned and holds
+// the su3 header fields sign-bundle signs it with
+type unsignedHeader struct {
+	Version       string `json:"version"`
+	SignerId      string `json:"signerId"`
+	SignatureType uint16 `json:"signatureType"`
+	FileType      uint8  `json:"fileType"`
+	ContentType   uint8  `json:"contentType"`
+	ContentSHA256 string `json:"contentSha256"`
+}
+
+func NewBuildUnsignedCommand() cli.Command {
+	return cli.Command{
+		Name:        "build-unsigned",
+		Usage:       "Build the content of a reseed su3 file to sign it on another host with sign-bundle",
+		Description: "Writes the zip of routerInfos to --out and its su3 header fields to --out with .json appended",
+		Action:      buildUnsignedAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "The su3 signing ID the content will be signed with (ex. something@mail.i2p)",
+			},
+			cli.StringFlag{
+				Name:  "netdb",
+				Usage: "Path to NetDB directory containing routerInfos (comma separated to merge several)",
+			},
+			cli.StringFlag{
+				Name:  "netdbDb",
+				Usage: "Path to a bbolt database with routerInfos, instead of --netdb",
+			},
+			cli.IntFlag{
+				Name:  "numRi",
+				Value: 77,
+				Usage: "Number of routerInfos to include",
+			},
+			cli.BoolFlag{
+				Name:  "verifyRouterInfos",
+				Usage: "Check the signature of every routerInfo and skip invalid ones",
+			},
+			cli.BoolFlag{
+				Name:  "includeManifest",
+				Usage: "Add an info.json listing the router hashes to the zip",
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Value: "content.zip",
+				Usage: "Path to write the content to",
+			},
+		},
+	}
+}
+
+func NewSignBundleCommand() cli.Command {
+	return cli.Command{
+		Name:        "sign-bundle",
+		Usage:       "Sign content built by build-unsigned into a reseed su3 file",
+		Description: "sign-bundle content.zip [--key you_at_mail.i2p.pem] [--out i2pseeds.su3], reads the header fields from content.zip.json",
+		Action:      signBundleAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your su3 signing private key (default the signer of the content)",
+			},
+			cli.StringFlag{
+				Name:  "out",
+				Value: "i2pseeds.su3",
+				Usage: "Path to write the signed su3 file to, - for stdout",
+			},
+			cli.BoolFlag{
+				Name:  "writeChecksum",
+				Usage: "Also write the SHA-256 of the su3 file to <out>.sha256, for mirrors",
+			},
+		},
+	}
+}
+
+func buildUnsignedAction(c *cli.Context) {
+	signerId := c.String("signer")
+	if signerId == "" {
+		fmt.Fprintln(os.Stderr, "--signer is required")
+		os.Exit(1)
+	}
+	if err := su3.CheckSignerId(signerId); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	netdbDir, netdbDb := c.String("netdb"), c.String("netdbDb")
+	if (netdbDir == "") == (netdbDb == "") {
+		fmt.Fprintln(os.Stderr, "Either --netdb or --netdbDb is required")
+		os.Exit(1)
+	}
+
+	reseeder := reseed.NewReseeder(newNetDb(netdbDir, netdbDb))
+	reseeder.SignerId = []byte(signerId)
+	reseeder.NumRi = c.Int("numRi")
+	reseeder.VerifyRouterInfos = c.Bool("verifyRouterInfos")
+	reseeder.IncludeManifest = c.Bool("includeManifest")
+
+	content, err := reseeder.UnsignedContent()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	template := su3.NewSu3File()
+	sum := sha256.Sum256(content)
+	header := unsignedHeader{
+		Version:       string(template.Version),
+		SignerId:      signerId,
+		SignatureType: template.SignatureType,
+		FileType:      su3.FILE_TYPE_ZIP,
+		ContentType:   su3.CONTENT_TYPE_RESEED,
+		ContentSHA256: hex.EncodeToString(sum[:]),
+	}
+	headerJson, err := json.MarshalIndent(header, "", "\t")
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	out := c.String("out")
+	if err := reseed.WriteFileAtomic(out, content, 0644); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := reseed.WriteFileAtomic(out+".json", append(headerJson, '\n'), 0644); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "Unsigned content saved to:", out)
+	fmt.Fprintln(os.Stderr, "su3 header fields saved to:", out+".json")
+}
+
+func signBundleAction(c *cli.Context) {
+	path := c.Args().First()
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "Usage: sign-bundle <content.zip>")
+		os.Exit(1)
+	}
+
+	if c.Bool("writeChecksum") && c.String("out") == STDOUT {
+		fmt.Fprintln(os.Stderr, "--writeChecksum can't be used with --out=-")
+		os.Exit(1)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	headerJson, err := ioutil.ReadFile(path + ".json")
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	var header unsignedHeader
+	if err := json.Unmarshal(headerJson, &header); nil != err {
+		fmt.Fprintf(os.Stderr, "%s.json: %s\n", path, err)
+		os.Exit(1)
+	}
+
+	// the content may have been damaged on its way to this host
+	if sum := sha256.Sum256(content); hex.EncodeToString(sum[:]) != header.ContentSHA256 {
+		fmt.Fprintf(os.Stderr, "%s doesn't match the SHA-256 in %s.json\n", path, path)
+		os.Exit(1)
+	}
+
+	signerKey := c.String("key")
+	if signerKey == "" {
+		signerKey = signerFile(header.SignerId) + ".pem"
+	}
+	privKey, err := loadPrivateKey(signerKey)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	// the header was built for one key, signing with another would change it
+	sigType, err := su3.RSASignatureType(&privKey.PublicKey)
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if sigType != header.SignatureType {
+		fmt.Fprintf(os.Stderr, "%s is for signature type %d, %s.json declares %d\n", signerKey, sigType, path, header.SignatureType)
+		os.Exit(1)
+	}
+
+	su3File := su3.NewSu3File()
+	su3File.Version = []byte(header.Version)
+	su3File.SignatureType = header.SignatureType
+	su3File.FileType = header.FileType
+	su3File.ContentType = header.ContentType
+	su3File.SignerId = []byte(header.SignerId)
+	su3File.Content = content
+	if err := su3File.SignWith(privKey); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := writeOutput(c.String("out"), data, 0644); nil != err {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if c.String("out") != STDOUT {
+		fmt.Fprintln(os.Stderr, "Signed su3 saved to:", c.String("out"))
+	}
+	if c.Bool("writeChecksum") {
+		if err := reseed.WriteFileAtomic(c.String("out")+".sha256", reseed.Checksum(c.String("out"), data), 0644); nil != err {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Fprintln(os.Stderr, "Checksum saved to:", c.String("out")+".sha256")
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+// pinStore maps signer ids to the SHA-256 fingerprint of the certificate
+// their su3 files verified with the first time (trust on first use)
+type pinStore struct {
+	path string
+	pins map[string]string
+}
+
+func loadPinStore(path string) (*pinStore, error) {
+	ps := &pinStore{path: path, pins: make(map[string]string)}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return ps, nil
+	} else if nil != err {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &ps.pins); nil != err {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+
+	return ps, nil
+}
+
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// check compares cert to the pin of signerId, pinning it if there is none
+// yet. It returns true if a new pin was added.
+func (ps *pinStore) check(signerId string, cert *x509.Certificate) (bool, error) {
+	fingerprint := certFingerprint(cert)
+	pinned, found := ps.pins[signerId]
+	if found && pinned != fingerprint {
+		return false, fmt.Errorf("The certificate of signer '%s' CHANGED: pinned %s, now %s", signerId, pinned, fingerprint)
+	}
+	if found {
+		return false, nil
+	}
+
+	ps.pins[signerId] = fingerprint
+	return true, ps.save()
+}
+
+// save replaces the file atomically so an interrupted write can't lose pins
+func (ps *pinStore) save() error {
+	data, err := json.MarshalIndent(ps.pins, "", "\t")
+	if nil != err {
+		return err
+	}
+
+	return reseed.WriteFileAtomic(ps.path, append(data, '\n'), 0644)
+}
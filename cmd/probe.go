@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewProbeCommand() cli.Command {
+	return cli.Command{
+		Name:        "probe",
+		Usage:       "Download an su3 file from a reseed server like a router and report how it performed",
+		Description: "probe https://reseed.example.org/ [--count 5]\n\n   Times the TLS handshake and the transfer, verifies the su3 signature and counts the routerInfos",
+		Action:      probeAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "certificates",
+				Value: "./certificates",
+				Usage: "Directory with the certificates of the signers",
+			},
+			cli.StringFlag{
+				Name:  "tlsCa",
+				Usage: "Trust the server's TLS certificate if it is signed by (or is) one in this PEM file, for self-signed reseeds",
+			},
+			cli.IntFlag{
+				Name:  "count",
+				Value: 1,
+				Usage: "Number of downloads",
+			},
+			cli.DurationFlag{
+				Name:  "timeout",
+				Value: time.Minute,
+				Usage: "Timeout of each download",
+			},
+			cli.StringFlag{
+				Name:  "proxy",
+				Usage: "Download through this socks5:// or http:// proxy, ex. socks5://127.0.0.1:9050 for Tor or http://127.0.0.1:4444 for I2P",
+			},
+		},
+	}
+}
+
+// probeTimings of a single download, measured from the start of the request
+type probeTimings struct {
+	connect   time.Duration
+	handshake time.Duration
+	firstByte time.Duration
+	total     time.Duration
+	bytes     int
+}
+
+func probeAction(c *cli.Context) {
+	probeUrl := c.Args().First()
+	if probeUrl == "" {
+		fmt.Println("Usage: probe <url>")
+		os.Exit(1)
+	}
+	u, err := url.Parse(probeUrl)
+	if nil != err || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		fmt.Printf("'%s' is not an http(s) URL\n", probeUrl)
+		os.Exit(1)
+	}
+	// like routers, request i2pseeds.su3 from a server URL
+	if u.Path == "" || u.Path[len(u.Path)-1] == '/' {
+		u.Path += reseed.DEFAULT_SU3_PATH[1:]
+	}
+	if c.Int("count") < 1 {
+		fmt.Println("--count must be at least 1")
+		os.Exit(1)
+	}
+
+	transport := &http.Transport{DisableKeepAlives: true}
+	if proxy := c.String("proxy"); proxy != "" {
+		if transport, err = reseed.NewProxyTransport(proxy); nil != err {
+			fmt.Println("--proxy:", err)
+			os.Exit(1)
+		}
+	}
+	transport.TLSClientConfig = &tls.Config{}
+	if tlsCa := c.String("tlsCa"); tlsCa != "" {
+		pool, err := loadCertPool(tlsCa)
+		if nil != err {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	client := &http.Client{Transport: transport, Timeout: c.Duration("timeout")}
+	ks := reseed.KeyStore{Path: c.String("certificates")}
+
+	var all []probeTimings
+	failed := 0
+	for i := 0; i < c.Int("count"); i++ {
+		t, err := probe(client, u.String(), ks)
+		if nil != err {
+			fmt.Printf("%d: %s\n", i+1, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%d: connect %s, TLS handshake %s, first byte %s, total %s, %d bytes, %.1f KiB/s\n", i+1,
+			ms(t.connect), ms(t.handshake), ms(t.firstByte), ms(t.total), t.bytes, float64(t.bytes)/1024/t.total.Seconds())
+		all = append(all, t)
+	}
+
+	if len(all) > 1 {
+		var min, max, sum time.Duration
+		for i, t := range all {
+			if i == 0 || t.total < min {
+				min = t.total
+			}
+			if t.total > max {
+				max = t.total
+			}
+			sum += t.total
+		}
+		fmt.Printf("total min/avg/max %s/%s/%s\n", ms(min), ms(sum/time.Duration(len(all))), ms(max))
+	}
+	if failed > 0 {
+		fmt.Printf("%d of %d downloads failed\n", failed, c.Int("count"))
+		os.Exit(1)
+	}
+}
+
+// probe downloads and checks one su3 file
+func probe(client *http.Client, probeUrl string, ks reseed.KeyStore) (probeTimings, error) {
+	var t probeTimings
+	var start, connectStart, handshakeStart time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { t.connect = time.Since(connectStart) },
+		TLSHandshakeStart:    func() { handshakeStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { t.handshake = time.Since(handshakeStart) },
+		GotFirstResponseByte: func() { t.firstByte = time.Since(start) },
+	}
+
+	req, err := http.NewRequest("GET", probeUrl, nil)
+	if nil != err {
+		return t, err
+	}
+	req.Header.Set("User-Agent", reseed.I2P_USER_AGENT)
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	start = time.Now()
+	resp, err := client.Do(req)
+	if nil != err {
+		return t, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return t, fmt.Errorf("GET %s: %s", probeUrl, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	t.total = time.Since(start)
+	t.bytes = len(data)
+	if nil != err {
+		return t, err
+	}
+
+	su3File, err := su3.Parse(data)
+	if nil != err {
+		return t, err
+	}
+	cert, err := ks.ReseederCertificate(su3File.SignerId)
+	if nil != err {
+		return t, fmt.Errorf("No certificate for signer '%s': %s", su3File.SignerId, err)
+	}
+	if err := su3File.VerifySignature(cert); nil != err {
+		return t, fmt.Errorf("Invalid signature of signer '%s': %s", su3File.SignerId, err)
+	}
+	if su3File.ContentType != su3.CONTENT_TYPE_RESEED || su3File.FileType != su3.FILE_TYPE_ZIP {
+		return t, fmt.Errorf("unexpected su3 content type %d, file type %d", su3File.ContentType, su3File.FileType)
+	}
+
+	summary, err := reseed.SummarizeSeeds(su3File.Content)
+	if nil != err {
+		return t, err
+	}
+	fmt.Printf("Signed by '%s', %d routerInfos (%d reachable, %d floodfill, %d unparsed), published %s to %s\n",
+		su3File.SignerId, summary.RouterInfos, summary.Reachable, summary.Floodfill, summary.Unparsed,
+		summary.Oldest.Format(time.RFC3339), summary.Newest.Format(time.RFC3339))
+
+	return t, nil
+}
+
+func ms(d time.Duration) string {
+	return d.Round(time.Millisecond).String()
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// how long to wait for an answer before assuming no
+	DEFAULT_PROMPT_TIMEOUT = 60 * time.Second
+)
+
+// lines read from stdin, one reader for all prompts so an abandoned prompt
+// doesn't swallow the answer to the next one
+var (
+	stdinLines     = make(chan string)
+	stdinLinesOnce sync.Once
+)
+
+func readStdinLines() {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			stdinLines <- line
+		}
+		if nil != err {
+			close(stdinLines)
+			return
+		}
+	}
+}
+
+// promptYesNo asks question on stderr and returns true if the answer starts
+// with y. No answer within timeout (0 waits forever) or the end of stdin is a
+// no, a cancelled ctx returns its error.
+func promptYesNo(ctx context.Context, timeout time.Duration, question string) (bool, error) {
+	stdinLinesOnce.Do(func() { go readStdinLines() })
+
+	fmt.Fprintf(os.Stderr, "%s (y or n): ", question)
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	select {
+	case input := <-stdinLines:
+		return strings.HasPrefix(strings.TrimSpace(input), "y"), nil
+	case <-expired:
+		fmt.Fprintf(os.Stderr, "\nNo answer after %s, assuming no\n", timeout)
+		return false, nil
+	case <-ctx.Done():
+		fmt.Fprintln(os.Stderr)
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sort"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+// configReloader applies the changes to the --config file that are safe
+// while running. Listen addresses, keys and the other options need a
+// restart.
+type configReloader struct {
+	path     string
+	reseeder *reseed.ReseederImpl
+	server   *reseed.Server
+	// the options in effect, new values of options needing a restart are
+	// not recorded so they are reported until the restart
+	config *reseedConfig
+	// flags given on the command line take precedence over the file
+	cmdline map[string]bool
+}
+
+// reloadOnHangup reloads the config file on every SIGHUP. Without a config
+// file the signal is logged instead of ending the server.
+func reloadOnHangup(reloader *configReloader) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+	for range sigs {
+		if nil == reloader {
+			log.Println("SIGHUP: there is no --config file to reload")
+			continue
+		}
+		if err := reloader.reload(); nil != err {
+			log.Println("Unable to reload the config file:", err)
+		}
+	}
+}
+
+func (r *configReloader) reload() error {
+	config, err := readReseedConfig(r.path)
+	if nil != err {
+		return err
+	}
+	log.Println("Reloading", r.path)
+
+	// sorted so changes are logged in a stable order
+	names := make(map[string]bool)
+	for name := range r.config.Flags {
+		names[name] = true
+	}
+	for name := range config.Flags {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	for _, name := range sorted {
+		old, v := r.config.Flags[name], config.Flags[name]
+		if reflect.DeepEqual(old, v) {
+			continue
+		}
+		switch {
+		case r.cmdline[name]:
+			log.Printf("  %s: given on the command line, the config file is ignored\n", name)
+		case nil == v:
+			log.Printf("  %s: removed, restart to use the default\n", name)
+		case !liveOption(name):
+			log.Printf("  %s: changed, restart to use it\n", name)
+		default:
+			value, err := configValue(name, v)
+			if nil == err {
+				err = r.apply(name, value)
+			}
+			if nil != err {
+				log.Printf("  %s: %s, keeping %v\n", name, err, old)
+				continue
+			}
+			r.config.Flags[name] = v
+			log.Printf("  %s: changed to %s\n", name, value)
+		}
+	}
+
+	if !reflect.DeepEqual(r.config.Profiles, config.Profiles) {
+		if err := sameProfilePaths(r.config.Profiles, config.Profiles); nil != err {
+			log.Printf("  profiles: %s, restart to use them\n", err)
+		} else {
+			r.reseeder.SetProfiles(config.Profiles)
+			r.config.Profiles = config.Profiles
+			log.Println("  profiles: changed, used from the next rebuild")
+		}
+	}
+	if !reflect.DeepEqual(r.config.Headers, config.Headers) {
+		log.Println("  headers: changed, restart to use them")
+	}
+
+	return nil
+}
+
+// liveOption reports if the option can change while running
+func liveOption(name string) bool {
+	switch name {
+	case "interval", "perConnBandwidth", "accessLogSample", "maintenance":
+		return true
+	}
+
+	return false
+}
+
+// apply sets a live option to value
+func (r *configReloader) apply(name, value string) error {
+	switch name {
+	case "interval":
+		interval, err := time.ParseDuration(value)
+		if nil != err || interval <= 0 {
+			return fmt.Errorf("'%s' is not a valid time interval", value)
+		}
+		r.reseeder.SetRebuildInterval(interval)
+	case "perConnBandwidth":
+		rate, err := strconv.Atoi(value)
+		if nil != err || rate < 0 {
+			return fmt.Errorf("must be a number of bytes per second")
+		}
+		r.server.SetBandwidthPerConn(rate)
+	case "accessLogSample":
+		sample, err := strconv.ParseFloat(value, 64)
+		if nil != err || sample < 0 || sample > 1 {
+			return fmt.Errorf("must be between 0.0 and 1.0")
+		}
+		r.server.SetAccessLogSample(sample)
+	case "maintenance":
+		maintenance, err := strconv.ParseBool(value)
+		if nil != err {
+			return err
+		}
+		r.server.SetMaintenance(maintenance)
+	}
+
+	return nil
+}
+
+// sameProfilePaths returns an error if the profiles are not served at the
+// same paths, the handlers are registered at startup
+func sameProfilePaths(old, profiles []reseed.Profile) error {
+	paths := make(map[string]string)
+	for _, profile := range old {
+		paths[profile.Name] = profile.Path
+	}
+	if len(profiles) != len(old) {
+		return fmt.Errorf("profiles were added or removed")
+	}
+	for _, profile := range profiles {
+		if path, ok := paths[profile.Name]; !ok || path != profile.Path {
+			return fmt.Errorf("profile '%s' is new or moved", profile.Name)
+		}
+	}
+
+	return nil
+}
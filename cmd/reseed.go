@@ -1,13 +1,30 @@
 package cmd
 
 import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
+	"mime"
 	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
 	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
 	"github.com/codegangsta/cli"
 )
 
@@ -16,14 +33,22 @@ func NewReseedCommand() cli.Command {
 		Name:   "reseed",
 		Usage:  "Start a reseed server",
 		Action: reseedAction,
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "config",
+				Usage: "Path to a JSON file with reseed options and bundle profiles, command line flags take precedence",
+			},
 			cli.StringFlag{
 				Name:  "signer",
 				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
 			},
+			cli.StringFlag{
+				Name:  "expectedSigner",
+				Usage: "Refuse to start unless the signing certificate was issued to this signer ID, to catch the key of another reseed mounted by mistake",
+			},
 			cli.StringFlag{
 				Name:  "tlsHost",
-				Usage: "The public hostname used on your TLS certificate",
+				Usage: "The public hostname used on your TLS certificate (comma separated, or @file with one host per line)",
 			},
 			cli.StringFlag{
 				Name:  "key",
@@ -31,7 +56,11 @@ func NewReseedCommand() cli.Command {
 			},
 			cli.StringFlag{
 				Name:  "netdb",
-				Usage: "Path to NetDB directory containing routerInfos",
+				Usage: "Path to NetDB directory containing routerInfos (comma separated to merge several)",
+			},
+			cli.StringFlag{
+				Name:  "netdbDb",
+				Usage: "Path to a bbolt database with routerInfos keyed by router hash in its routerInfos bucket, instead of --netdb",
 			},
 			cli.StringFlag{
 				Name:  "tlsCert",
@@ -41,16 +70,87 @@ func NewReseedCommand() cli.Command {
 				Name:  "tlsKey",
 				Usage: "Path to a TLS private key",
 			},
+			cli.StringFlag{
+				Name:  "tlsBundle",
+				Usage: "Path to a single PEM file containing the TLS certificate chain and private key",
+			},
+			cli.StringFlag{
+				Name:  "tlsCaBundle",
+				Usage: "PEM file with the roots the TLS certificate chain is checked against at startup, the system roots by default",
+			},
+			cli.BoolFlag{
+				Name:  "tlsFetchIntermediates",
+				Usage: "Download the intermediates missing from the TLS certificate chain from the AIA URLs of the certificates and serve them",
+			},
+			cli.BoolFlag{
+				Name:  "requireClientCert",
+				Usage: "Only serve clients with a TLS client certificate issued by --clientCa, for private reseeds",
+			},
+			cli.StringFlag{
+				Name:  "clientCa",
+				Usage: "PEM file with the CA certificates client certificates are verified against",
+			},
+			cli.DurationFlag{
+				Name:  "promptTimeout",
+				Value: DEFAULT_PROMPT_TIMEOUT,
+				Usage: "How long to wait for an answer when asked to generate missing keys before assuming no, 0 waits forever",
+			},
 			cli.StringFlag{
 				Name:  "ip",
 				Value: "0.0.0.0",
-				Usage: "IP address to listen on",
+				Usage: "IP address to listen on, link-local IPv6 addresses need their zone (fe80::1%eth0)",
 			},
 			cli.StringFlag{
 				Name:  "port",
 				Value: "8443",
 				Usage: "Port to listen on",
 			},
+			cli.StringFlag{
+				Name:  "listenHttp",
+				Value: "",
+				Usage: "Also serve plain HTTP on this address for a TLS terminating proxy, trusting its X-Forwarded-For header (ex. 127.0.0.1:8080 or unix:/run/reseed.sock)",
+			},
+			cli.StringFlag{
+				Name:  "listenHttpMode",
+				Value: "0660",
+				Usage: "Permissions of the unix socket created for --listenHttp",
+			},
+			cli.StringSliceFlag{
+				Name:  "netdbFallback",
+				Usage: "netDb source to rebuild from while the netdb has too few routerInfos, tried in the order given: a netDb directory (comma separated to merge several), bolt:/path/to/db or su3 URLs of other reseeds or mirrors (comma separated)",
+			},
+			cli.StringFlag{
+				Name:  "fallbackCertificates",
+				Value: "./certificates",
+				Usage: "Directory with the certificates of the signers of --netdbFallback su3 URLs",
+			},
+			cli.StringFlag{
+				Name:  "fallbackProxy",
+				Usage: "Fetch --netdbFallback su3 URLs through this socks5:// or http:// proxy, ex. socks5://127.0.0.1:9050 for Tor",
+			},
+			cli.BoolFlag{
+				Name:  "embeddedFallback",
+				Usage: "Serve the routerInfos compiled into the binary while the netdb and --netdbFallback sources have too few routerInfos, requires a build with routerInfos in reseed/bootstrap",
+			},
+			cli.StringFlag{
+				Name:  "bundleCache",
+				Value: "",
+				Usage: "Shared directory to publish the signed su3 files to, or to serve them from with --bundleCacheRole=server",
+			},
+			cli.StringFlag{
+				Name:  "bundleCacheRole",
+				Value: "builder",
+				Usage: "'builder' builds and publishes to --bundleCache, 'server' only serves what a builder published",
+			},
+			cli.DurationFlag{
+				Name:  "bundleCachePoll",
+				Value: 30 * time.Second,
+				Usage: "How often a --bundleCacheRole=server instance checks for a new bundle",
+			},
+			cli.BoolFlag{
+				Name:  "writeChecksum",
+				Usage: "Publish a .sha256 file with the digest of each su3 file to --bundleCache, for mirrors",
+			},
 			cli.IntFlag{
 				Name:  "numRi",
 				Value: 77,
@@ -61,6 +161,43 @@ func NewReseedCommand() cli.Command {
 				Value: 0,
 				Usage: "Number of su3 files to build (0 = automatic based on size of netdb)",
 			},
+			cli.StringFlag{
+				Name:  "compression",
+				Value: reseed.COMPRESSION_DEFAULT,
+				Usage: "Compression of the routerInfos in the su3 files: 'deflate', 'best' (maximum deflate level, slightly smaller for more CPU on rebuilds) or a deflate level from 0 (fastest rebuilds) to 9",
+			},
+			cli.IntFlag{
+				Name:  "maxBundleBytes",
+				Value: 0,
+				Usage: "Maximum size of an su3 file in bytes (0 = no limit)",
+			},
+			cli.IntFlag{
+				Name:  "perConnBandwidth",
+				Value: 0,
+				Usage: "Maximum bytes per second of each su3 download, to leave room for other services on small uplinks (0 = no limit)",
+			},
+			cli.IntFlag{
+				Name:  "rebuildCpuLimit",
+				Value: reseed.DEFAULT_REBUILD_WORKERS,
+				Usage: "Number of goroutines building su3 files in a rebuild, independent of GOMAXPROCS. Lower it on shared hosts so rebuilds take longer but disturb other services less",
+			},
+			cli.DurationFlag{
+				Name:  "rebuildPause",
+				Usage: "Pause after each routerInfo file read and su3 file built in a rebuild, to spread it further with a low --rebuildCpuLimit (ex. 5ms)",
+			},
+			cli.StringFlag{
+				Name:  "onOversize",
+				Value: reseed.OVERSIZE_TRIM,
+				Usage: "What to do with su3 files over --maxBundleBytes: 'trim' drops routerInfos, keeping reachable and newer ones, 'fail' fails the rebuild",
+			},
+			cli.BoolFlag{
+				Name:  "includeManifest",
+				Usage: "Add an info.json listing the router hashes to each su3 zip (the files then differ from stock reseeds)",
+			},
+			cli.BoolFlag{
+				Name:  "verifyRouterInfos",
+				Usage: "Check the signature of every routerInfo and skip invalid ones (uses more CPU)",
+			},
 			cli.StringFlag{
 				Name:  "interval",
 				Value: "90h",
@@ -71,10 +208,172 @@ func NewReseedCommand() cli.Command {
 				Value: "",
 				Usage: "Prefix path for the HTTP(S) server. (ex. /netdb)",
 			},
+			cli.StringFlag{
+				Name:  "publicUrl",
+				Value: "",
+				Usage: "URL routers reach the prefix at, used for absolute links (default from --tlsHost or the listen address)",
+			},
+			cli.BoolFlag{
+				Name:  "index",
+				Usage: "Serve an index page linking the su3 files and the signing certificate at the prefix",
+			},
+			cli.StringFlag{
+				Name:  "notFoundPage",
+				Usage: "File to answer requests for unknown paths with, instead of an empty body",
+			},
+			cli.IntFlag{
+				Name:  "notFoundStatus",
+				Value: http.StatusNotFound,
+				Usage: "HTTP status of the answer to unknown paths",
+			},
+			cli.StringFlag{
+				Name:  "notFoundRedirect",
+				Usage: "Redirect requests for unknown paths to this URL, ex. / with --index",
+			},
+			cli.BoolFlag{
+				Name:  "closeScannerPaths",
+				Usage: "Close the connection without a response to requests for paths of web scanners, ex. /wp-login.php, and don't log them",
+			},
+			cli.StringSliceFlag{
+				Name:  "scannerPath",
+				Usage: "Path to close with --closeScannerPaths in addition to the built in ones, ex. /*.asp or /owa/ for everything below it. May be given several times.",
+			},
+			cli.StringFlag{
+				Name:  "su3Path",
+				Value: reseed.DEFAULT_SU3_PATH,
+				Usage: "Path the su3 file is served at, relative to the prefix",
+			},
+			cli.StringFlag{
+				Name:  "onRebuild",
+				Value: "",
+				Usage: "Command to run after each rebuild, called with the directory of the new su3 files and their sha256",
+			},
+			cli.DurationFlag{
+				Name:  "onRebuildTimeout",
+				Value: time.Minute,
+				Usage: "Kill the --onRebuild command if it runs longer than this",
+			},
+			cli.BoolFlag{
+				Name:  "maintenance",
+				Usage: "Start in maintenance: answer su3 requests with 503 until it is turned off with a POST to /admin/maintenance",
+			},
+			cli.StringFlag{
+				Name:  "crlPath",
+				Usage: "Publish a CRL signed with the signing key at this path (ex. /reseed.crl) and revoke serials with a POST to /admin/revoke",
+			},
+			cli.StringFlag{
+				Name:  "revocations",
+				Usage: "File the serials revoked at /admin/revoke are kept in (default <signer>.revoked)",
+			},
+			cli.Float64Flag{
+				Name:  "accessLogSample",
+				Value: 1,
+				Usage: "Fraction of successful requests to write to the access log (0.0-1.0), errors and rate limited requests are always logged",
+			},
+			cli.BoolFlag{
+				Name:  "logSyslog",
+				Usage: "Send the log and the access log to syslog instead of stderr and stdout",
+			},
+			cli.StringFlag{
+				Name:  "syslogFacility",
+				Value: "daemon",
+				Usage: "Syslog facility with --logSyslog, ex. daemon or local0",
+			},
+			cli.StringFlag{
+				Name:  "syslogAddr",
+				Usage: "Remote syslog server with --logSyslog, host:port or tcp://host:port (default: the local syslog daemon)",
+			},
+			cli.StringFlag{
+				Name:  "tempDir",
+				Usage: "Directory for the intermediate files of rebuilds, created if needed (default: the system temp dir)",
+			},
+			cli.StringFlag{
+				Name:  "webhookUrl",
+				Value: "",
+				Usage: "URL to POST a JSON notification to on startup, shutdown, rebuilds and approaching cert expiry",
+			},
+			cli.StringFlag{
+				Name:  "webhookSecret",
+				Value: "",
+				Usage: "Secret used to sign webhook payloads (HMAC-SHA256 in the X-Reseed-Signature header)",
+			},
+			cli.DurationFlag{
+				Name:  "certExpiryWarn",
+				Value: 30 * 24 * time.Hour,
+				Usage: "Warn when the signing or TLS certificate expires within this duration",
+			},
+			cli.DurationFlag{
+				Name:  "maxBundleAge",
+				Usage: "Fail /healthz and warn once the served su3 files are older than this, ex. because every rebuild fails (default no limit)",
+			},
+			cli.BoolFlag{
+				Name:  "refuseStaleBundle",
+				Usage: "Also answer su3 requests with 503 once the su3 files are older than --maxBundleAge",
+			},
+			cli.BoolFlag{
+				Name:  "acceptRouterInfos",
+				Usage: "Accept routerInfos POSTed to /router by trusted routers with --routerInfoSubmitToken and include them in the next rebuilds",
+			},
+			cli.StringFlag{
+				Name:  "routerInfoSubmitToken",
+				Value: "",
+				Usage: "Bearer token routers POST their routerInfo to /router with, not the admin one",
+			},
+			cli.IntFlag{
+				Name:  "routerInfoSubmitLimit",
+				Value: 60,
+				Usage: "routerInfos a client IP may POST to /router per hour, failed attempts included",
+			},
+			cli.BoolFlag{
+				Name:  "enableLegacyReseed",
+				Usage: "Also serve the routerInfos of the su3 files one by one at /netDb/, for routers from before su3 files",
+			},
+			cli.BoolFlag{
+				Name:  "nextRebuildHeader",
+				Usage: "Tell clients when the next rebuild is due in X-Reseed-Next-Rebuild and a Cache-Control max-age on su3 responses",
+			},
+			cli.DurationFlag{
+				Name:  "certCheckInterval",
+				Value: 24 * time.Hour,
+				Usage: "Duration between certificate expiry checks",
+			},
+			cli.BoolFlag{
+				Name:  "autoRenew",
+				Usage: "Renew self-signed certificates expiring within --certExpiryWarn, keeping their keys",
+			},
+			cli.BoolFlag{
+				Name:  "autoRenewTlsKey",
+				Usage: "With --autoRenew, also generate a new TLS key. The signing key is never replaced automatically.",
+			},
 			cli.BoolFlag{
 				Name:  "trustProxy",
 				Usage: "If provided, we will trust the 'X-Forwarded-For' header in requests (ex. behind cloudflare)",
 			},
+			cli.IntFlag{
+				Name:  "banThreshold",
+				Value: 0,
+				Usage: "Ban an IP after this many rate limit violations or bad requests within --banWindow (0 = disabled)",
+			},
+			cli.DurationFlag{
+				Name:  "banWindow",
+				Value: time.Hour,
+				Usage: "Window in which violations are counted",
+			},
+			cli.DurationFlag{
+				Name:  "banTime",
+				Value: time.Hour,
+				Usage: "Duration of the first ban, doubled for each further ban of the same IP",
+			},
+			cli.DurationFlag{
+				Name:  "banMaxTime",
+				Value: 7 * 24 * time.Hour,
+				Usage: "Maximum duration of a ban",
+			},
+			cli.IntFlag{
+				Name:  "banMaxEntries",
+				Value: 100000,
+				Usage: "Maximum number of IPs tracked for banning",
+			},
 			cli.StringFlag{
 				Name:  "blacklist",
 				Value: "",
@@ -85,15 +384,151 @@ func NewReseedCommand() cli.Command {
 				Value: 0,
 				Usage: "Periodically print memory stats.",
 			},
-		},
+			cli.StringFlag{
+				Name:  "serverHeader",
+				Value: "",
+				Usage: "Value of the Server response header (default none). More headers can be set in the --config file",
+			},
+			cli.BoolFlag{
+				Name:  "selfCheck",
+				Usage: "After startup, download our own su3 file over loopback and verify its signature",
+			},
+			cli.BoolFlag{
+				Name:  "selfCheckStrict",
+				Usage: "Exit if the --selfCheck fails",
+			},
+			cli.StringSliceFlag{
+				Name:  "tlsAllowedSni",
+				Usage: "Only complete TLS handshakes on the main listener for this host name, rejecting other or no SNI (repeatable, the --publicUrl host must be one)",
+			},
+			cli.BoolFlag{
+				Name:  "tlsDebug",
+				Usage: "Log the details of every TLS handshake",
+			},
+			cli.StringFlag{
+				Name:  "adminListen",
+				Value: "",
+				Usage: "Also serve the operator endpoints on this address, on 127.0.0.1 without a host (ex. :6060)",
+			},
+			cli.StringFlag{
+				Name:  "adminTlsCert",
+				Usage: "Serve --adminListen over TLS with this certificate, independent of the public one",
+			},
+			cli.StringFlag{
+				Name:  "adminTlsKey",
+				Usage: "Private key of --adminTlsCert, may be the same file for a combined PEM bundle",
+			},
+			cli.StringSliceFlag{
+				Name:  "listen",
+				Usage: "Also serve a handler set on its own address, ex. handlers=admin,addr=127.0.0.1:8443,cert=admin.crt,key=admin.pem. The handler sets are 'public' and 'admin', cert and key are optional. May be given several times.",
+			},
+			cli.BoolFlag{
+				Name:  "pprof",
+				Usage: "Serve the Go profiling endpoints at /debug/pprof/ on --adminListen and the admin --listen addresses",
+			},
+			cli.StringFlag{
+				Name:  "adminAuthToken",
+				Value: "",
+				Usage: "Bearer token required for the operator endpoints (ex. /stats.json)",
+			},
+			cli.StringFlag{
+				Name:  "adminBasicAuth",
+				Value: "",
+				Usage: "user:passhash required for the operator endpoints, passhash is the hex sha256 of the password",
+			},
+		}, certFlags()...),
 	}
 }
 
 func reseedAction(c *cli.Context) {
+	// fill in flags from the config file
+	var profiles []reseed.Profile
+	var headers map[string]string
+	var reloader *configReloader
+	if configFile := c.String("config"); configFile != "" {
+		config, err := readReseedConfig(configFile)
+		if nil != err {
+			log.Fatalln(err)
+		}
+		reloader = &configReloader{path: configFile, config: config, cmdline: make(map[string]bool)}
+		for _, name := range c.FlagNames() {
+			reloader.cmdline[name] = c.IsSet(name)
+		}
+		if err := config.apply(c); nil != err {
+			log.Fatalln(err)
+		}
+		profiles = config.Profiles
+		headers = config.Headers
+	}
+
+	// send the log to syslog before anything is logged
+	var syslogWriter io.Writer
+	if c.Bool("logSyslog") {
+		w, err := reseed.NewSyslogWriter(c.String("syslogFacility"), c.String("syslogAddr"))
+		if nil != err {
+			log.Fatalln("--logSyslog:", err)
+		}
+		// syslog adds the time
+		log.SetOutput(w)
+		log.SetFlags(0)
+		syslogWriter = w
+	}
+
 	// validate flags
+	bundleCacheRole := c.String("bundleCacheRole")
+	if bundleCacheRole != "builder" && bundleCacheRole != "server" {
+		fmt.Println("--bundleCacheRole must be 'builder' or 'server'")
+		return
+	}
+	following := c.String("bundleCache") != "" && bundleCacheRole == "server"
+	if c.Bool("writeChecksum") && (c.String("bundleCache") == "" || following) {
+		fmt.Println("--writeChecksum requires --bundleCache with --bundleCacheRole=builder")
+		return
+	}
+	certOpts, err := newCertOptions(c)
+	if nil != err {
+		fmt.Println(err)
+		return
+	}
+	if c.Duration("promptTimeout") < 0 {
+		fmt.Println("--promptTimeout can't be negative")
+		return
+	}
+
+	// an interrupt cancels the prompts for missing keys
+	promptCtx, stopPrompts := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopPrompts()
+	if following && c.Duration("bundleCachePoll") <= 0 {
+		fmt.Println("--bundleCachePoll must be positive")
+		return
+	}
+
 	netdbDir := c.String("netdb")
-	if netdbDir == "" {
-		fmt.Println("--netdb is required")
+	netdbDb := c.String("netdbDb")
+	// only the mirror command has an upstream
+	upstream := c.StringSlice("upstream")
+	if len(upstream) > 0 && (netdbDir != "" || netdbDb != "") {
+		fmt.Println("--upstream replaces --netdb and --netdbDb")
+		return
+	}
+	if netdbDir == "" && netdbDb == "" && len(upstream) == 0 && !following {
+		fmt.Println("--netdb or --netdbDb is required")
+		return
+	}
+	if c.Bool("verbatim") && len(upstream) == 0 {
+		fmt.Println("--verbatim requires --upstream")
+		return
+	}
+	if c.Bool("verbatim") && (len(c.StringSlice("netdbFallback")) > 0 || c.Bool("embeddedFallback")) {
+		fmt.Println("--verbatim serves the upstream su3 files, it can't fall back to other netDbs")
+		return
+	}
+	if c.Bool("verbatim") && c.Bool("selfCheck") {
+		fmt.Println("--selfCheck verifies with the local signing key, it can't be used with --verbatim")
+		return
+	}
+	if netdbDir != "" && netdbDb != "" {
+		fmt.Println("--netdb and --netdbDb can't be used together")
 		return
 	}
 
@@ -102,24 +537,33 @@ func reseedAction(c *cli.Context) {
 		fmt.Println("--signer is required")
 		return
 	}
+	if err := su3.CheckSignerId(signerId); nil != err {
+		fmt.Println(err)
+		return
+	}
 
 	var tlsCert, tlsKey string
 	tlsHost := c.String("tlsHost")
-	if tlsHost != "" {
+	if tlsBundle := c.String("tlsBundle"); tlsBundle != "" {
+		if _, err := reseed.LoadTLSBundle(tlsBundle); nil != err {
+			log.Fatalln(err)
+		}
+		tlsCert, tlsKey = tlsBundle, tlsBundle
+	} else if tlsHost != "" {
 		tlsKey = c.String("tlsKey")
 		// if no key is specified, default to the host.pem in the current dir
 		if tlsKey == "" {
-			tlsKey = tlsHost + ".pem"
+			tlsKey = tlsFile(tlsHost) + ".pem"
 		}
 
 		tlsCert = c.String("tlsCert")
 		// if no certificate is specified, default to the host.crt in the current dir
 		if tlsCert == "" {
-			tlsCert = tlsHost + ".crt"
+			tlsCert = tlsFile(tlsHost) + ".crt"
 		}
 
 		// prompt to create tls keys if they don't exist?
-		err := checkOrNewTLSCert(tlsHost, &tlsCert, &tlsKey)
+		err := checkOrNewTLSCert(promptCtx, c.Duration("promptTimeout"), tlsHost, &tlsCert, &tlsKey, certOpts)
 		if nil != err {
 			log.Fatalln(err)
 		}
@@ -138,13 +582,101 @@ func reseedAction(c *cli.Context) {
 	}
 
 	// load our signing privKey
-	privKey, err := getOrNewSigningCert(&signerKey, signerId)
+	privKey, err := getOrNewSigningCert(promptCtx, c.Duration("promptTimeout"), &signerKey, signerId, certOpts)
 	if nil != err {
 		log.Fatalln(err)
 	}
+	stopPrompts()
+
+	// the signing certificate, usually stored along with the key. New su3
+	// files are verified with it before they are served.
+	var signerCert *x509.Certificate
+	if cert, err := loadCertificate(signerKey); nil == err {
+		signerCert = cert
+	} else if cert, err := loadCertificate(signerFile(signerId) + ".crt"); nil == err {
+		signerCert = cert
+	} else {
+		log.Println("Unable to find the signing certificate, it will not be served:", err)
+	}
+	if nil != signerCert {
+		if err := checkCertKey(signerCert, &privKey.PublicKey); nil != err {
+			log.Println("Not serving the signing certificate:", err)
+			signerCert = nil
+		}
+	}
+	// guard against deploying the key of another reseed
+	if expectedSigner := c.String("expectedSigner"); "" != expectedSigner {
+		if err := checkExpectedSigner(signerCert, signerId, expectedSigner); nil != err {
+			log.Fatalln(err)
+		}
+	}
 
-	// create a local file netdb provider
-	netdb := reseed.NewLocalNetDb(netdbDir)
+	// create a local file netdb provider, or a remote one for a mirror
+	var netdb reseed.NetDbProvider
+	var remote *reseed.RemoteNetDbImpl
+	if len(upstream) > 0 {
+		remote = reseed.NewRemoteNetDb(upstream, c.String("upstreamCertificates"))
+		if upstreamProxy := c.String("upstreamProxy"); "" != upstreamProxy {
+			transport, err := reseed.NewProxyTransport(upstreamProxy)
+			if nil != err {
+				log.Fatalln("--upstreamProxy:", err)
+			}
+			remote.Client.Transport = transport
+		}
+		netdb = remote
+		netdbDir = strings.Join(upstream, ",")
+	} else {
+		netdb = newNetDb(netdbDir, netdbDb)
+		if pacer, ok := netdb.(reseed.ReadPacer); ok {
+			pacer.SetReadPause(c.Duration("rebuildPause"))
+		}
+	}
+	if fallbacks := c.StringSlice("netdbFallback"); len(fallbacks) > 0 || c.Bool("embeddedFallback") {
+		// a rebuild uses 3/4 of the routerInfos and needs numRi of them
+		chain := &reseed.ChainNetDbImpl{MinRi: (c.Int("numRi")*4 + 2) / 3}
+		chain.Sources = append(chain.Sources, reseed.NetDbSource{Name: netdbDir + netdbDb, NetDbProvider: netdb})
+		var transport http.RoundTripper
+		if fallbackProxy := c.String("fallbackProxy"); "" != fallbackProxy {
+			if transport, err = reseed.NewProxyTransport(fallbackProxy); nil != err {
+				log.Fatalln("--fallbackProxy:", err)
+			}
+		}
+		for _, spec := range fallbacks {
+			chain.Sources = append(chain.Sources, newNetDbSource(spec, c.String("fallbackCertificates"), transport))
+		}
+		if c.Bool("embeddedFallback") {
+			embedded, err := reseed.NewEmbeddedNetDb()
+			if nil != err {
+				fmt.Println("--embeddedFallback:", err)
+				return
+			}
+			chain.Sources = append(chain.Sources, reseed.NetDbSource{Name: "embedded", NetDbProvider: embedded})
+		}
+		netdb = chain
+	}
+
+	// trusted routers keep the netDb fresh
+	var submitted *reseed.SubmittedNetDbImpl
+	if c.Bool("acceptRouterInfos") {
+		if following || c.Bool("verbatim") {
+			fmt.Println("--acceptRouterInfos requires building the su3 files, not following a --bundleCache or --verbatim")
+			return
+		}
+		if c.String("routerInfoSubmitToken") == "" {
+			fmt.Println("--acceptRouterInfos requires --routerInfoSubmitToken")
+			return
+		}
+		if c.String("routerInfoSubmitToken") == c.String("adminAuthToken") {
+			fmt.Println("--routerInfoSubmitToken must not be the admin token")
+			return
+		}
+		if c.Int("routerInfoSubmitLimit") <= 0 {
+			fmt.Println("--routerInfoSubmitLimit must be positive")
+			return
+		}
+		submitted = reseed.NewSubmittedNetDb(netdb)
+		netdb = submitted
+	}
 
 	// create a reseeder
 	reseeder := reseed.NewReseeder(netdb)
@@ -152,13 +684,206 @@ func reseedAction(c *cli.Context) {
 	reseeder.SignerId = []byte(signerId)
 	reseeder.NumRi = c.Int("numRi")
 	reseeder.NumSu3 = c.Int("numSu3")
+	reseeder.VerifyRouterInfos = c.Bool("verifyRouterInfos")
 	reseeder.RebuildInterval = reloadIntvl
+	reseeder.BundleCache = c.String("bundleCache")
+	reseeder.BundleCacheChecksums = c.Bool("writeChecksum")
+	reseeder.FollowBundleCache = following
+	reseeder.BundleCachePoll = c.Duration("bundleCachePoll")
+	reseeder.Profiles = profiles
+	if c.Bool("verbatim") {
+		reseeder.Verbatim = remote
+	}
+	if err := reseeder.SetCompression(c.String("compression")); nil != err {
+		fmt.Println(err)
+		return
+	}
+	reseeder.IncludeManifest = c.Bool("includeManifest")
+	reseeder.RebuildWorkers = c.Int("rebuildCpuLimit")
+	reseeder.RebuildPause = c.Duration("rebuildPause")
+	if reseeder.RebuildWorkers < 1 || reseeder.RebuildPause < 0 {
+		fmt.Println("--rebuildCpuLimit must be at least 1 and --rebuildPause can't be negative")
+		return
+	}
+	reseeder.MaxBundleBytes = c.Int("maxBundleBytes")
+	reseeder.OnOversize = c.String("onOversize")
+	if reseeder.OnOversize != reseed.OVERSIZE_TRIM && reseeder.OnOversize != reseed.OVERSIZE_FAIL {
+		fmt.Printf("--onOversize must be '%s' or '%s'\n", reseed.OVERSIZE_TRIM, reseed.OVERSIZE_FAIL)
+		return
+	}
+	tempDir := c.String("tempDir")
+	if "" != tempDir {
+		if err := os.MkdirAll(tempDir, 0700); nil != err {
+			log.Fatalln("--tempDir:", err)
+		}
+	}
+	if onRebuild := c.String("onRebuild"); "" != onRebuild {
+		hook := &reseed.RebuildHook{Command: onRebuild, Timeout: c.Duration("onRebuildTimeout"), TempDir: tempDir}
+		reseeder.OnRebuild = append(reseeder.OnRebuild, hook.Run)
+	}
+
+	publicUrl := c.String("publicUrl")
+	if publicUrl == "" {
+		publicUrl = defaultPublicUrl(tlsHost, tlsCert != "", c.String("ip"), c.String("port"), c.String("prefix"))
+	}
+	if publicUrl, err = reseed.ParsePublicURL(publicUrl); nil != err {
+		fmt.Println("--publicUrl:", err)
+		return
+	}
+	log.Println("Public URL:", publicUrl)
+
+	var webhook *reseed.Webhook
+	if webhookUrl := c.String("webhookUrl"); "" != webhookUrl {
+		webhook = reseed.NewWebhook(webhookUrl, c.String("webhookSecret"))
+		webhook.Signer = signerId
+		webhook.PublicURL = publicUrl
+		reseeder.OnRebuild = append(reseeder.OnRebuild, func(su3s [][]byte) {
+			webhook.Notify(reseed.EVENT_REBUILD_SUCCESS, map[string]interface{}{"numSu3": len(su3s)})
+		})
+		reseeder.OnRebuildError = append(reseeder.OnRebuildError, func(err error) {
+			webhook.Notify(reseed.EVENT_REBUILD_FAILURE, map[string]interface{}{"error": err.Error()})
+		})
+		webhook.Notify(reseed.EVENT_STARTUP, nil)
+	}
+
+	reseeder.SignerCertificate = signerCert
+
 	reseeder.Start()
 
 	// create a server
-	server := reseed.NewServer(c.String("prefix"), c.Bool("trustProxy"))
+	server := reseed.NewServer(c.String("prefix"), c.String("su3Path"), c.Bool("trustProxy"))
 	server.Reseeder = reseeder
 	server.Addr = net.JoinHostPort(c.String("ip"), c.String("port"))
+	server.PublicURL = publicUrl
+	server.Index = c.Bool("index")
+	if nil != syslogWriter {
+		server.AccessLog = syslogWriter
+	}
+	server.SetMaintenance(c.Bool("maintenance"))
+	accessLogSample := c.Float64("accessLogSample")
+	if accessLogSample < 0 || accessLogSample > 1 {
+		log.Fatalln("--accessLogSample must be between 0.0 and 1.0")
+	}
+	server.SetAccessLogSample(accessLogSample)
+	server.NextRebuildHeader = c.Bool("nextRebuildHeader")
+	if c.Int("perConnBandwidth") < 0 {
+		log.Fatalln("--perConnBandwidth can't be negative")
+	}
+	server.SetBandwidthPerConn(c.Int("perConnBandwidth"))
+	if err := setNotFound(server, c); nil != err {
+		log.Fatalln(err)
+	}
+	socketMode, err := strconv.ParseUint(c.String("listenHttpMode"), 8, 32)
+	if nil != err || socketMode > 0777 {
+		log.Fatalf("--listenHttpMode must be octal permissions like 0660, not '%s'\n", c.String("listenHttpMode"))
+	}
+	server.SocketMode = os.FileMode(socketMode)
+	if c.Bool("requireClientCert") {
+		if tlsCert == "" || tlsKey == "" {
+			log.Fatalln("--requireClientCert requires TLS")
+		}
+		if c.String("listenHttp") != "" {
+			log.Fatalln("--requireClientCert can't be used with --listenHttp, it serves clients without certificates")
+		}
+		if c.Bool("selfCheck") {
+			log.Fatalln("--requireClientCert can't be used with --selfCheck, it has no client certificate")
+		}
+		cas, err := loadCertPool(c.String("clientCa"))
+		if nil != err {
+			log.Fatalln("--clientCa:", err)
+		}
+		server.RequireClientCerts(cas)
+	}
+	if tlsCert != "" && tlsKey != "" {
+		// some routers reject incomplete chains of CA issued certificates
+		if err := checkServedChain(server, tlsCert, tlsKey, c.String("tlsCaBundle"), c.Bool("tlsFetchIntermediates")); nil != err {
+			log.Println("WARNING:", err)
+		}
+	} else if c.Bool("tlsFetchIntermediates") {
+		log.Fatalln("--tlsFetchIntermediates requires TLS")
+	}
+	if names := c.StringSlice("tlsAllowedSni"); len(names) > 0 {
+		if err := checkAllowedSni(names, publicUrl); nil != err {
+			fmt.Println("--tlsAllowedSni:", err)
+			return
+		}
+		server.AllowSNI(names)
+	}
+	if c.Bool("tlsDebug") {
+		server.EnableTLSDebug()
+	}
+	for _, profile := range profiles {
+		server.HandleProfile(profile.Name, profile.Path)
+	}
+	if c.Bool("enableLegacyReseed") {
+		server.HandleLegacy()
+	}
+	if nil != submitted {
+		server.HandleSubmissions(submitted, c.String("routerInfoSubmitToken"), c.Int("routerInfoSubmitLimit"))
+	}
+	if serverHeader := c.String("serverHeader"); "" != serverHeader {
+		server.Headers["Server"] = serverHeader
+	}
+	for k, v := range headers {
+		if "" == v {
+			delete(server.Headers, http.CanonicalHeaderKey(k))
+		} else {
+			server.Headers[http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	// serve the signing certificate
+	if nil != signerCert {
+		server.SetSignerCertificate(signerCert.Raw)
+	}
+
+	// publish a CRL and take revocations without a restart
+	if crlPath := c.String("crlPath"); "" != crlPath {
+		if nil == signerCert {
+			log.Fatalln("--crlPath requires the signing certificate to issue the CRL")
+		}
+		revocations := c.String("revocations")
+		if "" == revocations {
+			revocations = signerFile(signerId) + ".revoked"
+		}
+		issuer := &reseed.CRLIssuer{
+			Store:    &reseed.RevocationStore{Path: revocations},
+			Cert:     signerCert,
+			Key:      privKey,
+			Validity: certOpts.crlValidity,
+			Base:     signerFile(signerId),
+		}
+		if err := server.HandleCRL(crlPath, issuer); nil != err {
+			log.Fatalln("--crlPath:", err)
+		}
+		log.Printf("Publishing the CRL at %s, revocations are kept in %s\n", crlPath, revocations)
+	}
+
+	// protect the operator endpoints
+	server.AdminAuth.Token = c.String("adminAuthToken")
+	if basicAuth := c.String("adminBasicAuth"); "" != basicAuth {
+		server.AdminAuth.User, server.AdminAuth.PassHash, err = reseed.ParseBasicAuth(basicAuth)
+		if nil != err {
+			log.Fatalln(err)
+		}
+	}
+
+	// the operator endpoints may use their own TLS certificate
+	server.AdminCertFile, server.AdminKeyFile = c.String("adminTlsCert"), c.String("adminTlsKey")
+	if (server.AdminCertFile == "") != (server.AdminKeyFile == "") {
+		log.Fatalln("--adminTlsCert and --adminTlsKey must be used together")
+	}
+	if server.AdminCertFile != "" && c.String("adminListen") == "" {
+		log.Fatalln("--adminTlsCert requires --adminListen")
+	}
+
+	// ban abusive clients for a while
+	if banThreshold := c.Int("banThreshold"); banThreshold > 0 {
+		if c.Int("banMaxEntries") <= 0 || c.Duration("banTime") <= 0 || c.Duration("banMaxTime") < c.Duration("banTime") {
+			log.Fatalln("--banMaxEntries and --banTime must be positive and --banMaxTime at least --banTime")
+		}
+		server.Banlist = reseed.NewBanlist(banThreshold, c.Duration("banWindow"), c.Duration("banTime"), c.Duration("banMaxTime"), c.Int("banMaxEntries"))
+	}
 
 	// load a blacklist
 	blacklist := reseed.NewBlacklist()
@@ -168,6 +893,61 @@ func reseedAction(c *cli.Context) {
 		blacklist.LoadFile(blacklistFile)
 	}
 
+	// keep an eye on the certificates
+	certs := []expiringCert{{
+		name:     "signing",
+		certFile: signerFile(signerId) + ".crt",
+		renew: func() error {
+			key, err := loadPrivateKey(signerKey)
+			if nil != err {
+				return err
+			}
+			cert, err := saveSigningCertificate(signerId, certOpts, key, signerFile(signerId)+".crt", signerKey)
+			if nil != err {
+				return err
+			}
+			server.SetSignerCertificate(cert)
+			return nil
+		},
+	}}
+	if tlsCert != "" && tlsKey != "" {
+		certs = append(certs, expiringCert{
+			name:     "TLS",
+			certFile: tlsCert,
+			renew: func() error {
+				if tlsCert == tlsKey {
+					return fmt.Errorf("Certificates in a --tlsBundle are not renewed")
+				}
+				hosts, err := tlsHosts(tlsHost)
+				if nil != err {
+					return err
+				}
+				var priv *ecdsa.PrivateKey
+				if c.Bool("autoRenewTlsKey") {
+					priv, err = certOpts.tlsKey()
+				} else {
+					priv, err = loadTLSPrivateKey(tlsKey)
+				}
+				if nil != err {
+					return err
+				}
+				if _, err := saveTLSCertificate(hosts, certOpts, priv, tlsCert, tlsKey); nil != err {
+					return err
+				}
+				return server.ReloadCertificate()
+			},
+		})
+	}
+	if maxBundleAge := c.Duration("maxBundleAge"); maxBundleAge > 0 {
+		server.MaxBundleAge = maxBundleAge
+		server.RefuseStaleBundle = c.Bool("refuseStaleBundle")
+		go watchBundleAge(reseeder, maxBundleAge, webhook)
+	} else if c.Bool("refuseStaleBundle") {
+		log.Fatalln("--refuseStaleBundle requires --maxBundleAge")
+	}
+
+	watchCertExpiry(certs, c.Duration("certExpiryWarn"), c.Duration("certCheckInterval"), c.Bool("autoRenew"), webhook)
+
 	// print stats once in a while
 	if c.Duration("stats") != 0 {
 		go func() {
@@ -179,7 +959,91 @@ func reseedAction(c *cli.Context) {
 		}()
 	}
 
-	if tlsHost != "" && tlsCert != "" && tlsKey != "" {
+	listeners, err := parseListeners(c.StringSlice("listen"))
+	if nil != err {
+		log.Fatalln("--listen:", err)
+	}
+	if adminListen := c.String("adminListen"); "" != adminListen {
+		listeners = append(listeners, reseed.Listener{Handlers: reseed.HANDLERS_ADMIN, Addr: adminListen, CertFile: server.AdminCertFile, KeyFile: server.AdminKeyFile})
+	}
+	pprofServed := false
+	for i := range listeners {
+		if listeners[i].Handlers == reseed.HANDLERS_ADMIN {
+			listeners[i].Addr = reseed.AdminListenAddr(listeners[i].Addr)
+			listeners[i].Pprof = c.Bool("pprof")
+			pprofServed = true
+		}
+	}
+	if c.Bool("pprof") && !pprofServed {
+		log.Fatalln("--pprof requires --adminListen or an admin --listen, profiling is never served on the public listener")
+	}
+	for _, l := range listeners {
+		go func(l reseed.Listener) {
+			if l.CertFile != "" {
+				log.Printf("Server for the %s handlers started on %s with TLS\n", l.Handlers, l.Addr)
+			} else {
+				log.Printf("Server for the %s handlers started on %s\n", l.Handlers, l.Addr)
+			}
+			log.Fatalln(server.ListenAndServeListener(l))
+		}(l)
+	}
+
+	// apply the options that can change while running on SIGHUP
+	if nil != reloader {
+		reloader.reseeder, reloader.server = reseeder, server
+	}
+	go reloadOnHangup(reloader)
+
+	// let the webhook receiver know we are going away and remove unix sockets
+	go func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+		sig := <-sigs
+		if nil != webhook {
+			if err := webhook.Send(reseed.EVENT_SHUTDOWN, map[string]interface{}{"signal": sig.String()}); nil != err {
+				log.Println(err)
+			}
+		}
+		server.Close()
+		os.Exit(0)
+	}()
+
+	if listenHttp := c.String("listenHttp"); "" != listenHttp {
+		go func() {
+			log.Printf("Plain HTTP server started on %s, TLS MUST be terminated by the proxy in front of it\n", listenHttp)
+			log.Fatalln(server.ListenAndServeProxied(listenHttp))
+		}()
+	}
+
+	// fetch our own su3 once the server is up
+	if c.Bool("selfCheck") {
+		go func() {
+			var pub crypto.PublicKey = &privKey.PublicKey
+			if nil != signerCert {
+				pub = signerCert.PublicKey
+			}
+			var servedCert *x509.Certificate
+			if tlsCert != "" && tlsKey != "" {
+				cert, err := loadCertificate(tlsCert)
+				if nil != err {
+					log.Fatalln(err)
+				}
+				servedCert = cert
+			}
+
+			su3Path := c.String("prefix") + c.String("su3Path")
+			if err := selfCheck(server.Addr, su3Path, servedCert, pub); nil != err {
+				if c.Bool("selfCheckStrict") {
+					log.Fatalln("Self-check failed:", err)
+				}
+				log.Println("WARNING: self-check failed:", err)
+				return
+			}
+			log.Println("Self-check passed, the served su3 file verifies.")
+		}()
+	}
+
+	if tlsCert != "" && tlsKey != "" {
 		log.Printf("HTTPS server started on %s\n", server.Addr)
 		log.Fatalln(server.ListenAndServeTLS(tlsCert, tlsKey))
 	} else {
@@ -187,3 +1051,144 @@ func reseedAction(c *cli.Context) {
 		log.Fatalln(server.ListenAndServe())
 	}
 }
+
+// checkExpectedSigner returns an error unless the signing certificate and
+// --signer are both for expectedSigner
+func checkExpectedSigner(signerCert *x509.Certificate, signerId, expectedSigner string) error {
+	if nil == signerCert {
+		return fmt.Errorf("--expectedSigner: no signing certificate matching the key to check")
+	}
+	if signerCert.Subject.CommonName != expectedSigner {
+		return fmt.Errorf("--expectedSigner: the signing certificate is for '%s', not '%s'", signerCert.Subject.CommonName, expectedSigner)
+	}
+	if signerId != expectedSigner {
+		return fmt.Errorf("--expectedSigner: --signer is '%s', not '%s'", signerId, expectedSigner)
+	}
+
+	return nil
+}
+
+// parseListeners reads --listen specs: comma separated key=value pairs with
+// handlers, addr and optionally cert and key
+func parseListeners(specs []string) ([]reseed.Listener, error) {
+	var listeners []reseed.Listener
+	for _, spec := range specs {
+		var l reseed.Listener
+		for _, pair := range strings.Split(spec, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("'%s' is not a key=value pair", pair)
+			}
+			switch kv[0] {
+			case "handlers":
+				l.Handlers = kv[1]
+			case "addr":
+				l.Addr = kv[1]
+			case "cert":
+				l.CertFile = kv[1]
+			case "key":
+				l.KeyFile = kv[1]
+			default:
+				return nil, fmt.Errorf("Unknown listener option '%s'", kv[0])
+			}
+		}
+
+		if l.Handlers != reseed.HANDLERS_PUBLIC && l.Handlers != reseed.HANDLERS_ADMIN {
+			return nil, fmt.Errorf("'%s': handlers must be '%s' or '%s'", spec, reseed.HANDLERS_PUBLIC, reseed.HANDLERS_ADMIN)
+		}
+		if l.Addr == "" {
+			return nil, fmt.Errorf("'%s': addr is required", spec)
+		}
+		if (l.CertFile == "") != (l.KeyFile == "") {
+			return nil, fmt.Errorf("'%s': cert and key must be used together", spec)
+		}
+		listeners = append(listeners, l)
+	}
+
+	return listeners, nil
+}
+
+// defaultPublicUrl guesses the public URL from the first TLS host or the
+// listen address
+func defaultPublicUrl(tlsHost string, useTLS bool, ip, port, prefix string) string {
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+
+	host := ip
+	if hosts, err := tlsHosts(tlsHost); tlsHost != "" && nil == err {
+		host = hosts[0]
+	} else if parsed := net.ParseIP(ip); nil != parsed && parsed.IsUnspecified() {
+		if hostname, err := os.Hostname(); nil == err {
+			host = hostname
+		}
+	}
+
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+
+	return scheme + "://" + reseed.URLHost(host, port) + prefix
+}
+
+// setNotFound configures the answer of server to unknown paths and scanners
+func setNotFound(server *reseed.Server, c *cli.Context) error {
+	status := c.Int("notFoundStatus")
+	if status < 200 || status > 599 || (status >= 300 && status < 400) {
+		return fmt.Errorf("--notFoundStatus must be a 2xx, 4xx or 5xx status, use --notFoundRedirect to redirect")
+	}
+	redirect := c.String("notFoundRedirect")
+	if redirect != "" && c.String("notFoundPage") != "" {
+		return fmt.Errorf("--notFoundRedirect and --notFoundPage can't be used together")
+	}
+	if _, err := url.Parse(redirect); nil != err {
+		return fmt.Errorf("--notFoundRedirect: %w", err)
+	}
+	if len(c.StringSlice("scannerPath")) > 0 && !c.Bool("closeScannerPaths") {
+		return fmt.Errorf("--scannerPath requires --closeScannerPaths")
+	}
+	for _, pattern := range c.StringSlice("scannerPath") {
+		if _, err := path.Match(pattern, ""); nil != err || !strings.HasPrefix(pattern, "/") {
+			return fmt.Errorf("--scannerPath: '%s' is not a path pattern", pattern)
+		}
+	}
+
+	if page := c.String("notFoundPage"); page != "" {
+		body, err := ioutil.ReadFile(page)
+		if nil != err {
+			return fmt.Errorf("--notFoundPage: %w", err)
+		}
+		server.NotFoundBody = body
+		server.NotFoundContentType = mime.TypeByExtension(filepath.Ext(page))
+	}
+	server.NotFoundStatus = status
+	server.NotFoundRedirect = redirect
+	if c.Bool("closeScannerPaths") {
+		server.ScannerPaths = append(append([]string{}, reseed.DEFAULT_SCANNER_PATHS...), c.StringSlice("scannerPath")...)
+	}
+
+	return nil
+}
+
+// checkAllowedSni checks that names are host names, SNI never carries an IP
+// address, and that routers reach publicUrl with one of them
+func checkAllowedSni(names []string, publicUrl string) error {
+	allowed := make(map[string]bool)
+	for _, name := range names {
+		if name == "" || nil != net.ParseIP(name) || strings.ContainsAny(name, ":/ ") {
+			return fmt.Errorf("'%s' is not a host name", name)
+		}
+		allowed[reseed.NormalizeSNI(name)] = true
+	}
+
+	u, err := url.Parse(publicUrl)
+	if nil != err {
+		return err
+	}
+	if u.Scheme == "https" && !allowed[reseed.NormalizeSNI(u.Hostname())] {
+		return fmt.Errorf("the --publicUrl host '%s' is not allowed, routers would be rejected", u.Hostname())
+	}
+
+	return nil
+}
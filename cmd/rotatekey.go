@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/codegangsta/cli"
+)
+
+func NewRotateKeyCommand() cli.Command {
+	return cli.Command{
+		Name:   "rotate-key",
+		Usage:  "Generate a new su3 signing key, archiving the current key and certificate",
+		Action: rotateKeyAction,
+		Flags: append([]cli.Flag{
+			cli.StringFlag{
+				Name:  "signer",
+				Usage: "Your su3 signing ID (ex. something@mail.i2p)",
+			},
+			cli.StringFlag{
+				Name:  "key",
+				Usage: "Path to your active su3 signing private key",
+			},
+			cli.StringFlag{
+				Name:  "archive",
+				Value: "archive",
+				Usage: "Directory the previous key, certificate and crl are moved to",
+			},
+		}, certFlags()...),
+	}
+}
+
+func rotateKeyAction(c *cli.Context) {
+	signerId := c.String("signer")
+	if signerId == "" {
+		fmt.Println("--signer is required")
+		return
+	}
+
+	signerKey := c.String("key")
+	if signerKey == "" {
+		signerKey = signerFile(signerId) + ".pem"
+	}
+
+	opts, err := newCertOptions(c)
+	if nil != err {
+		fmt.Println(err)
+		return
+	}
+
+	if err := rotateSigningKey(signerId, signerKey, c.String("archive"), opts); nil != err {
+		fmt.Println(err)
+		return
+	}
+}
+
+func rotateSigningKey(signerId, signerKey, archiveDir string, opts *certOptions) error {
+	if _, err := os.Stat(signerKey); nil != err {
+		return fmt.Errorf("Unable to read signing key '%s': %s", signerKey, err)
+	}
+
+	base := signerFile(signerId)
+	certFile := base + ".crt"
+	crlFile := base + ".crl"
+
+	// the new files are written aside first, the current ones are only
+	// archived once the new key, certificate and crl are complete
+	staging, err := ioutil.TempDir(".", base+".rotate-")
+	if nil != err {
+		return err
+	}
+	keepStaging := false
+	defer func() {
+		if !keepStaging {
+			os.RemoveAll(staging)
+		}
+	}()
+	staged := filepath.Join(staging, base)
+
+	var newKey *rsa.PrivateKey
+	if opts.reuseKey {
+		fmt.Fprintln(os.Stderr, "Issuing a new signing certificate for the key in", signerKey)
+		newKey, err = loadPrivateKey(signerKey)
+	} else {
+		fmt.Fprintf(os.Stderr, "Generating a %d bit RSA signing key. This may take a minute...\n", opts.rsaBits)
+		newKey, err = opts.signingKey()
+	}
+	if nil != err {
+		return err
+	}
+	newCert, err := saveSigningCertificate(signerId, opts, newKey, staged+".crt", staged+".pem")
+	if nil != err {
+		return err
+	}
+	if opts.crl {
+		// continue the crl numbers of the current key
+		if number, err := ioutil.ReadFile(base + ".crlnumber"); nil == err {
+			if err := ioutil.WriteFile(staged+".crlnumber", number, 0600); nil != err {
+				return err
+			}
+		}
+		if err := saveCRL(staged, newCert, newKey, opts.crlValidity); nil != err {
+			return err
+		}
+	}
+
+	// move the current files into a timestamped archive directory
+	dir := filepath.Join(archiveDir, base+"-"+time.Now().UTC().Format("20060102T150405Z"))
+	if err := os.MkdirAll(dir, 0700); nil != err {
+		return err
+	}
+
+	for _, file := range []string{signerKey, certFile, crlFile} {
+		if _, err := os.Stat(file); nil != err {
+			continue
+		}
+		if err := os.Rename(file, filepath.Join(dir, filepath.Base(file))); nil != err {
+			return err
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Previous signing key archived to:", dir)
+
+	// keep all previous certificates around so older su3 files can still be verified
+	if oldCert, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(certFile))); nil == err {
+		historyFile := base + ".history.crt"
+		history, err := os.OpenFile(historyFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open %s for writing: %s", historyFile, err)
+		}
+		_, err = history.Write(oldCert)
+		history.Close()
+		if nil != err {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Previous signing certificate appended to:", historyFile)
+	}
+
+	moves := [][2]string{{staged + ".pem", signerKey}, {staged + ".crt", certFile}}
+	if opts.crl {
+		moves = append(moves, [2]string{staged + ".crl", crlFile}, [2]string{staged + ".crlnumber", base + ".crlnumber"})
+	}
+	for _, move := range moves {
+		if err := os.Rename(move[0], move[1]); nil != err {
+			keepStaging = true
+			return fmt.Errorf("%s, the new files are still in %s and the previous ones in %s", err, staging, dir)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "\tSigning private key moved to:", signerKey)
+	fmt.Fprintln(os.Stderr, "\tSigning certificate moved to:", certFile)
+
+	return nil
+}
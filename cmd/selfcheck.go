@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+// selfCheck downloads an su3 file from our own server like a router would and
+// verifies its signature with pub. If tlsCert is set, the server must present
+// exactly that certificate.
+func selfCheck(addr, path string, tlsCert *x509.Certificate, pub crypto.PublicKey) error {
+	host, port, err := net.SplitHostPort(addr)
+	if nil != err {
+		return err
+	}
+	// a wildcard listener is reachable on loopback
+	if ip := net.ParseIP(host); host == "" || (nil != ip && ip.IsUnspecified()) {
+		if nil != ip && nil == ip.To4() {
+			host = "::1"
+		} else {
+			host = "127.0.0.1"
+		}
+	}
+
+	transport := &http.Transport{}
+	url := "http://" + reseed.URLHost(host, port) + path
+	if nil != tlsCert {
+		url = "https://" + reseed.URLHost(host, port) + path
+		transport.TLSClientConfig = &tls.Config{
+			// the hostname doesn't match on loopback, compare the certificate instead
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], tlsCert.Raw) {
+					return fmt.Errorf("the server presented a different TLS certificate")
+				}
+				return nil
+			},
+		}
+	}
+	client := &http.Client{Transport: transport, Timeout: 30 * time.Second}
+
+	req, err := http.NewRequest("GET", url, nil)
+	if nil != err {
+		return err
+	}
+	req.Header.Set("User-Agent", reseed.I2P_USER_AGENT)
+
+	// the server is started after us, give it a moment
+	var resp *http.Response
+	for i := 0; i < 10; i++ {
+		if resp, err = client.Do(req); nil == err {
+			break
+		}
+		time.Sleep(time.Second)
+	}
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	su3File, err := su3.Verify(resp.Body, pub)
+	if nil != err {
+		return fmt.Errorf("GET %s: %s", url, err)
+	}
+
+	if su3File.ContentType != su3.CONTENT_TYPE_RESEED || su3File.FileType != su3.FILE_TYPE_ZIP {
+		return fmt.Errorf("unexpected su3 content type %d, file type %d", su3File.ContentType, su3File.FileType)
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/internal/testutil"
+	"github.com/martin61/i2p-tools/reseed"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func NewTestdataCommand() cli.Command {
+	return cli.Command{
+		Name:   "testdata",
+		Usage:  "Generate a synthetic netDb and a signed su3 for tests",
+		Action: testdataAction,
+		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "out",
+				Value: "testdata",
+				Usage: "Directory to write the test data to",
+			},
+			cli.StringFlag{
+				Name:  "signer",
+				Value: "testdata@mail.i2p",
+				Usage: "su3 signing ID of the test signer",
+			},
+			cli.IntFlag{
+				Name:  "numRi",
+				Value: 10,
+				Usage: "Number of routerInfos to generate",
+			},
+			cli.BoolFlag{
+				Name:  "insecureDeterministic",
+				Usage: "Derive the signing key from a fixed, public seed so the output is reproducible byte-for-byte. NEVER use this key for a real reseed.",
+			},
+		},
+	}
+}
+
+func testdataAction(c *cli.Context) {
+	if err := createTestdata(c.String("out"), c.String("signer"), c.Int("numRi"), c.Bool("insecureDeterministic")); nil != err {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+}
+
+func createTestdata(out, signerId string, numRi int, deterministic bool) error {
+	// netDb
+	netdbDir := filepath.Join(out, "netDb")
+	seeds, err := testutil.WriteNetDb(netdbDir, numRi, testutil.NetDbOptions{})
+	if nil != err {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "\t%d routerInfos saved to: %s\n", len(seeds), netdbDir)
+
+	// signer
+	fmt.Fprintln(os.Stderr, "Generating signing keys. This may take a minute...")
+	var signerKey *rsa.PrivateKey
+	if deterministic {
+		fmt.Fprintln(os.Stderr, "WARNING: the signing key is derived from a public seed and is NOT secret")
+		signerKey, err = insecureRSAKey(newInsecureReader(testutil.KEY_SEED), 4096)
+	} else {
+		signerKey, err = rsa.GenerateKey(rand.Reader, 4096)
+	}
+	if nil != err {
+		return err
+	}
+
+	signerCert, err := su3.CreateSigningCertificate(su3.SigningCertificateTemplate(signerId, su3.DefaultSubject(), big.NewInt(1), testutil.Time), signerKey)
+	if nil != err {
+		return err
+	}
+
+	// where the verify command looks for it when run from the out dir
+	certFile := filepath.Join(out, "certificates", "reseed", reseed.SignerFilename(signerId))
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); nil != err {
+		return err
+	}
+	if err := reseed.WriteFileAtomic(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signerCert}), 0644); nil != err {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "\tSigning certificate saved to:", certFile)
+
+	privFile := filepath.Join(out, signerFile(signerId)+".pem")
+	privPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(signerKey)})
+	if err := reseed.WriteFileAtomic(privFile, privPem, 0600); nil != err {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "\tSigning private key saved to:", privFile)
+
+	// su3
+	zipped, err := zipTestdata(seeds)
+	if nil != err {
+		return err
+	}
+
+	su3File := su3.NewSu3File()
+	su3File.Version = []byte(strconv.FormatInt(testutil.Time.Unix(), 10))
+	su3File.FileType = su3.FILE_TYPE_ZIP
+	su3File.ContentType = su3.CONTENT_TYPE_RESEED
+	su3File.SignerId = []byte(signerId)
+	su3File.Content = zipped
+	if err := su3File.Sign(signerKey); nil != err {
+		return err
+	}
+
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		return err
+	}
+	su3Path := filepath.Join(out, "i2pseeds.su3")
+	if err := reseed.WriteFileAtomic(su3Path, data, 0644); nil != err {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "\tSigned su3 saved to:", su3Path)
+
+	return nil
+}
+
+// zipTestdata zips the seeds sorted by name
+func zipTestdata(seeds []testutil.Router) ([]byte, error) {
+	sort.Slice(seeds, func(i, j int) bool { return seeds[i].Name < seeds[j].Name })
+
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	for _, seed := range seeds {
+		fileHeader := &zip.FileHeader{Name: seed.Name, Method: zip.Deflate}
+		fileHeader.SetModTime(seed.Published)
+		zipFile, err := zipWriter.CreateHeader(fileHeader)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zipFile.Write(seed.Data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+const (
+	// intermediates fetched from AIA URLs before giving up
+	MAX_FETCHED_INTERMEDIATES = 4
+)
+
+var errIncompleteChain = errors.New("the TLS certificate chain doesn't build to a trusted root, an intermediate certificate is probably missing")
+
+// verifyTLSChain checks that the chain of cert, plus the extra intermediates,
+// builds to one of roots, the system roots if nil. Self-signed certificates
+// are pinned by routers and not checked.
+func verifyTLSChain(cert tls.Certificate, extra [][]byte, roots *x509.CertPool) error {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if nil != err {
+		return err
+	}
+	if isSelfSigned(leaf) {
+		return nil
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, der := range append(append([][]byte{}, cert.Certificate[1:]...), extra...) {
+		c, err := x509.ParseCertificate(der)
+		if nil != err {
+			return err
+		}
+		intermediates.AddCert(c)
+	}
+
+	_, err = leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	var unknownAuthority x509.UnknownAuthorityError
+	if errors.As(err, &unknownAuthority) {
+		return fmt.Errorf("%w: %s", errIncompleteChain, err)
+	}
+
+	return err
+}
+
+// fetchIntermediates downloads the issuers of the chain of cert from the AIA
+// URLs in the certificates until it builds to one of roots, and returns them
+// DER encoded
+func fetchIntermediates(cert tls.Certificate, roots *x509.CertPool) ([][]byte, error) {
+	last, err := x509.ParseCertificate(cert.Certificate[len(cert.Certificate)-1])
+	if nil != err {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	var fetched [][]byte
+	for len(fetched) < MAX_FETCHED_INTERMEDIATES {
+		if nil == verifyTLSChain(cert, fetched, roots) {
+			return fetched, nil
+		}
+		if len(last.IssuingCertificateURL) == 0 {
+			return nil, fmt.Errorf("'%s' has no AIA URL of its issuer", last.Subject)
+		}
+
+		var issuer *x509.Certificate
+		for _, url := range last.IssuingCertificateURL {
+			if issuer, err = fetchCertificate(client, url); nil == err {
+				break
+			}
+			log.Printf("Unable to fetch the issuer of '%s' from %s: %s\n", last.Subject, url, err)
+		}
+		if nil == issuer {
+			return nil, fmt.Errorf("no issuer of '%s' could be fetched", last.Subject)
+		}
+		if isSelfSigned(issuer) {
+			return nil, fmt.Errorf("the root '%s' is not trusted", issuer.Subject)
+		}
+
+		fetched = append(fetched, issuer.Raw)
+		last = issuer
+	}
+
+	return nil, fmt.Errorf("the chain doesn't verify after fetching %d intermediates", len(fetched))
+}
+
+// fetchCertificate downloads a DER or PEM encoded certificate
+func fetchCertificate(client *http.Client, url string) (*x509.Certificate, error) {
+	resp, err := client.Get(url)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if nil != err {
+		return nil, err
+	}
+	if block, _ := pem.Decode(data); nil != block && block.Type == "CERTIFICATE" {
+		data = block.Bytes
+	} else if bytes.HasPrefix(data, []byte("-----")) {
+		return nil, fmt.Errorf("no CERTIFICATE found")
+	}
+
+	return x509.ParseCertificate(data)
+}
+
+// checkServedChain warns if the chain of the TLS certificate doesn't build
+// to a trusted root and with fetch has the server send the intermediates
+// missing from it
+func checkServedChain(server *reseed.Server, certFile, keyFile, caBundle string, fetch bool) error {
+	cert, err := reseed.LoadKeyPair(certFile, keyFile)
+	if nil != err {
+		return err
+	}
+	var roots *x509.CertPool
+	if caBundle != "" {
+		if roots, err = loadCertPool(caBundle); nil != err {
+			return fmt.Errorf("--tlsCaBundle: %w", err)
+		}
+	}
+
+	err = verifyTLSChain(cert, nil, roots)
+	if nil == err {
+		return nil
+	}
+	if !errors.Is(err, errIncompleteChain) {
+		return fmt.Errorf("the TLS certificate doesn't verify: %w", err)
+	}
+	if !fetch {
+		return fmt.Errorf("%w, add it to %s or use --tlsFetchIntermediates", err, certFile)
+	}
+
+	intermediates, fetchErr := fetchIntermediates(cert, roots)
+	if nil != fetchErr {
+		return fmt.Errorf("%w, and it can't be completed: %s", err, fetchErr)
+	}
+	server.Intermediates = intermediates
+	log.Printf("Serving %d intermediate certificates fetched from AIA URLs with the TLS certificate\n", len(intermediates))
+
+	return nil
+}
@@ -1,10 +1,12 @@
 package cmd
 
 import (
-	"bufio"
+	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"crypto/x509/pkix"
 	"crypto/elliptic"
 	"crypto/ecdsa"
 	"encoding/asn1"
@@ -12,14 +14,175 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
-	"crypto/x509/pkix"
 
+	"github.com/codegangsta/cli"
 	"github.com/martin61/i2p-tools/reseed"
 	"github.com/martin61/i2p-tools/su3"
 )
 
+const (
+	// how long generated CRLs stay valid
+	DEFAULT_CRL_VALIDITY = 7 * 24 * time.Hour
+
+	// size of generated su3 signing keys, su3 files can be signed with
+	// 2048, 3072 or 4096 bit RSA keys
+	DEFAULT_SIGNER_RSA_BITS = 4096
+
+	// as --out writes to stdout instead of a file
+	STDOUT = "-"
+)
+
+// certOptions are the settings of newly generated certificates
+type certOptions struct {
+	// also generate a CRL for new certificates
+	crl         bool
+	crlValidity time.Duration
+	subject     pkix.Name
+	// derive keys from this seed instead of crypto/rand, for tests only
+	keySeed string
+	// size of new signing keys
+	rsaBits int
+	// issue new certificates for the existing keys instead of generating keys
+	reuseKey bool
+}
+
+// certFlags are shared by all commands that generate certificates
+func certFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.BoolFlag{
+			Name:  "crl",
+			Usage: "Also generate a CRL for newly generated certificates, only useful if you publish CRLs for your own CA",
+		},
+		cli.BoolFlag{
+			Name:  "noCrl",
+			Usage: "Don't generate CRLs for newly generated certificates (the default)",
+		},
+		cli.DurationFlag{
+			Name:  "crlValidity",
+			Value: DEFAULT_CRL_VALIDITY,
+			Usage: "How long newly generated CRLs stay valid (their nextUpdate)",
+		},
+		cli.StringFlag{
+			Name:  "certOrg",
+			Usage: "Organization of newly generated certificates",
+		},
+		cli.StringFlag{
+			Name:  "certOu",
+			Usage: "Organizational unit of newly generated certificates",
+		},
+		cli.StringFlag{
+			Name:  "certCountry",
+			Usage: "Two letter country code of newly generated certificates",
+		},
+		cli.IntFlag{
+			Name:  "signerRsaBits",
+			Value: DEFAULT_SIGNER_RSA_BITS,
+			Usage: "Size of newly generated su3 signing keys: 2048, 3072 or 4096 bits",
+		},
+		cli.StringFlag{
+			Name:  "insecureKeySeed",
+			Usage: "Derive newly generated keys from this seed so tests can regenerate the same keys. INSECURE, anyone knowing the seed has the keys, NEVER use it for a real reseed.",
+		},
+	}
+}
+
+var countryRegexp = regexp.MustCompile("^[A-Za-z]{2}$")
+
+// newCertOptions reads the certFlags. Without any of --certOrg, --certOu and
+// --certCountry certificates keep the subject of earlier versions, otherwise
+// only the given fields are set.
+func newCertOptions(c *cli.Context) (*certOptions, error) {
+	opts := &certOptions{crl: c.Bool("crl"), crlValidity: c.Duration("crlValidity"), keySeed: c.String("insecureKeySeed"), rsaBits: c.Int("signerRsaBits")}
+	if opts.crl && c.Bool("noCrl") {
+		return nil, fmt.Errorf("%w: --crl and --noCrl can't be used together", ErrInvalidFlag)
+	}
+	// each size has its own su3 signature type, there is none for other sizes
+	if opts.rsaBits != 2048 && opts.rsaBits != 3072 && opts.rsaBits != 4096 {
+		return nil, fmt.Errorf("%w: --signerRsaBits must be 2048, 3072 or 4096, not %d", ErrInvalidFlag, opts.rsaBits)
+	}
+	if opts.crlValidity <= 0 {
+		return nil, fmt.Errorf("%w: --crlValidity must be positive", ErrInvalidFlag)
+	}
+
+	org, ou, country := c.String("certOrg"), c.String("certOu"), c.String("certCountry")
+	if org == "" && ou == "" && country == "" {
+		opts.subject = su3.DefaultSubject()
+		return opts, nil
+	}
+
+	if org != "" {
+		opts.subject.Organization = []string{org}
+	}
+	if ou != "" {
+		opts.subject.OrganizationalUnit = []string{ou}
+	}
+	if country != "" {
+		if !countryRegexp.MatchString(country) {
+			return nil, fmt.Errorf("%w: --certCountry must be a two letter country code, not '%s'", ErrInvalidFlag, country)
+		}
+		opts.subject.Country = []string{strings.ToUpper(country)}
+	}
+
+	return opts, nil
+}
+
+// signingKey generates a new su3 signing key
+func (opts *certOptions) signingKey() (*rsa.PrivateKey, error) {
+	if opts.keySeed == "" {
+		return rsa.GenerateKey(rand.Reader, opts.rsaBits)
+	}
+
+	fmt.Fprintln(os.Stderr, "WARNING: the signing key is derived from --insecureKeySeed and is NOT secret")
+	return insecureRSAKey(newInsecureReader("signing:"+opts.keySeed), opts.rsaBits)
+}
+
+// signingCertificate issues a self-signed su3 signing certificate for key.
+// With a seed its serial number and dates are derived from the seed too.
+func (opts *certOptions) signingCertificate(signerId string, key *rsa.PrivateKey) ([]byte, error) {
+	if opts.keySeed == "" {
+		return su3.NewSigningCertificate(signerId, opts.subject, key)
+	}
+
+	serialNumber, notBefore, err := insecureCertSerial(newInsecureReader("signing-cert:" + opts.keySeed))
+	if nil != err {
+		return nil, err
+	}
+	// PKCS #1 v1.5 signatures don't use randomness
+	return su3.CreateSigningCertificate(su3.SigningCertificateTemplate(signerId, opts.subject, serialNumber, notBefore), key)
+}
+
+// tlsCertificate issues a self-signed TLS certificate for priv. With a seed
+// its serial number, dates and signature are derived from the seed too.
+func (opts *certOptions) tlsCertificate(hosts []string, priv *ecdsa.PrivateKey) ([]byte, error) {
+	if opts.keySeed == "" {
+		return reseed.NewTLSCertificate(hosts, opts.subject, priv)
+	}
+
+	serialNumber, notBefore, err := insecureCertSerial(newInsecureReader("tls-cert:" + opts.keySeed))
+	if nil != err {
+		return nil, err
+	}
+	template, err := reseed.TLSCertificateTemplate(hosts, opts.subject, serialNumber, notBefore)
+	if nil != err {
+		return nil, err
+	}
+	return x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, insecureECDSASigner{priv})
+}
+
+// tlsKey generates a new TLS key
+func (opts *certOptions) tlsKey() (*ecdsa.PrivateKey, error) {
+	if opts.keySeed == "" {
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	}
+
+	fmt.Fprintln(os.Stderr, "WARNING: the TLS key is derived from --insecureKeySeed and is NOT secret")
+	return insecureECDSAKey(newInsecureReader("tls:"+opts.keySeed), elliptic.P384())
+}
+
 func loadPrivateKey(path string) (*rsa.PrivateKey, error) {
 	privPem, err := ioutil.ReadFile(path)
 	if nil != err {
@@ -39,16 +202,62 @@ func signerFile(signerId string) string {
 	return strings.Replace(signerId, "@", "_at_", 1)
 }
 
-func getOrNewSigningCert(signerKey *string, signerId string) (*rsa.PrivateKey, error) {
+// tlsHosts returns the hostnames and IPs for a --tlsHost value, which is either
+// a comma separated list or @file containing one host per line
+func tlsHosts(tlsHost string) ([]string, error) {
+	var hosts []string
+	if strings.HasPrefix(tlsHost, "@") {
+		content, err := ioutil.ReadFile(tlsHost[1:])
+		if nil != err {
+			return nil, err
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				hosts = append(hosts, line)
+			}
+		}
+	} else {
+		for _, h := range strings.Split(tlsHost, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("No TLS hosts found in '%s'", tlsHost)
+	}
+	for _, h := range hosts {
+		if !reseed.ValidTLSHost(h) {
+			return nil, fmt.Errorf("'%s' is not a valid hostname or IP address", h)
+		}
+	}
+
+	return hosts, nil
+}
+
+// tlsFile returns the base name of the TLS key, certificate and crl files.
+// For @file hosts this is the name of the file without its extension.
+func tlsFile(tlsHost string) string {
+	if strings.HasPrefix(tlsHost, "@") {
+		base := filepath.Base(tlsHost[1:])
+		return strings.TrimSuffix(base, filepath.Ext(base))
+	}
+
+	return tlsHost
+}
+
+func getOrNewSigningCert(ctx context.Context, promptTimeout time.Duration, signerKey *string, signerId string, opts *certOptions) (*rsa.PrivateKey, error) {
 	if _, err := os.Stat(*signerKey); nil != err {
-		fmt.Printf("Unable to read signing key '%s'\n", *signerKey)
-		fmt.Printf("Would you like to generate a new signing key for %s? (y or n): ", signerId)
-		reader := bufio.NewReader(os.Stdin)
-		input, _ := reader.ReadString('\n')
-		if []byte(input)[0] != 'y' {
-			return nil, fmt.Errorf("A signing key is required")
+		fmt.Fprintf(os.Stderr, "Unable to read signing key '%s'\n", *signerKey)
+		yes, err := promptYesNo(ctx, promptTimeout, fmt.Sprintf("Would you like to generate a new signing key for %s?", signerId))
+		if nil != err {
+			return nil, err
+		}
+		if !yes {
+			return nil, ErrNoSigningKey
 		} else {
-			if err := createSigningCertificate(signerId); nil != err {
+			if err := createSigningCertificate(signerId, opts); nil != err {
 				return nil, err
 			}
 
@@ -59,176 +268,281 @@ func getOrNewSigningCert(signerKey *string, signerId string) (*rsa.PrivateKey, e
 	return loadPrivateKey(*signerKey)
 }
 
-func checkOrNewTLSCert(tlsHost string, tlsCert, tlsKey *string) error {
+func checkOrNewTLSCert(ctx context.Context, promptTimeout time.Duration, tlsHost string, tlsCert, tlsKey *string, opts *certOptions) error {
 	_, certErr := os.Stat(*tlsCert)
 	_, keyErr := os.Stat(*tlsKey)
 	if certErr != nil || keyErr != nil {
 		if certErr != nil {
-			fmt.Printf("Unable to read TLS certificate '%s'\n", *tlsCert)
+			fmt.Fprintf(os.Stderr, "Unable to read TLS certificate '%s'\n", *tlsCert)
 		}
 		if keyErr != nil {
-			fmt.Printf("Unable to read TLS key '%s'\n", *tlsKey)
+			fmt.Fprintf(os.Stderr, "Unable to read TLS key '%s'\n", *tlsKey)
 		}
 
-		fmt.Printf("Would you like to generate a new self-signed certificate for '%s'? (y or n): ", tlsHost)
-		reader := bufio.NewReader(os.Stdin)
-		input, _ := reader.ReadString('\n')
-		if []byte(input)[0] != 'y' {
-			fmt.Println("Continuing without TLS")
+		yes, err := promptYesNo(ctx, promptTimeout, fmt.Sprintf("Would you like to generate a new self-signed certificate for '%s'?", tlsHost))
+		if nil != err {
+			return err
+		}
+		if !yes {
+			fmt.Fprintln(os.Stderr, "Continuing without TLS")
 			return nil
 		} else {
-			if err := createTLSCertificate(tlsHost); nil != err {
+			if err := createTLSCertificate(tlsHost, opts); nil != err {
 				return err
 			}
 
-			*tlsCert = tlsHost + ".crt"
-			*tlsKey = tlsHost + ".pem"
+			*tlsCert = tlsFile(tlsHost) + ".crt"
+			*tlsKey = tlsFile(tlsHost) + ".pem"
 		}
 	}
 
 	return nil
 }
 
-func createSigningCertificate(signerId string) error {
-	// generate private key
-	fmt.Println("Generating signing keys. This may take a minute...")
-	signerKey, err := rsa.GenerateKey(rand.Reader, 4096)
+func createSigningCertificate(signerId string, opts *certOptions) error {
+	var signerKey *rsa.PrivateKey
+	var err error
+	if opts.reuseKey {
+		fmt.Fprintln(os.Stderr, "Issuing a new signing certificate for the key in", signerFile(signerId)+".pem")
+		signerKey, err = loadPrivateKey(signerFile(signerId) + ".pem")
+	} else {
+		fmt.Fprintf(os.Stderr, "Generating a %d bit RSA signing key. This may take a minute...\n", opts.rsaBits)
+		signerKey, err = opts.signingKey()
+	}
 	if err != nil {
 		return err
 	}
 
-	signerCert, err := su3.NewSigningCertificate(signerId, signerKey)
+	signerCert, err := saveSigningCertificate(signerId, opts, signerKey, signerFile(signerId)+".crt", signerFile(signerId)+".pem")
 	if nil != err {
 		return err
 	}
 
-	// save cert
-	certFile := signerFile(signerId) + ".crt"
-	certOut, err := os.Create(certFile)
-	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s\n", certFile, err)
+	// CRL
+	if opts.crl {
+		if err := saveCRL(signerFile(signerId), signerCert, signerKey, opts.crlValidity); nil != err {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "\tSigning CRL saved to: %s\n", signerFile(signerId)+".crl")
 	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: signerCert})
-	certOut.Close()
-	fmt.Println("\tSigning certificate saved to:", certFile)
 
-	// save signing private key
-	privFile := signerFile(signerId) + ".pem"
-	keyOut, err := os.OpenFile(privFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	return nil
+}
+
+func createTLSCertificate(host string, opts *certOptions) error {
+	hosts, err := tlsHosts(host)
+	if nil != err {
+		return err
+	}
+
+	var priv *ecdsa.PrivateKey
+	if opts.reuseKey {
+		fmt.Fprintln(os.Stderr, "Issuing a new TLS certificate for the key in", tlsFile(host)+".pem")
+		priv, err = loadTLSPrivateKey(tlsFile(host) + ".pem")
+	} else {
+		fmt.Fprintln(os.Stderr, "Generating TLS keys. This may take a minute...")
+//		priv, err = rsa.GenerateKey(rand.Reader, 4096)
+		priv, err = opts.tlsKey()
+	}
 	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s\n", privFile, err)
+		return err
 	}
-	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(signerKey)})
-	pem.Encode(keyOut, &pem.Block{Type: "CERTIFICATE", Bytes: signerCert})
-	keyOut.Close()
-	fmt.Println("\tSigning private key saved to:", privFile)
 
+	tlsCert, err := saveTLSCertificate(hosts, opts, priv, tlsFile(host)+".crt", tlsFile(host)+".pem")
+	if nil != err {
+		return err
+	}
 
 	// CRL
-	crlFile := signerFile(signerId) + ".crl"
-	crlOut, err := os.OpenFile(crlFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if opts.crl {
+		if err := saveCRL(tlsFile(host), tlsCert, priv, opts.crlValidity); nil != err {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "\tTLS CRL saved to: %s\n", tlsFile(host)+".crl")
+	}
+
+	return nil
+}
+
+// saveSigningCertificate issues a new signing certificate for signerKey and
+// saves it to certFile and with the key to privFile
+func saveSigningCertificate(signerId string, opts *certOptions, signerKey *rsa.PrivateKey, certFile, privFile string) ([]byte, error) {
+	signerCert, err := opts.signingCertificate(signerId, signerKey)
+	if nil != err {
+		return nil, err
+	}
+	if err := checkNewCertificate(signerCert, &signerKey.PublicKey); nil != err {
+		return nil, err
+	}
+
+	// save cert
+	if err := reseed.WriteFileAtomic(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signerCert}), 0644); nil != err {
+		return nil, fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+	fmt.Fprintln(os.Stderr, "\tSigning certificate saved to:", certFile)
+
+	// save signing private key
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(signerKey)})
+	keyPem = append(keyPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: signerCert})...)
+	if err := reseed.WriteFileAtomic(privFile, keyPem, 0600); nil != err {
+		return nil, fmt.Errorf("failed to write %s: %w", privFile, err)
+	}
+	fmt.Fprintln(os.Stderr, "\tSigning private key saved to:", privFile)
+
+	return signerCert, nil
+}
+
+// saveTLSCertificate issues a new self-signed TLS certificate for priv and
+// saves it along with the key
+func saveTLSCertificate(hosts []string, opts *certOptions, priv *ecdsa.PrivateKey, certFile, privFile string) ([]byte, error) {
+	tlsCert, err := opts.tlsCertificate(hosts, priv)
+	if nil != err {
+		return nil, err
+	}
+	if err := checkNewCertificate(tlsCert, &priv.PublicKey); nil != err {
+		return nil, err
+	}
+
+	// save the TLS certificate
+	if err := reseed.WriteFileAtomic(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsCert}), 0644); nil != err {
+		return nil, fmt.Errorf("failed to write %s: %w", certFile, err)
+	}
+	fmt.Fprintf(os.Stderr, "\tTLS certificate saved to: %s\n", certFile)
+
+	// save the TLS private key
+	secp384r1, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})		// http://www.ietf.org/rfc/rfc5480.txt
+	if nil != err {
+		return nil, err
+	}
+	ecder, err := x509.MarshalECPrivateKey(priv)
+	if nil != err {
+		return nil, err
+	}
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PARAMETERS", Bytes: secp384r1})
+	keyPem = append(keyPem, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})...)
+	keyPem = append(keyPem, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsCert})...)
+	if err := reseed.WriteFileAtomic(privFile, keyPem, 0600); nil != err {
+		return nil, fmt.Errorf("failed to write %s: %w", privFile, err)
+	}
+	fmt.Fprintf(os.Stderr, "\tTLS private key saved to: %s\n", privFile)
+
+	return tlsCert, nil
+}
+
+// saveCRL writes base.crl, an empty CRL signed by the certificate's own key.
+// The CRL number is kept in base.crlnumber so it keeps increasing across runs.
+func saveCRL(base string, certDer []byte, key crypto.Signer, validity time.Duration) error {
+	cert, err := x509.ParseCertificate(certDer)
 	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s", crlFile, err)
+		return fmt.Errorf("Certificate with unknown critical extension was not parsed: %w", err)
+	}
+
+	number, err := reseed.NextCRLNumber(base + ".crlnumber")
+	if nil != err {
+		return err
 	}
-	crlcert, err := x509.ParseCertificate(signerCert)
-		if err != nil {
-			return fmt.Errorf("Certificate with unknown critical extension was not parsed: %s", err)
-		}
 
 	now := time.Now()
-	revokedCerts := []pkix.RevokedCertificate{
-		{
-			SerialNumber:   crlcert.SerialNumber,
-			RevocationTime: now,
-		},
+	template := &x509.RevocationList{
+		Number:     number,
+		ThisUpdate: now,
+		NextUpdate: now.Add(validity),
 	}
 
-	crlBytes, err := crlcert.CreateCRL(rand.Reader, signerKey, revokedCerts, now, now)
+	crlBytes, err := x509.CreateRevocationList(rand.Reader, template, cert, key)
 	if err != nil {
-		return fmt.Errorf("error creating CRL: %s", err)
+		return fmt.Errorf("error creating CRL: %w", err)
 	}
-	_, err = x509.ParseDERCRL(crlBytes)
-	if err != nil {
-		return fmt.Errorf("error reparsing CRL: %s", err)
+	if _, err := x509.ParseRevocationList(crlBytes); err != nil {
+		return fmt.Errorf("error reparsing CRL: %w", err)
 	}
-	pem.Encode(crlOut, &pem.Block{Type: "X509 CRL", Bytes: crlBytes})
-	crlOut.Close()
-	fmt.Printf("\tSigning CRL saved to: %s\n", crlFile)
 
+	crlFile := base + ".crl"
+	if err := reseed.WriteFileAtomic(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crlBytes}), 0600); nil != err {
+		return fmt.Errorf("failed to write %s: %w", crlFile, err)
+	}
 
 	return nil
 }
 
-func createTLSCertificate(host string) error {
-	fmt.Println("Generating TLS keys. This may take a minute...")
-//	priv, err := rsa.GenerateKey(rand.Reader, 4096)
-	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
-	if err != nil {
+// writeOutput writes data to path, or to stdout if path is STDOUT. The data is
+// complete before anything is written, so a failed build never leaves a
+// truncated file for the next command in a pipe.
+func writeOutput(path string, data []byte, perm os.FileMode) error {
+	if path == STDOUT {
+		_, err := os.Stdout.Write(data)
 		return err
 	}
 
-	tlsCert, err := reseed.NewTLSCertificate(host, priv)
+	return reseed.WriteFileAtomic(path, data, perm)
+}
+
+// checkCertKey returns ErrCertKeyMismatch if cert is not the certificate of
+// the private key of pub
+func checkCertKey(cert *x509.Certificate, pub crypto.PublicKey) error {
+	certPub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !certPub.Equal(pub) {
+		return fmt.Errorf("%w: certificate of '%s'", ErrCertKeyMismatch, cert.Subject.CommonName)
+	}
+
+	return nil
+}
+
+// checkNewCertificate makes sure a freshly issued certificate pairs with its
+// key before anything is written
+func checkNewCertificate(der []byte, pub crypto.PublicKey) error {
+	cert, err := x509.ParseCertificate(der)
 	if nil != err {
 		return err
 	}
 
-	// save the TLS certificate
-	certOut, err := os.Create(host + ".crt")
-	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s", host+".crt", err)
-	}
-	pem.Encode(certOut, &pem.Block{Type: "CERTIFICATE", Bytes: tlsCert})
-	certOut.Close()
-	fmt.Printf("\tTLS certificate saved to: %s\n", host+".crt")
+	return checkCertKey(cert, pub)
+}
 
-	// save the TLS private key
-	privFile := host + ".pem"
-	keyOut, err := os.OpenFile(privFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s\n", privFile, err)
+func loadTLSPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	privPem, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
 	}
-//	pem.Encode(keyOut, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(priv)})
-	secp384r1, err := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 34})		// http://www.ietf.org/rfc/rfc5480.txt
-	pem.Encode(keyOut, &pem.Block{Type: "EC PARAMETERS", Bytes: secp384r1})
-	ecder, err := x509.MarshalECPrivateKey(priv)
-	pem.Encode(keyOut, &pem.Block{Type: "EC PRIVATE KEY", Bytes: ecder})
-	pem.Encode(keyOut, &pem.Block{Type: "CERTIFICATE", Bytes: tlsCert})
 
-	keyOut.Close()
-	fmt.Printf("\tTLS private key saved to: %s\n", privFile)
+	for block, rest := pem.Decode(privPem); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "EC PRIVATE KEY" {
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
 
+	return nil, fmt.Errorf("No EC PRIVATE KEY found in '%s'", path)
+}
 
-	// CRL
-	crlFile := host + ".crl"
-	crlOut, err := os.OpenFile(crlFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		return fmt.Errorf("failed to open %s for writing: %s", crlFile, err)
+func loadCertificate(path string) (*x509.Certificate, error) {
+	certPem, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
 	}
-	crlcert, err := x509.ParseCertificate(tlsCert)
-		if err != nil {
-			return fmt.Errorf("Certificate with unknown critical extension was not parsed: %s", err)
-		}
 
-	now := time.Now()
-	revokedCerts := []pkix.RevokedCertificate{
-		{
-			SerialNumber:   crlcert.SerialNumber,
-			RevocationTime: now,
-		},
+	for block, rest := pem.Decode(certPem); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
 	}
 
-	crlBytes, err := crlcert.CreateCRL(rand.Reader, priv, revokedCerts, now, now)
-	if err != nil {
-		return fmt.Errorf("error creating CRL: %s", err)
+	return nil, fmt.Errorf("No CERTIFICATE found in '%s'", path)
+}
+
+// loadCertPool reads all certificates of a PEM file
+func loadCertPool(path string) (*x509.CertPool, error) {
+	if path == "" {
+		return nil, fmt.Errorf("No certificate file given")
 	}
-	_, err = x509.ParseDERCRL(crlBytes)
-	if err != nil {
-		return fmt.Errorf("error reparsing CRL: %s", err)
+	certPem, err := ioutil.ReadFile(path)
+	if nil != err {
+		return nil, err
 	}
-	pem.Encode(crlOut, &pem.Block{Type: "X509 CRL", Bytes: crlBytes})
-	crlOut.Close()
-	fmt.Printf("\tTLS CRL saved to: %s\n", crlFile)
 
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certPem) {
+		return nil, fmt.Errorf("No CERTIFICATE found in '%s'", path)
+	}
 
-	return nil
+	return pool, nil
 }
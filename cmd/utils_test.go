@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509/pkix"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+func TestSaveCRLNextUpdate(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	cert, err := reseed.NewTLSCertificate([]string{"reseed.example"}, pkix.Name{}, priv)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	validity := 7 * 24 * time.Hour
+	base := filepath.Join(t.TempDir(), "reseed.example")
+	before := time.Now()
+	if err := saveCRL(base, cert, priv, validity); nil != err {
+		t.Fatal(err)
+	}
+
+	crl, err := loadCRL(base + ".crl")
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !crl.NextUpdate.After(time.Now()) {
+		t.Fatalf("nextUpdate %s is not in the future", crl.NextUpdate)
+	}
+	// the dates are encoded in whole seconds
+	if crl.NextUpdate.Before(before.Add(validity).Truncate(time.Second)) || crl.NextUpdate.After(time.Now().Add(validity)) {
+		t.Errorf("nextUpdate %s is not %s after thisUpdate %s", crl.NextUpdate, validity, crl.ThisUpdate)
+	}
+}
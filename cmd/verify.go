@@ -3,6 +3,8 @@ package cmd
 import (
 	"fmt"
 	"io/ioutil"
+	"os"
+	"time"
 
 	"github.com/martin61/i2p-tools/reseed"
 	"github.com/martin61/i2p-tools/su3"
@@ -16,40 +18,87 @@ func NewSu3VerifyCommand() cli.Command {
 		Description: "Verify a Su3 file",
 		Action:      su3VerifyAction,
 		Flags: []cli.Flag{
+			cli.StringFlag{
+				Name:  "certificates",
+				Value: "./certificates",
+				Usage: "Directory with the trusted signer certificates, like the certificates/reseed directory of a router or its parent",
+			},
 			cli.BoolFlag{
 				Name:  "extract",
 				Usage: "Also extract the contents of the su3",
 			},
+			cli.StringFlag{
+				Name:  "pinStore",
+				Usage: "Pin the signer's certificate fingerprint in this file on the first successful verify and fail if it changes later",
+			},
 		},
 	}
 }
 
 func su3VerifyAction(c *cli.Context) {
-	su3File := su3.Su3File{}
-
 	data, err := ioutil.ReadFile(c.Args().Get(0))
 	if nil != err {
 		panic(err)
 	}
-	if err := su3File.UnmarshalBinary(data); err != nil {
+	su3File, err := su3.Parse(data)
+	if err != nil {
 		panic(err)
 	}
 
 	fmt.Println(su3File.String())
 
-	// get the reseeder key
-	ks := reseed.KeyStore{Path: "./certificates"}
-	cert, err := ks.ReseederCertificate(su3File.SignerId)
+	// find the certificate of the claimed signer, there may be several
+	// after a key rotation
+	ks := reseed.KeyStore{Path: c.String("certificates")}
+	certs, err := ks.SignerCertificates(su3File.SignerId)
 	if nil != err {
 		fmt.Println(err)
-		return
+		os.Exit(1)
 	}
 
-	if err := su3File.VerifySignature(cert); nil != err {
-		panic(err)
+	var signerCert *reseed.SignerCertificate
+	for i := range certs {
+		if err = su3File.VerifySignature(certs[i].Certificate); nil == err {
+			signerCert = &certs[i]
+			break
+		}
+	}
+	if nil == signerCert {
+		fmt.Printf("Signature is INVALID for signer '%s', tried %d certificates: %s\n", su3File.SignerId, len(certs), err)
+		os.Exit(1)
 	}
+	cert := signerCert.Certificate
 
 	fmt.Printf("Signature is valid for signer '%s'\n", su3File.SignerId)
+	fmt.Printf("Verified with %s (serial %s, valid until %s)\n", signerCert.Path, cert.SerialNumber, cert.NotAfter.Format(time.RFC3339))
+
+	if pinFile := c.String("pinStore"); pinFile != "" {
+		pins, err := loadPinStore(pinFile)
+		if nil != err {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		pinned, err := pins.check(string(su3File.SignerId), cert)
+		if nil != err {
+			fmt.Println()
+			fmt.Println("!!! WARNING:", err, "!!!")
+			os.Exit(1)
+		}
+		if pinned {
+			fmt.Printf("Pinned certificate %s for signer '%s'\n", certFingerprint(cert), su3File.SignerId)
+		}
+	}
+
+	if su3File.ContentType == su3.CONTENT_TYPE_RESEED && su3File.FileType == su3.FILE_TYPE_ZIP {
+		if manifest, err := reseed.ReadManifest(su3File.Content); nil != err {
+			fmt.Println("Unable to read the manifest:", err)
+		} else if nil != manifest {
+			fmt.Printf("Manifest: %d routerInfos built %s by '%s'\n", manifest.Count, manifest.Built.Format(time.RFC3339), manifest.Signer)
+			for _, hash := range manifest.Routers {
+				fmt.Println("\t" + hash)
+			}
+		}
+	}
 
 	if c.Bool("extract") {
 		// @todo: don't assume zip
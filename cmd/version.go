@@ -0,0 +1,24 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/codegangsta/cli"
+	"github.com/martin61/i2p-tools/reseed"
+)
+
+func NewVersionCommand() cli.Command {
+	return cli.Command{
+		Name:   "version",
+		Usage:  "Print the version, git commit, build date and Go version of this build",
+		Action: versionAction,
+	}
+}
+
+func versionAction(c *cli.Context) {
+	info := reseed.GetBuildInfo()
+	fmt.Println("Version:   ", info.Version)
+	fmt.Println("Commit:    ", info.Commit)
+	fmt.Println("Build date:", info.BuildDate)
+	fmt.Println("Go version:", info.GoVersion)
+}
@@ -0,0 +1,18 @@
+module github.com/martin61/i2p-tools
+
+go 1.21
+
+require (
+	github.com/codegangsta/cli v1.20.0
+	github.com/gorilla/handlers v1.5.2
+	github.com/justinas/alice v1.2.0
+	github.com/throttled/throttled v2.2.5+incompatible
+	go.etcd.io/bbolt v1.3.10
+)
+
+require (
+	github.com/felixge/httpsnoop v1.0.3 // indirect
+	github.com/gomodule/redigo v1.8.9 // indirect
+	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	golang.org/x/sys v0.4.0 // indirect
+)
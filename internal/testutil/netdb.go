@@ -0,0 +1,154 @@
+// Package testutil generates synthetic test data, so the netDb code can be
+// exercised without an I2P router.
+package testutil
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+)
+
+// all dates of the generated routerInfos are derived from this one
+var Time = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// the router keys are derived from it, they are not secret
+const KEY_SEED = "i2p-tools testdata"
+
+// NetDbOptions controls the routerInfos of a synthetic netDb
+type NetDbOptions struct {
+	// when router i was published, i minutes before Time if nil
+	Published func(i int) time.Time
+	// transports of the addresses of each router, one address each, NTCP2
+	// and SSU2 if empty
+	Transports []string
+	// router options, caps LR on netId 2 if nil
+	Options map[string]string
+}
+
+// Router is a routerInfo of a synthetic netDb
+type Router struct {
+	Name      string
+	Path      string
+	Published time.Time
+	Data      []byte
+}
+
+// WriteNetDb writes numRi routerInfos to dir in the I2P netDb layout, with
+// the modification time of each file set to its published date. Each
+// router's key is derived from its index, so the output is always the same.
+func WriteNetDb(dir string, numRi int, opts NetDbOptions) ([]Router, error) {
+	transports := opts.Transports
+	if len(transports) == 0 {
+		transports = []string{router.TRANSPORT_NTCP2, router.TRANSPORT_SSU2}
+	}
+	options := opts.Options
+	if nil == options {
+		options = map[string]string{"caps": "LR", "netId": "2", "router.version": "0.9.61"}
+	}
+
+	var routers []Router
+	for i := 0; i < numRi; i++ {
+		keySeed := sha256.Sum256([]byte(fmt.Sprintf("%s router %d", KEY_SEED, i)))
+		key := ed25519.NewKeyFromSeed(keySeed[:])
+		published := Time.Add(-time.Duration(i) * time.Minute)
+		if nil != opts.Published {
+			published = opts.Published(i)
+		}
+
+		var addresses []router.RouterAddress
+		for _, transport := range transports {
+			addr, err := address(transport, i, keySeed[:])
+			if nil != err {
+				return nil, err
+			}
+			addresses = append(addresses, addr)
+		}
+		data := router.NewEd25519RouterInfo(key, published, addresses, options)
+
+		ri, err := router.ParseRouterInfo(data)
+		if nil != err {
+			return nil, err
+		}
+		hash := ri.HashBase64()
+		name := "routerInfo-" + hash + ".dat"
+
+		subdir := filepath.Join(dir, "r"+hash[:1])
+		if err := os.MkdirAll(subdir, 0755); nil != err {
+			return nil, err
+		}
+		path := filepath.Join(subdir, name)
+		if err := ioutil.WriteFile(path, data, 0644); nil != err {
+			return nil, err
+		}
+		if err := os.Chtimes(path, published, published); nil != err {
+			return nil, err
+		}
+
+		routers = append(routers, Router{Name: name, Path: path, Published: published, Data: data})
+	}
+
+	return routers, nil
+}
+
+// TB is the part of testing.TB used here, so the package doesn't link the
+// testing package into the binaries using WriteNetDb
+type TB interface {
+	Helper()
+	TempDir() string
+	Fatal(args ...interface{})
+}
+
+// TempNetDb writes a synthetic netDb to a temporary directory removed when
+// the test ends and returns its path
+func TempNetDb(tb TB, numRi int, opts NetDbOptions) string {
+	tb.Helper()
+
+	dir := tb.TempDir()
+	if _, err := WriteNetDb(dir, numRi, opts); nil != err {
+		tb.Fatal(err)
+	}
+
+	return dir
+}
+
+// address returns an address of router i in the layout of current routers,
+// NTCP2 and SSU2 with their static key and IV or intro key
+func address(transport string, i int, keySeed []byte) (router.RouterAddress, error) {
+	host := fmt.Sprintf("10.%d.%d.%d", i>>16&0xff, i>>8&0xff, i&0xff)
+	staticKey := sha256.Sum256(append(keySeed, 's'))
+	introKey := sha256.Sum256(append(keySeed, 'i'))
+
+	switch transport {
+	case router.TRANSPORT_NTCP2:
+		return router.RouterAddress{Cost: 3, Transport: transport, Options: map[string]string{
+			"host": host,
+			"i":    router.Base64.EncodeToString(introKey[:16]),
+			"port": "12345",
+			"s":    router.Base64.EncodeToString(staticKey[:]),
+			"v":    "2",
+		}}, nil
+	case router.TRANSPORT_SSU2:
+		return router.RouterAddress{Cost: 8, Transport: transport, Options: map[string]string{
+			"caps": "BC",
+			"host": host,
+			"i":    router.Base64.EncodeToString(introKey[:]),
+			"mtu":  "1500",
+			"port": "12345",
+			"s":    router.Base64.EncodeToString(staticKey[:]),
+			"v":    "2",
+		}}, nil
+	case router.TRANSPORT_NTCP, router.TRANSPORT_SSU:
+		return router.RouterAddress{Cost: 10, Transport: transport, Options: map[string]string{
+			"host": host,
+			"port": "12345",
+		}}, nil
+	}
+
+	return router.RouterAddress{}, fmt.Errorf("unknown transport '%s'", transport)
+}
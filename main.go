@@ -5,6 +5,7 @@ import (
 	"runtime"
 
 	"github.com/martin61/i2p-tools/cmd"
+	"github.com/martin61/i2p-tools/reseed"
 	"github.com/codegangsta/cli"
 )
 
@@ -19,7 +20,7 @@ func main() {
 
 	app := cli.NewApp()
 	app.Name = "i2p-tools"
-	app.Version = "0.1.7"
+	app.Version = reseed.Version
 	app.Usage = "I2P tools and reseed server"
 	app.Author = "martin61"
 	app.Email = "noemail"
@@ -28,6 +29,24 @@ func main() {
 		cmd.NewReseedCommand(),
 		cmd.NewSu3VerifyCommand(),
 		cmd.NewKeygenCommand(),
+		cmd.NewRotateKeyCommand(),
+		cmd.NewTestdataCommand(),
+		cmd.NewCrlInfoCommand(),
+		cmd.NewMergeCommand(),
+		cmd.NewSignDetachedCommand(),
+		cmd.NewVerifyDetachedCommand(),
+		cmd.NewHashesCommand(),
+		cmd.NewBuildUnsignedCommand(),
+		cmd.NewSignBundleCommand(),
+		cmd.NewAuditCommand(),
+		cmd.NewProbeCommand(),
+		cmd.NewCheckConfigCommand(),
+		cmd.NewKeyBenchCommand(),
+		cmd.NewVersionCommand(),
+		cmd.NewCertDiffCommand(),
+		cmd.NewImportCertCommand(),
+		cmd.NewInitCommand(),
+		cmd.NewMirrorCommand(),
 		// cmd.NewSu3VerifyPublicCommand(),
 	}
 
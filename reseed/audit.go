@@ -0,0 +1,113 @@
+package reseed
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/martin61/i2p-tools/su3"
+)
+
+// the reseed command signs with RSA_SHA512, the su3 header reserves this much
+const AUDIT_SIGNATURE_LENGTH = 512
+
+// AuditReport describes what a rebuild would produce from the current netDb
+type AuditReport struct {
+	// routerInfos returned by the netDb, routerInfos older than 192h are
+	// already left out by it
+	Total int `json:"total"`
+	// with VerifyRouterInfos
+	InvalidSignature int `json:"invalidSignature"`
+	// a rebuild only uses 75% of the routerInfos
+	Unused   int            `json:"unused"`
+	Profiles []ProfileAudit `json:"profiles"`
+}
+
+type ProfileAudit struct {
+	Name                   string `json:"name"`
+	Included               int    `json:"included"`
+	ExcludedByAge          int    `json:"excludedByAge"`
+	ExcludedByReachability int    `json:"excludedByReachability"`
+	ExcludedByFloodfill    int    `json:"excludedByFloodfill"`
+	NumRi                  int    `json:"numRi"`
+	NumSu3                 int    `json:"numSu3"`
+	// size of a single su3 file, built from a random sample
+	EstimatedSu3Bytes int `json:"estimatedSu3Bytes"`
+	// why the profile can't be built, "" if it can
+	Error string `json:"error,omitempty"`
+}
+
+// Audit scans the netDb and applies the filters of a rebuild without
+// building or signing anything
+func (rs *ReseederImpl) Audit() (*AuditReport, error) {
+	ris, err := rs.netdb.RouterInfos()
+	if nil != err {
+		return nil, fmt.Errorf("Unable to get routerInfos: %w", err)
+	}
+	if 0 == len(ris) {
+		return nil, ErrEmptyNetDb
+	}
+
+	report := &AuditReport{Total: len(ris)}
+	if rs.VerifyRouterInfos {
+		ris = verifiedRouterInfos(ris)
+		report.InvalidSignature = report.Total - len(ris)
+	}
+
+	// use only 75% of routerInfos
+	report.Unused = len(ris) / 4
+	ris = ris[len(ris)/4:]
+
+	profiles := append([]Profile{{Name: DEFAULT_PROFILE, NumRi: rs.NumRi, NumSu3: rs.NumSu3}}, rs.Profiles...)
+	for _, profile := range profiles {
+		report.Profiles = append(report.Profiles, rs.auditProfile(profile, ris))
+	}
+
+	return report, nil
+}
+
+func (rs *ReseederImpl) auditProfile(profile Profile, ris []routerInfo) ProfileAudit {
+	pa := ProfileAudit{Name: profile.Name, NumRi: profile.NumRi}
+	if 0 == pa.NumRi {
+		pa.NumRi = rs.NumRi
+	}
+
+	var included []routerInfo
+	for _, ri := range ris {
+		switch profile.excludes(ri) {
+		case EXCLUDED_BY_AGE:
+			pa.ExcludedByAge++
+		case EXCLUDED_BY_REACHABILITY:
+			pa.ExcludedByReachability++
+		case EXCLUDED_BY_FLOODFILL:
+			pa.ExcludedByFloodfill++
+		default:
+			included = append(included, ri)
+		}
+	}
+	pa.Included = len(included)
+
+	if pa.NumRi > len(included) {
+		pa.Error = fmt.Sprintf("%s: %d of %d", ErrNotEnoughRouterInfos, len(included), pa.NumRi)
+		return pa
+	}
+	pa.NumSu3 = su3Count(len(included), profile.NumSu3)
+
+	var seeds []routerInfo
+	for _, i := range rand.Perm(len(included))[:pa.NumRi] {
+		seeds = append(seeds, included[i])
+	}
+	zipped, err := rs.zipSeeds(seeds)
+	if nil != err {
+		pa.Error = err.Error()
+		return pa
+	}
+
+	su3File := su3.NewSu3File()
+	su3File.FileType = su3.FILE_TYPE_ZIP
+	su3File.ContentType = su3.CONTENT_TYPE_RESEED
+	su3File.SignerId = rs.SignerId
+	su3File.Content = zipped
+	pa.EstimatedSu3Bytes = len(su3File.BodyBytes()) + AUDIT_SIGNATURE_LENGTH
+
+	return pa
+}
@@ -0,0 +1,85 @@
+package reseed
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	ADMIN_REALM = "i2p-tools"
+)
+
+// AdminAuth protects the operator endpoints (stats, metrics, ...).
+// If neither a token nor basic auth credentials are set, no auth is required.
+type AdminAuth struct {
+	Token    string
+	User     string
+	PassHash []byte // sha256 of the password
+}
+
+// ParseBasicAuth parses "user:passhash" where passhash is the hex encoded
+// sha256 of the password (ex. `printf %s secret | sha256sum`)
+func ParseBasicAuth(userPass string) (user string, passHash []byte, err error) {
+	parts := strings.SplitN(userPass, ":", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", nil, fmt.Errorf("Basic auth must be in the form user:passhash")
+	}
+
+	passHash, err = hex.DecodeString(parts[1])
+	if nil != err || len(passHash) != sha256.Size {
+		return "", nil, fmt.Errorf("Basic auth passhash must be a hex encoded sha256 digest")
+	}
+
+	return parts[0], passHash, nil
+}
+
+// SecretEqual compares secrets (tokens, password hashes, signatures) in
+// constant time. Secrets must never be compared with == or bytes.Equal,
+// which return at the first difference and let an attacker guess them byte
+// by byte. Both are hashed first, so not even the length is leaked.
+func SecretEqual(a, b []byte) bool {
+	hashA, hashB := sha256.Sum256(a), sha256.Sum256(b)
+	return 1 == subtle.ConstantTimeCompare(hashA[:], hashB[:])
+}
+
+func (a *AdminAuth) enabled() bool {
+	return a.Token != "" || a.User != ""
+}
+
+func (a *AdminAuth) authorized(r *http.Request) bool {
+	if a.Token != "" {
+		if token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "); token != r.Header.Get("Authorization") {
+			if SecretEqual([]byte(token), []byte(a.Token)) {
+				return true
+			}
+		}
+	}
+
+	if a.User != "" {
+		if user, pass, ok := r.BasicAuth(); ok {
+			// both are compared so the time doesn't tell which was wrong
+			passHash := sha256.Sum256([]byte(pass))
+			userOk := SecretEqual([]byte(user), []byte(a.User))
+			passOk := SecretEqual(passHash[:], a.PassHash)
+			if userOk && passOk {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (a *AdminAuth) challenge(w http.ResponseWriter) {
+	if a.Token != "" {
+		w.Header().Add("WWW-Authenticate", `Bearer realm="`+ADMIN_REALM+`"`)
+	}
+	if a.User != "" {
+		w.Header().Add("WWW-Authenticate", `Basic realm="`+ADMIN_REALM+`"`)
+	}
+	http.Error(w, "401 Unauthorized", http.StatusUnauthorized)
+}
@@ -0,0 +1,105 @@
+package reseed
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSecretEqual(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		equal bool
+	}{
+		{"s3cret-token", "s3cret-token", true},
+		{"", "", true},
+		{"s3cret-token", "s3cret-tokeN", false},
+		{"s3cret-token", "s3cret-toke", false},
+		{"s3cret-token", "s3cret-token-longer", false},
+		{"s3cret-token", "", false},
+	}
+
+	for _, test := range tests {
+		if equal := SecretEqual([]byte(test.a), []byte(test.b)); equal != test.equal {
+			t.Errorf("SecretEqual(%q, %q) = %t", test.a, test.b, equal)
+		}
+	}
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	secret, body := "webhook-secret", []byte(`{"event":"rebuild_success"}`)
+	header := "sha256=" + WebhookSignature(secret, body)
+
+	if !VerifyWebhookSignature(secret, body, header) {
+		t.Error("valid signature rejected")
+	}
+
+	tampered := append([]byte{}, body...)
+	tampered[len(tampered)-2] = 'x'
+	if VerifyWebhookSignature(secret, tampered, header) {
+		t.Error("signature accepted for a tampered body")
+	}
+	if VerifyWebhookSignature("other-secret", body, header) {
+		t.Error("signature accepted with another secret")
+	}
+
+	sig := []byte(header)
+	sig[len(sig)-1] ^= 0x01
+	if VerifyWebhookSignature(secret, body, string(sig)) {
+		t.Error("tampered signature accepted")
+	}
+	if VerifyWebhookSignature(secret, body, WebhookSignature(secret, body)) {
+		t.Error("signature accepted without the sha256= prefix")
+	}
+}
+
+func TestWebhookSendSigned(t *testing.T) {
+	secret := "webhook-secret"
+	verified := make(chan bool, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if nil != err {
+			t.Error(err)
+		}
+		verified <- VerifyWebhookSignature(secret, body, r.Header.Get(WEBHOOK_SIGNATURE_HEADER))
+	}))
+	defer ts.Close()
+
+	if err := NewWebhook(ts.URL, secret).Send(EVENT_STARTUP, nil); nil != err {
+		t.Fatal(err)
+	}
+	if !<-verified {
+		t.Error("the receiver couldn't verify the signature")
+	}
+}
+
+func TestAdminAuthorized(t *testing.T) {
+	_, passHash, err := ParseBasicAuth("admin:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b")
+	if nil != err {
+		t.Fatal(err)
+	}
+	auth := &AdminAuth{Token: "s3cret-token", User: "admin", PassHash: passHash}
+
+	tests := []struct {
+		name       string
+		set        func(r *http.Request)
+		authorized bool
+	}{
+		{"token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-token") }, true},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret-tokeN") }, false},
+		{"token without bearer", func(r *http.Request) { r.Header.Set("Authorization", "s3cret-token") }, false},
+		{"basic", func(r *http.Request) { r.SetBasicAuth("admin", "secret") }, true},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("admin", "Secret") }, false},
+		{"wrong user", func(r *http.Request) { r.SetBasicAuth("admiN", "secret") }, false},
+		{"none", func(r *http.Request) {}, false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/stats.json", nil)
+		test.set(r)
+		if authorized := auth.authorized(r); authorized != test.authorized {
+			t.Errorf("%s: authorized is %t", test.name, authorized)
+		}
+	}
+}
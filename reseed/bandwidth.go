@@ -0,0 +1,76 @@
+package reseed
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// the most a throttled response writes at once, so the rate stays even
+	// on slow limits and fast ones don't make tiny writes
+	BANDWIDTH_CHUNK = 16 * 1024
+)
+
+// bandwidthWriter writes at most rate bytes per second to the response, a
+// token bucket holding up to one second of bytes
+type bandwidthWriter struct {
+	http.ResponseWriter
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func (w *bandwidthWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		now := time.Now()
+		w.tokens += now.Sub(w.last).Seconds() * w.rate
+		if w.tokens > w.rate {
+			w.tokens = w.rate
+		}
+		w.last = now
+
+		n := len(p)
+		if n > BANDWIDTH_CHUNK {
+			n = BANDWIDTH_CHUNK
+		}
+		if float64(n) > w.rate {
+			n = int(w.rate)
+		}
+		if w.tokens < float64(n) {
+			time.Sleep(time.Duration((float64(n) - w.tokens) / w.rate * float64(time.Second)))
+			continue
+		}
+
+		m, err := w.ResponseWriter.Write(p[:n])
+		written += m
+		w.tokens -= float64(m)
+		if nil != err {
+			return written, err
+		}
+		p = p[m:]
+	}
+
+	return written, nil
+}
+
+// SetBandwidthPerConn limits each su3 download to rate bytes per second, 0
+// is no limit. Downloads already running keep their rate.
+func (s *Server) SetBandwidthPerConn(rate int) {
+	atomic.StoreInt64(&s.bandwidthPerConn, int64(rate))
+}
+
+// bandwidthMiddleware throttles each response to the rate set with
+// SetBandwidthPerConn
+func (s *Server) bandwidthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rate := atomic.LoadInt64(&s.bandwidthPerConn)
+		if rate <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		next.ServeHTTP(&bandwidthWriter{ResponseWriter: w, rate: float64(rate), last: time.Now()}, r)
+	})
+}
@@ -0,0 +1,141 @@
+package reseed
+
+import (
+	"container/list"
+	"log"
+	"sync"
+	"time"
+)
+
+// Banlist temporarily bans IPs that keep getting rate limited or sending bad
+// requests. Every ban of the same IP lasts twice as long as the previous one,
+// up to MaxBanTime.
+type Banlist struct {
+	// violations within Window that trigger a ban
+	Threshold int
+	Window    time.Duration
+	// duration of the first ban
+	BanTime    time.Duration
+	MaxBanTime time.Duration
+	// number of IPs tracked, the least recently seen are forgotten first
+	MaxEntries int
+
+	offenders map[string]*offender
+	// the IPs of offenders, most recently seen first
+	seen *list.List
+	m    sync.Mutex
+}
+
+type offender struct {
+	violations  int
+	windowStart time.Time
+	bans        uint
+	bannedUntil time.Time
+	lastSeen    time.Time
+	// in Banlist.seen
+	elem *list.Element
+}
+
+func NewBanlist(threshold int, window, banTime, maxBanTime time.Duration, maxEntries int) *Banlist {
+	return &Banlist{
+		Threshold:  threshold,
+		Window:     window,
+		BanTime:    banTime,
+		MaxBanTime: maxBanTime,
+		MaxEntries: maxEntries,
+		offenders:  make(map[string]*offender),
+		seen:       list.New(),
+	}
+}
+
+// Violation records a violation of ip and bans it once it reached the
+// threshold. It returns true if ip is banned.
+func (b *Banlist) Violation(ip string) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+	o, found := b.offenders[ip]
+	if !found {
+		if len(b.offenders) >= b.MaxEntries {
+			b.evict(now)
+		}
+		o = &offender{windowStart: now, elem: b.seen.PushFront(ip)}
+		b.offenders[ip] = o
+	} else {
+		b.seen.MoveToFront(o.elem)
+	}
+	o.lastSeen = now
+
+	if now.Before(o.bannedUntil) {
+		return true
+	}
+
+	if now.Sub(o.windowStart) > b.Window {
+		o.violations, o.windowStart = 0, now
+	}
+	o.violations++
+	if o.violations < b.Threshold {
+		return false
+	}
+
+	banTime := b.BanTime << o.bans
+	if banTime > b.MaxBanTime || banTime < b.BanTime {
+		banTime = b.MaxBanTime
+	}
+	o.bans++
+	o.violations = 0
+	o.bannedUntil = now.Add(banTime)
+	log.Printf("Banned %s for %s after %d violations.\n", ip, banTime, b.Threshold)
+
+	return true
+}
+
+func (b *Banlist) isBanned(ip string) bool {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	o, found := b.offenders[ip]
+
+	return found && time.Now().Before(o.bannedUntil)
+}
+
+// Banned returns the number of IPs currently banned
+func (b *Banlist) Banned() int {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	now := time.Now()
+	banned := 0
+	for _, o := range b.offenders {
+		if now.Before(o.bannedUntil) {
+			banned++
+		}
+	}
+
+	return banned
+}
+
+// evict forgets the IPs not seen for longer than a ban and its window, their
+// bans are over. If that doesn't make room, the least recently seen IP is
+// forgotten. Only the oldest entries are visited.
+func (b *Banlist) evict(now time.Time) {
+	// keep the ban count around for a while so repeat offenders escalate
+	for e := b.seen.Back(); nil != e; e = b.seen.Back() {
+		if o := b.offenders[e.Value.(string)]; now.Sub(o.lastSeen) <= b.Window+b.MaxBanTime {
+			break
+		}
+		b.forget(e)
+	}
+
+	if len(b.offenders) >= b.MaxEntries {
+		if e := b.seen.Back(); nil != e {
+			b.forget(e)
+		}
+	}
+}
+
+func (b *Banlist) forget(e *list.Element) {
+	delete(b.offenders, e.Value.(string))
+	b.seen.Remove(e)
+}
@@ -57,13 +57,7 @@ func (ln blacklistListener) Accept() (net.Conn, error) {
 		return nil, err
 	}
 
-	ip, _, err := net.SplitHostPort(tc.RemoteAddr().String())
-	if err != nil {
-		tc.Close()
-		return tc, err
-	}
-
-	if ln.blacklist.isBlocked(ip) {
+	if ln.blacklist.isBlocked(addrIp(tc.RemoteAddr().String())) {
 		tc.Close()
 		return tc, nil
 	}
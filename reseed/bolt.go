@@ -0,0 +1,129 @@
+package reseed
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	DEFAULT_BOLT_BUCKET = "routerInfos"
+	// how long to wait for a writer to release the database
+	BOLT_LOCK_TIMEOUT = time.Second
+)
+
+// BoltNetDbImpl reads routerInfos from a bbolt database instead of loose
+// files. The bucket maps router hashes, either the 32 raw bytes or their I2P
+// base64 encoding, to routerInfo blobs.
+//
+// bbolt locks the file: a process with the database open for writing, like a
+// running router, blocks readers until it closes it. Rebuilds then reuse the
+// routerInfos of the last read. Have the writer export a copy with
+// Tx.WriteTo to read a live database.
+type BoltNetDbImpl struct {
+	Path   string
+	Bucket string
+
+	mu   sync.Mutex
+	last []routerInfo
+}
+
+func NewBoltNetDb(path string) *BoltNetDbImpl {
+	return &BoltNetDbImpl{
+		Path:   path,
+		Bucket: DEFAULT_BOLT_BUCKET,
+	}
+}
+
+func (db *BoltNetDbImpl) RouterInfos() ([]routerInfo, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	routerInfos, err := db.read()
+	if errors.Is(err, bolt.ErrTimeout) && nil != db.last {
+		log.Printf("%s is locked by a writer, reusing the routerInfos of the last read\n", db.Path)
+		return fresh(db.last), nil
+	}
+	if nil != err {
+		return nil, err
+	}
+	db.last = routerInfos
+
+	return routerInfos, nil
+}
+
+// fresh returns the routerInfos published in the last 192h, like LocalNetDbImpl
+func fresh(routerInfos []routerInfo) []routerInfo {
+	var kept []routerInfo
+	for _, ri := range routerInfos {
+		if time.Since(ri.ModTime).Hours() <= 192 {
+			kept = append(kept, ri)
+		}
+	}
+
+	return kept
+}
+
+func (db *BoltNetDbImpl) read() (routerInfos []routerInfo, err error) {
+	// the writer holds an exclusive lock while it has the database open
+	bdb, err := bolt.Open(db.Path, 0600, &bolt.Options{ReadOnly: true, Timeout: BOLT_LOCK_TIMEOUT})
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("%s is locked by a writer: %w", db.Path, err)
+	}
+	if nil != err {
+		return nil, fmt.Errorf("%s: %s", db.Path, err)
+	}
+	defer bdb.Close()
+
+	unparsed := 0
+	err = bdb.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(db.Bucket))
+		if nil == b {
+			return fmt.Errorf("%s: no bucket '%s'", db.Path, db.Bucket)
+		}
+
+		return b.ForEach(func(k, v []byte) error {
+			hash := string(k)
+			if len(k) == 32 {
+				hash = router.Base64.EncodeToString(k)
+			}
+
+			// v is only valid during the transaction
+			data := append([]byte(nil), v...)
+			info, _ := router.ParseRouterInfo(data)
+			ri := routerInfo{
+				Name: "routerInfo-" + hash + ".dat",
+				Data: data,
+				Info: info,
+			}
+			if nil == info {
+				unparsed++
+				ri.ModTime = time.Now()
+			} else {
+				ri.ModTime = info.Published
+			}
+
+			// ignore outdated routerInfos, like LocalNetDbImpl
+			if time.Since(ri.ModTime).Hours() > 192 {
+				return nil
+			}
+
+			routerInfos = append(routerInfos, ri)
+			return nil
+		})
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	if unparsed > 0 {
+		log.Printf("Unable to parse %d routerInfos in %s\n", unparsed, db.Path)
+	}
+
+	return
+}
@@ -0,0 +1,64 @@
+package reseed
+
+import (
+	"log"
+)
+
+// NetDbSource is a named netDb in a ChainNetDbImpl
+type NetDbSource struct {
+	Name string
+	NetDbProvider
+}
+
+// ChainNetDbImpl tries its sources in order and uses the first one with at
+// least MinRi routerInfos, ex. the local netDb, then a remote mirror, then
+// the embedded routerInfos.
+type ChainNetDbImpl struct {
+	Sources []NetDbSource
+	MinRi   int
+
+	// the source used by the previous call
+	current string
+}
+
+func (db *ChainNetDbImpl) RouterInfos() ([]routerInfo, error) {
+	// if no source has enough, use the one with the most
+	var best []routerInfo
+	var bestName string
+	var lastErr error
+	for i, source := range db.Sources {
+		ris, err := source.RouterInfos()
+		if nil != err {
+			log.Printf("Unable to read netDb source %s: %s\n", source.Name, err)
+			lastErr = err
+			continue
+		}
+		if len(ris) >= db.MinRi {
+			db.use(source.Name, i > 0)
+			return ris, nil
+		}
+
+		log.Printf("netDb source %s has %d routerInfos, %d are required\n", source.Name, len(ris), db.MinRi)
+		if len(ris) > len(best) {
+			best, bestName = ris, source.Name
+		}
+	}
+
+	if nil == best {
+		return nil, lastErr
+	}
+	log.Printf("WARNING: no netDb source has enough routerInfos, using the %d of %s\n", len(best), bestName)
+	db.current = bestName
+
+	return best, nil
+}
+
+// use logs which source the routerInfos come from
+func (db *ChainNetDbImpl) use(name string, fallback bool) {
+	if fallback {
+		log.Printf("WARNING: using the routerInfos of FALLBACK netDb source %s\n", name)
+	} else if name != db.current {
+		log.Printf("Using the routerInfos of netDb source %s\n", name)
+	}
+	db.current = name
+}
@@ -0,0 +1,217 @@
+package reseed
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// names the generation of the bundle cache to serve
+	BUNDLE_CACHE_CURRENT = "CURRENT"
+	// generations kept for followers still reading an older one
+	BUNDLE_CACHE_KEEP = 3
+)
+
+// bundleMeta is stored along with the su3 files of a generation
+type bundleMeta struct {
+	Built    time.Time      `json:"built"`
+	NumRi    int            `json:"numRi"`
+	Profiles map[string]int `json:"profiles"`
+}
+
+// writeBundleCache publishes the su3 files of cache as a new generation in
+// dir, each with a .sha256 sidecar if checksums is set. Followers only see it
+// once it is complete.
+func writeBundleCache(dir string, cache *su3Cache, checksums bool) error {
+	generation := strconv.FormatInt(cache.built.UnixNano(), 10)
+
+	// fail before writing anything rather than halfway through
+	var size uint64
+	for _, pc := range cache.profiles {
+		for _, su3 := range pc.su3s {
+			size += uint64(len(su3))
+		}
+	}
+	if err := CheckDiskSpace(dir, size); nil != err {
+		return err
+	}
+
+	tmp, err := ioutil.TempDir(dir, ".tmp-")
+	if nil != err {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	meta := bundleMeta{Built: cache.built, NumRi: cache.numRi, Profiles: make(map[string]int)}
+	for name, pc := range cache.profiles {
+		meta.Profiles[name] = pc.numRi
+
+		profileDir := filepath.Join(tmp, name)
+		if err := os.Mkdir(profileDir, 0755); nil != err {
+			return err
+		}
+		for i, su3 := range pc.su3s {
+			name := filepath.Join(profileDir, fmt.Sprintf("i2pseeds-%03d.su3", i))
+			if err := ioutil.WriteFile(name, su3, 0644); nil != err {
+				return err
+			}
+			if !checksums {
+				continue
+			}
+			if err := ioutil.WriteFile(name+".sha256", Checksum(name, su3), 0644); nil != err {
+				return err
+			}
+		}
+	}
+
+	metaJson, err := json.Marshal(meta)
+	if nil != err {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmp, "meta.json"), metaJson, 0644); nil != err {
+		return err
+	}
+	if err := os.Chmod(tmp, 0755); nil != err {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, generation)); nil != err {
+		return err
+	}
+
+	// switch followers over atomically
+	current := filepath.Join(dir, BUNDLE_CACHE_CURRENT)
+	if err := WriteFileAtomic(current, []byte(generation+"\n"), 0644); nil != err {
+		return err
+	}
+	log.Printf("Published bundle generation %s to %s\n", generation, dir)
+
+	pruneBundleCache(dir)
+
+	return nil
+}
+
+// Checksum returns the sidecar of a file in the sha256sum format, so mirrors
+// can check name with `sha256sum -c name.sha256`
+func Checksum(name string, data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return []byte(hex.EncodeToString(sum[:]) + "  " + filepath.Base(name) + "\n")
+}
+
+// pruneBundleCache removes all but the newest generations
+func pruneBundleCache(dir string) {
+	entries, err := ioutil.ReadDir(dir)
+	if nil != err {
+		return
+	}
+
+	var generations []string
+	for _, entry := range entries {
+		if _, err := strconv.ParseInt(entry.Name(), 10, 64); entry.IsDir() && nil == err {
+			generations = append(generations, entry.Name())
+		}
+	}
+	sort.Slice(generations, func(i, j int) bool {
+		a, _ := strconv.ParseInt(generations[i], 10, 64)
+		b, _ := strconv.ParseInt(generations[j], 10, 64)
+		return a < b
+	})
+
+	for len(generations) > BUNDLE_CACHE_KEEP {
+		os.RemoveAll(filepath.Join(dir, generations[0]))
+		generations = generations[1:]
+	}
+}
+
+// readBundleCache loads the current generation of dir
+func readBundleCache(dir string) (string, *su3Cache, error) {
+	current, err := ioutil.ReadFile(filepath.Join(dir, BUNDLE_CACHE_CURRENT))
+	if nil != err {
+		return "", nil, err
+	}
+	generation := strings.TrimSpace(string(current))
+	if _, err := strconv.ParseInt(generation, 10, 64); nil != err {
+		return "", nil, fmt.Errorf("Invalid bundle generation '%s'", generation)
+	}
+	genDir := filepath.Join(dir, generation)
+
+	metaJson, err := ioutil.ReadFile(filepath.Join(genDir, "meta.json"))
+	if nil != err {
+		return "", nil, err
+	}
+	var meta bundleMeta
+	if err := json.Unmarshal(metaJson, &meta); nil != err {
+		return "", nil, err
+	}
+
+	cache := &su3Cache{profiles: make(map[string]*profileCache), numRi: meta.NumRi, built: meta.Built}
+	for name, numRi := range meta.Profiles {
+		files, err := filepath.Glob(filepath.Join(genDir, filepath.Base(name), "i2pseeds-*.su3"))
+		if nil != err {
+			return "", nil, err
+		}
+		sort.Strings(files)
+
+		pc := &profileCache{numRi: numRi}
+		for _, file := range files {
+			su3, err := ioutil.ReadFile(file)
+			if nil != err {
+				return "", nil, err
+			}
+			pc.su3s = append(pc.su3s, su3)
+		}
+		cache.profiles[name] = pc
+	}
+	if nil == cache.profiles[DEFAULT_PROFILE] || 0 == len(cache.profiles[DEFAULT_PROFILE].su3s) {
+		return "", nil, fmt.Errorf("Bundle generation %s has no su3 files", generation)
+	}
+	cache.index()
+
+	return generation, cache, nil
+}
+
+// followBundleCache serves the bundles another instance publishes to
+// BundleCache, swapping to each new generation within BundleCachePoll
+func (rs *ReseederImpl) followBundleCache(quit chan bool) {
+	var loaded string
+	load := func() {
+		generation, cache, err := readBundleCache(rs.BundleCache)
+		if nil != err {
+			log.Println("Unable to load the bundle cache:", err)
+			return
+		}
+		if generation == loaded {
+			return
+		}
+
+		rs.current.Store(cache)
+		atomic.AddUint64(&rs.changes, 1)
+		loaded = generation
+		log.Printf("Serving bundle generation %s from %s\n", generation, rs.BundleCache)
+	}
+
+	load()
+
+	ticker := time.NewTicker(rs.BundleCachePoll)
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				load()
+			case <-quit:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,56 @@
+package reseed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// CheckDiskSpace returns ErrInsufficientDiskSpace if the file system of dir
+// has less than need bytes available. Platforms that can't tell are not
+// checked.
+func CheckDiskSpace(dir string, need uint64) error {
+	avail, ok, err := availableBytes(dir)
+	if nil != err {
+		return fmt.Errorf("%s: %w", dir, err)
+	}
+	if !ok || avail >= need {
+		return nil
+	}
+
+	return fmt.Errorf("%w in %s: need %d bytes, %d available (%d short)", ErrInsufficientDiskSpace, dir, need, avail, need-avail)
+}
+
+// WriteFileAtomic writes data to path after checking there is room for it.
+// The data goes to a temporary file that replaces path once complete, so a
+// failed write never leaves a partial file behind.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := CheckDiskSpace(dir, uint64(len(data))); nil != err {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path)+".tmp-")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); nil != err {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); nil != err {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
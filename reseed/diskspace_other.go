@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package reseed
+
+// availableBytes can't query the file system on this platform
+func availableBytes(dir string) (uint64, bool, error) {
+	return 0, false, nil
+}
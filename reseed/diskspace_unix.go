@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package reseed
+
+import (
+	"syscall"
+)
+
+// availableBytes returns the bytes of the file system of dir available to
+// unprivileged users
+func availableBytes(dir string) (uint64, bool, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); nil != err {
+		return 0, false, err
+	}
+
+	return uint64(st.Bavail) * uint64(st.Bsize), true, nil
+}
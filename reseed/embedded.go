@@ -0,0 +1,66 @@
+package reseed
+
+import (
+	"embed"
+	"errors"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+)
+
+//go:embed bootstrap
+var bootstrapNetDb embed.FS
+
+// ErrNoEmbeddedRouterInfos is returned by NewEmbeddedNetDb for binaries built
+// without routerInfos in the bootstrap directory
+var ErrNoEmbeddedRouterInfos = errors.New("no routerInfos were compiled into this binary, add them to reseed/bootstrap before building")
+
+// EmbeddedNetDbImpl serves the routerInfos compiled into the binary from the
+// bootstrap directory.
+type EmbeddedNetDbImpl struct{}
+
+func NewEmbeddedNetDb() (*EmbeddedNetDbImpl, error) {
+	db := &EmbeddedNetDbImpl{}
+	if ris, err := db.RouterInfos(); nil != err {
+		return nil, err
+	} else if len(ris) == 0 {
+		return nil, ErrNoEmbeddedRouterInfos
+	}
+
+	return db, nil
+}
+
+func (db *EmbeddedNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
+	r, _ := regexp.Compile("^routerInfo-[A-Za-z0-9-=~]+.dat$")
+
+	err = fs.WalkDir(bootstrapNetDb, "bootstrap", func(p string, d fs.DirEntry, err error) error {
+		if nil != err || d.IsDir() || !r.MatchString(d.Name()) {
+			return err
+		}
+
+		riBytes, err := bootstrapNetDb.ReadFile(p)
+		if nil != err {
+			return err
+		}
+
+		// the embedded files have no modification time
+		info, err := router.ParseRouterInfo(riBytes)
+		if nil != err {
+			log.Printf("Skipping embedded %s: %s\n", path.Base(p), err)
+			return nil
+		}
+
+		routerInfos = append(routerInfos, routerInfo{
+			Name:    d.Name(),
+			ModTime: info.Published,
+			Data:    riBytes,
+			Info:    info,
+		})
+		return nil
+	})
+
+	return
+}
@@ -0,0 +1,23 @@
+package reseed
+
+import (
+	"errors"
+)
+
+// errors returned by the reseeder, possibly wrapped with more details
+var (
+	// the netDb provider found no routerInfos at all
+	ErrEmptyNetDb = errors.New("No routerInfos found in the netDb")
+	// fewer routerInfos than needed for a single su3 file
+	ErrNotEnoughRouterInfos = errors.New("Not enough routerInfos")
+	// an su3 file exceeds MaxBundleBytes
+	ErrBundleTooLarge = errors.New("su3 file too large")
+	// no su3 files were built for a profile yet
+	ErrNoSu3 = errors.New("No su3 files available")
+	// no certificate of the signer of an su3 file
+	ErrNoSignerCertificate = errors.New("Unknown signer")
+	// a freshly built su3 file doesn't verify with our own certificate
+	ErrSelfVerification = errors.New("su3 file doesn't verify with the signing certificate")
+	// not enough free space to write a file
+	ErrInsufficientDiskSpace = errors.New("insufficient disk space")
+)
@@ -0,0 +1,77 @@
+package reseed
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// RebuildHook runs an external command after each rebuild. The new su3 files
+// are written to a temporary directory in TempDir, or the system temp dir,
+// which is removed once the command exits, even if it fails.
+//
+// The command is called as `Command <dir> <hash>` and also gets
+// RESEED_BUNDLE_DIR, RESEED_BUNDLE_HASH and RESEED_BUNDLE_COUNT in its environment.
+type RebuildHook struct {
+	Command string
+	Timeout time.Duration
+	TempDir string
+}
+
+func (h *RebuildHook) Run(su3s [][]byte) {
+	if err := h.run(su3s); nil != err {
+		log.Printf("Rebuild hook '%s' failed: %s\n", h.Command, err)
+	}
+}
+
+func (h *RebuildHook) run(su3s [][]byte) error {
+	dir, err := ioutil.TempDir(h.TempDir, "i2pseeds")
+	if nil != err {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	var size uint64
+	for _, data := range su3s {
+		size += uint64(len(data))
+	}
+	if err := CheckDiskSpace(dir, size); nil != err {
+		return err
+	}
+
+	hash := sha256.New()
+	for i, data := range su3s {
+		hash.Write(data)
+		if err := ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("i2pseeds-%03d.su3", i)), data, 0644); nil != err {
+			return err
+		}
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, h.Command, dir, sum)
+	cmd.Env = append(os.Environ(),
+		"RESEED_BUNDLE_DIR="+dir,
+		"RESEED_BUNDLE_HASH="+sum,
+		"RESEED_BUNDLE_COUNT="+strconv.Itoa(len(su3s)),
+	)
+	out, err := cmd.CombinedOutput()
+	if len(out) > 0 {
+		log.Printf("Rebuild hook output: %s\n", out)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", h.Timeout)
+	}
+
+	return err
+}
@@ -0,0 +1,59 @@
+package reseed
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"sort"
+)
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head><title>I2P reseed</title></head>
+<body>
+<h1>I2P reseed</h1>
+<p>This server helps new <a href="https://geti2p.net/">I2P</a> routers find their first peers.
+Routers download the reseed files automatically, there is nothing to do here.</p>
+<ul>
+{{range .Su3s}}<li>{{.Name}}: <a href="{{.URL}}">{{.URL}}</a></li>
+{{end}}<li>Signing certificate: <a href="{{.URL}}/reseed.crt">{{.URL}}/reseed.crt</a></li>
+</ul>
+</body>
+</html>
+`))
+
+type indexLink struct {
+	Name, URL string
+}
+
+func (s *Server) indexHandler(w http.ResponseWriter, r *http.Request) {
+	// relative links if we don't know our public URL
+	base := s.PublicURL
+	if base == "" {
+		base = s.prefix
+	}
+
+	var names []string
+	for name := range s.su3Paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var su3s []indexLink
+	for _, name := range names {
+		su3s = append(su3s, indexLink{Name: name, URL: base + s.su3Paths[name]})
+	}
+
+	data := struct {
+		URL  string
+		Su3s []indexLink
+	}{base, su3s}
+
+	var page *renderedPage
+	if pc := s.pages(); nil != pc {
+		page = &pc.index
+	}
+	servePage(w, r, page, "text/html; charset=utf-8", func(w io.Writer) error {
+		return indexTemplate.Execute(w, data)
+	})
+}
@@ -0,0 +1,137 @@
+package reseed
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/martin61/i2p-tools/su3"
+)
+
+const (
+	// where routers from before su3 files download the routerInfos from
+	LEGACY_PATH = "/netDb/"
+)
+
+var legacyIndexTemplate = template.Must(template.New("legacy").Parse(`<!DOCTYPE html>
+<html>
+<head><title>netDb</title></head>
+<body>
+{{range .}}<a href="{{.}}">{{.}}</a><br>
+{{end}}</body>
+</html>
+`))
+
+// LegacyReseeder is implemented by reseeders that can serve the routerInfos
+// of their su3 files one by one
+type LegacyReseeder interface {
+	// the routerInfo files of the su3 file a peer gets by name and when
+	// they were built
+	PeerRouterInfoFiles(peer Peer) (map[string][]byte, time.Time, error)
+}
+
+// legacyFiles are the routerInfos of an su3 file, extracted on first use
+type legacyFiles struct {
+	once  sync.Once
+	files map[string][]byte
+	err   error
+}
+
+// PeerRouterInfoFiles returns the routerInfos in the su3 file of the default
+// profile the peer gets, so a legacy reseed hands out no more routers than
+// an su3 one
+func (rs *ReseederImpl) PeerRouterInfoFiles(peer Peer) (map[string][]byte, time.Time, error) {
+	m := rs.cache()
+
+	if nil == m || nil == m.profiles[DEFAULT_PROFILE] || 0 == len(m.profiles[DEFAULT_PROFILE].su3s) {
+		return nil, time.Time{}, ErrNoSu3
+	}
+
+	i := peer.Hash() % len(m.profiles[DEFAULT_PROFILE].su3s)
+	legacy := &m.legacy[i]
+	legacy.once.Do(func() {
+		su3File, err := su3.Parse(m.profiles[DEFAULT_PROFILE].su3s[i])
+		if nil != err {
+			legacy.err = err
+			return
+		}
+		seeds, err := uzipSeeds(su3File.Content)
+		if nil != err {
+			legacy.err = err
+			return
+		}
+		legacy.files = make(map[string][]byte)
+		for _, seed := range seeds {
+			if seed.Name != MANIFEST_NAME {
+				legacy.files[seed.Name] = seed.Data
+			}
+		}
+	})
+
+	return legacy.files, m.built, legacy.err
+}
+
+// HandleLegacy serves the routerInfos of the su3 files one by one with a
+// directory index at LEGACY_PATH, for routers from before su3 files. Only
+// the index counts against the rate limit of the su3 paths, a reseed takes
+// one request per routerInfo.
+func (s *Server) HandleLegacy() {
+	index := s.reseedChain.Then(http.HandlerFunc(s.legacyHandler))
+	files := s.peerChain.Then(http.HandlerFunc(s.legacyHandler))
+	s.mux.Handle(s.prefix+LEGACY_PATH, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			index.ServeHTTP(w, r)
+			return
+		}
+		files.ServeHTTP(w, r)
+	}))
+}
+
+func (s *Server) legacyHandler(w http.ResponseWriter, r *http.Request) {
+	if s.Maintenance() {
+		w.Header().Set("Retry-After", fmt.Sprint(int(MAINTENANCE_RETRY_AFTER.Seconds())))
+		http.Error(w, "503 Down for maintenance, please try again later", http.StatusServiceUnavailable)
+		return
+	}
+	legacy, ok := s.Reseeder.(LegacyReseeder)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	files, built, err := legacy.PeerRouterInfoFiles(Peer(remoteIp(r)))
+	if nil != err {
+		logRequest(r, "Unable to serve the legacy netDb: %s", err)
+		http.Error(w, "500 Unable to serve routerInfos", http.StatusInternalServerError)
+		return
+	}
+
+	name := path.Base(r.URL.Path)
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		data, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, name, built, bytes.NewReader(data))
+		return
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := legacyIndexTemplate.Execute(w, names); nil != err {
+		logRequest(r, "%s", err)
+	}
+}
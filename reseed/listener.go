@@ -0,0 +1,150 @@
+package reseed
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+
+	"github.com/justinas/alice"
+)
+
+// the handler sets a Listener can serve
+const (
+	// the su3 files, the signing certificate and the index, what routers use
+	HANDLERS_PUBLIC = "public"
+	// stats.json, version, healthz, rebuilds, maintenance and revocations for operators
+	HANDLERS_ADMIN = "admin"
+
+	// admin listeners without a host (ex. ":6060") only accept local connections
+	DEFAULT_ADMIN_HOST = "127.0.0.1"
+)
+
+// Listener binds a handler set to an address with its own TLS settings, so
+// the public and the operator endpoints can be served on separate ports
+type Listener struct {
+	// HANDLERS_PUBLIC or HANDLERS_ADMIN
+	Handlers string
+	// host:port or unix:/path/to/sock
+	Addr string
+	// serve TLS with this certificate and key, plain HTTP if both are empty.
+	// The same file for both is read as a combined PEM bundle.
+	CertFile, KeyFile string
+	// also serve the net/http/pprof handlers, admin only
+	Pprof bool
+}
+
+// ListenAndServeListener serves the handler set of l on its address until
+// the server is closed
+func (srv *Server) ListenAndServeListener(l Listener) error {
+	var handler http.Handler
+	var config *tls.Config
+	switch l.Handlers {
+	case HANDLERS_PUBLIC:
+		handler = srv.Handler
+		if nil != srv.Blacklist {
+			handler = srv.blacklistMiddleware(handler)
+		}
+		config = &tls.Config{}
+		if nil != srv.TLSConfig {
+			config = srv.TLSConfig.Clone()
+		}
+	case HANDLERS_ADMIN:
+		handler = srv.adminHandler(l.Pprof)
+		config = &tls.Config{MinVersion: tls.VersionTLS12}
+		l.Addr = AdminListenAddr(l.Addr)
+		if !privateAddr(l.Addr) && !srv.AdminAuth.enabled() {
+			log.Printf("WARNING: the admin endpoints on %s are reachable from public addresses without auth, anyone can trigger rebuilds and read the stats", l.Addr)
+		}
+	default:
+		return fmt.Errorf("Unknown handler set '%s', must be '%s' or '%s'", l.Handlers, HANDLERS_PUBLIC, HANDLERS_ADMIN)
+	}
+	if (l.CertFile == "") != (l.KeyFile == "") {
+		return fmt.Errorf("%s listener on %s: the TLS certificate and key must be set together", l.Handlers, l.Addr)
+	}
+
+	socketMode := srv.SocketMode
+	if socketMode == 0 {
+		socketMode = DEFAULT_SOCKET_MODE
+	}
+	ln, err := listen(l.Addr, socketMode)
+	if nil != err {
+		return err
+	}
+
+	h := &http.Server{Handler: handler, ErrorLog: srv.ErrorLog}
+	srv.listenersMu.Lock()
+	srv.listeners = append(srv.listeners, h)
+	srv.listenersMu.Unlock()
+
+	if l.CertFile == "" {
+		return h.Serve(ln)
+	}
+
+	cert, err := LoadKeyPair(l.CertFile, l.KeyFile)
+	if nil != err {
+		ln.Close()
+		return err
+	}
+	config.Certificates = []tls.Certificate{cert}
+	config.NextProtos = []string{"http/1.1"}
+
+	return h.Serve(tls.NewListener(ln, config))
+}
+
+// AdminListenAddr binds an admin address without a host to DEFAULT_ADMIN_HOST
+func AdminListenAddr(addr string) string {
+	if strings.HasPrefix(addr, UNIX_PREFIX) {
+		return addr
+	}
+	if host, port, err := net.SplitHostPort(addr); nil == err && host == "" {
+		return net.JoinHostPort(DEFAULT_ADMIN_HOST, port)
+	}
+
+	return addr
+}
+
+// privateAddr reports whether a listen address is only reachable from this
+// host or a private network
+func privateAddr(addr string) bool {
+	if strings.HasPrefix(addr, UNIX_PREFIX) {
+		return true
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if nil != err {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(addrIp(addr))
+
+	return nil != ip && (ip.IsLoopback() || ip.IsPrivate())
+}
+
+// adminHandler routes the operator endpoints, with the net/http/pprof
+// profiling handlers if withPprof is set
+func (srv *Server) adminHandler(withPprof bool) http.Handler {
+	adminChain := alice.New(requestIdMiddleware, srv.loggingMiddleware, srv.adminMiddleware)
+
+	mux := http.NewServeMux()
+	mux.Handle("/stats.json", adminChain.Then(http.HandlerFunc(srv.statsHandler)))
+	mux.Handle("/metrics", adminChain.Then(http.HandlerFunc(srv.metricsHandler)))
+	mux.Handle("/version", adminChain.Then(http.HandlerFunc(srv.versionHandler)))
+	mux.Handle("/admin/rebuild", adminChain.Then(http.HandlerFunc(srv.rebuildHandler)))
+	mux.Handle("/admin/maintenance", adminChain.Then(http.HandlerFunc(srv.maintenanceHandler)))
+	mux.Handle("/admin/revoke", adminChain.Then(http.HandlerFunc(srv.revokeHandler)))
+	mux.Handle("/healthz", http.HandlerFunc(srv.healthHandler))
+	if withPprof {
+		mux.Handle("/debug/pprof/", adminChain.Then(http.HandlerFunc(pprof.Index)))
+		mux.Handle("/debug/pprof/cmdline", adminChain.Then(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("/debug/pprof/profile", adminChain.Then(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("/debug/pprof/symbol", adminChain.Then(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("/debug/pprof/trace", adminChain.Then(http.HandlerFunc(pprof.Trace)))
+	}
+
+	return mux
+}
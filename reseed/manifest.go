@@ -0,0 +1,72 @@
+package reseed
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// name of the manifest in the su3 zip, routers only import routerInfo-*.dat files
+	MANIFEST_NAME = "info.json"
+)
+
+// Manifest describes the routerInfos of an su3 file
+type Manifest struct {
+	Built   time.Time `json:"built"`
+	Signer  string    `json:"signer"`
+	Count   int       `json:"count"`
+	Routers []string  `json:"routers"`
+}
+
+func newManifest(seeds []routerInfo, signer string) routerInfo {
+	manifest := Manifest{Built: time.Now().UTC(), Signer: signer, Count: len(seeds)}
+	for _, seed := range seeds {
+		if nil != seed.Info {
+			manifest.Routers = append(manifest.Routers, seed.Info.HashBase64())
+		} else {
+			manifest.Routers = append(manifest.Routers, strings.TrimSuffix(strings.TrimPrefix(seed.Name, "routerInfo-"), ".dat"))
+		}
+	}
+	sort.Strings(manifest.Routers)
+
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+
+	return routerInfo{Name: MANIFEST_NAME, ModTime: manifest.Built, Data: data}
+}
+
+// ReadManifest returns the manifest of a reseed zip, or nil if it has none
+func ReadManifest(zipped []byte) (*Manifest, error) {
+	zipReader, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+	if nil != err {
+		return nil, err
+	}
+
+	for _, f := range zipReader.File {
+		if f.Name != MANIFEST_NAME {
+			continue
+		}
+
+		rc, err := f.Open()
+		if nil != err {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if nil != err {
+			return nil, err
+		}
+
+		var manifest Manifest
+		if err := json.Unmarshal(data, &manifest); nil != err {
+			return nil, err
+		}
+		return &manifest, nil
+	}
+
+	return nil, nil
+}
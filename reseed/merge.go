@@ -0,0 +1,55 @@
+package reseed
+
+import (
+	"compress/flate"
+	"sort"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+)
+
+// MergeSeeds merges the routerInfos of several reseed zips into one. Routers
+// in more than one zip are included once, using the newest copy. The result
+// is sorted by name so the same input always gives the same zip.
+func MergeSeeds(zips ...[]byte) (merged []byte, unique, duplicates int, err error) {
+	newest := make(map[string]routerInfo)
+	for _, zipped := range zips {
+		seeds, err := uzipSeeds(zipped)
+		if nil != err {
+			return nil, 0, 0, err
+		}
+
+		for _, seed := range seeds {
+			if seed.Name == MANIFEST_NAME {
+				continue
+			}
+
+			key := seed.Name
+			if info, err := router.ParseRouterInfo(seed.Data); nil == err {
+				seed.Info = info
+				key = info.HashBase64()
+			}
+
+			current, found := newest[key]
+			if found {
+				duplicates++
+			}
+			if !found || seed.published().After(current.published()) {
+				newest[key] = seed
+			}
+		}
+	}
+
+	var seeds []routerInfo
+	for _, seed := range newest {
+		seed.ModTime = seed.published()
+		seeds = append(seeds, seed)
+	}
+	sort.Slice(seeds, func(i, j int) bool { return seeds[i].Name < seeds[j].Name })
+
+	merged, err = zipSeeds(seeds, flate.DefaultCompression)
+	if nil != err {
+		return nil, 0, 0, err
+	}
+
+	return merged, len(seeds), duplicates, nil
+}
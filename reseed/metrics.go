@@ -0,0 +1,157 @@
+package reseed
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	METRICS_CONTENT_TYPE     = "text/plain; version=0.0.4; charset=utf-8"
+	OPENMETRICS_CONTENT_TYPE = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// metric is a family of samples in the Prometheus exposition formats
+type metric struct {
+	name    string
+	help    string
+	typ     string
+	samples []metricSample
+}
+
+type metricSample struct {
+	// label name and value pairs
+	labels []string
+	value  float64
+}
+
+// metrics returns the stats as metric families
+func (s *Server) metrics() []metric {
+	stats := s.Reseeder.Stats()
+	build := GetBuildInfo()
+
+	var profiles []string
+	for name := range stats.Profiles {
+		profiles = append(profiles, name)
+	}
+	sort.Strings(profiles)
+	su3s := metric{name: "reseed_su3_files", help: "su3 files served by profile.", typ: "gauge"}
+	ris := metric{name: "reseed_profile_router_infos", help: "routerInfos in the su3 files by profile.", typ: "gauge"}
+	for _, name := range profiles {
+		su3s.samples = append(su3s.samples, metricSample{[]string{"profile", name}, float64(stats.Profiles[name].NumSu3)})
+		ris.samples = append(ris.samples, metricSample{[]string{"profile", name}, float64(stats.Profiles[name].NumRi)})
+	}
+
+	var lastRebuild float64
+	if !stats.LastRebuild.IsZero() {
+		lastRebuild = float64(stats.LastRebuild.UnixNano()) / 1e9
+	}
+	var banned, maintenance float64
+	if nil != s.Banlist {
+		banned = float64(s.Banlist.Banned())
+	}
+	if s.Maintenance() {
+		maintenance = 1
+	}
+
+	return []metric{
+		{"reseed_build_info", "Version of the reseed server.", "info", []metricSample{{[]string{"version", build.Version, "commit", build.Commit, "goversion", build.GoVersion}, 1}}},
+		su3s,
+		ris,
+		{"reseed_router_infos", "routerInfos the su3 files were built from.", "gauge", []metricSample{{nil, float64(stats.NumRi)}}},
+		{"reseed_last_rebuild_timestamp_seconds", "Time of the last rebuild.", "gauge", []metricSample{{nil, lastRebuild}}},
+		{"reseed_self_verification_failures_total", "Rebuilds rejected because an su3 file didn't verify.", "counter", []metricSample{{nil, float64(stats.SelfVerificationFailures)}}},
+		{"reseed_banned_clients", "Clients on the banlist.", "gauge", []metricSample{{nil, banned}}},
+		{"reseed_maintenance", "1 while in maintenance.", "gauge", []metricSample{{nil, maintenance}}},
+	}
+}
+
+// acceptsOpenMetrics reports if the Accept header asks for OpenMetrics, as
+// Prometheus does from 2.5 on
+func acceptsOpenMetrics(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if nil != err || mediaType != "application/openmetrics-text" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); nil == err && q == 0 {
+			continue
+		}
+		return true
+	}
+
+	return false
+}
+
+// writeMetrics writes the metrics in the Prometheus text format, where info
+// metrics are gauges, or in OpenMetrics with counter and info families named
+// without _total and _info and the # EOF marker. No metric has exemplars.
+func writeMetrics(w *bytes.Buffer, metrics []metric, openMetrics bool) {
+	for _, m := range metrics {
+		family, typ := m.name, m.typ
+		switch {
+		case openMetrics && typ == "counter":
+			family = strings.TrimSuffix(family, "_total")
+		case openMetrics && typ == "info":
+			family = strings.TrimSuffix(family, "_info")
+		case typ == "info":
+			typ = "gauge"
+		}
+		fmt.Fprintf(w, "# HELP %s %s\n", family, escapeMetric(m.help, false))
+		fmt.Fprintf(w, "# TYPE %s %s\n", family, typ)
+
+		for _, sample := range m.samples {
+			w.WriteString(m.name)
+			if len(sample.labels) > 0 {
+				w.WriteByte('{')
+				for i := 0; i < len(sample.labels); i += 2 {
+					if i > 0 {
+						w.WriteByte(',')
+					}
+					fmt.Fprintf(w, `%s="%s"`, sample.labels[i], escapeMetric(sample.labels[i+1], true))
+				}
+				w.WriteByte('}')
+			}
+			fmt.Fprintf(w, " %s\n", strconv.FormatFloat(sample.value, 'g', -1, 64))
+		}
+	}
+
+	if openMetrics {
+		w.WriteString("# EOF\n")
+	}
+}
+
+// escapeMetric escapes backslashes and new lines, and quotes in label values
+func escapeMetric(s string, labelValue bool) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	s = strings.Replace(s, "\n", `\n`, -1)
+	if labelValue {
+		s = strings.Replace(s, `"`, `\"`, -1)
+	}
+
+	return s
+}
+
+// metricsHandler serves the stats for Prometheus, in OpenMetrics if the
+// client asks for it
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	openMetrics := acceptsOpenMetrics(r)
+
+	var buf bytes.Buffer
+	writeMetrics(&buf, s.metrics(), openMetrics)
+
+	if openMetrics {
+		w.Header().Set("Content-Type", OPENMETRICS_CONTENT_TYPE)
+	} else {
+		w.Header().Set("Content-Type", METRICS_CONTENT_TYPE)
+	}
+	w.Header().Add("Vary", "Accept")
+	if _, err := w.Write(buf.Bytes()); nil != err {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,92 @@
+package reseed
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+var testMetrics = []metric{
+	{"reseed_build_info", "Version of the reseed server.", "info", []metricSample{{[]string{"version", "1.0", "commit", `a"b\c`}, 1}}},
+	{"reseed_su3_files", "su3 files served\nby profile.", "gauge", []metricSample{{[]string{"profile", "default"}, 50}, {[]string{"profile", "mobile"}, 10}}},
+	{"reseed_last_rebuild_timestamp_seconds", "Time of the last rebuild.", "gauge", []metricSample{{nil, 1718000000.5}}},
+	{"reseed_self_verification_failures_total", "Rebuilds rejected.", "counter", []metricSample{{nil, 2}}},
+}
+
+const prometheusGolden = `# HELP reseed_build_info Version of the reseed server.
+# TYPE reseed_build_info gauge
+reseed_build_info{version="1.0",commit="a\"b\\c"} 1
+# HELP reseed_su3_files su3 files served\nby profile.
+# TYPE reseed_su3_files gauge
+reseed_su3_files{profile="default"} 50
+reseed_su3_files{profile="mobile"} 10
+# HELP reseed_last_rebuild_timestamp_seconds Time of the last rebuild.
+# TYPE reseed_last_rebuild_timestamp_seconds gauge
+reseed_last_rebuild_timestamp_seconds 1.7180000005e+09
+# HELP reseed_self_verification_failures_total Rebuilds rejected.
+# TYPE reseed_self_verification_failures_total counter
+reseed_self_verification_failures_total 2
+`
+
+const openMetricsGolden = `# HELP reseed_build Version of the reseed server.
+# TYPE reseed_build info
+reseed_build_info{version="1.0",commit="a\"b\\c"} 1
+# HELP reseed_su3_files su3 files served\nby profile.
+# TYPE reseed_su3_files gauge
+reseed_su3_files{profile="default"} 50
+reseed_su3_files{profile="mobile"} 10
+# HELP reseed_last_rebuild_timestamp_seconds Time of the last rebuild.
+# TYPE reseed_last_rebuild_timestamp_seconds gauge
+reseed_last_rebuild_timestamp_seconds 1.7180000005e+09
+# HELP reseed_self_verification_failures Rebuilds rejected.
+# TYPE reseed_self_verification_failures counter
+reseed_self_verification_failures_total 2
+# EOF
+`
+
+func TestWriteMetrics(t *testing.T) {
+	tests := []struct {
+		name        string
+		openMetrics bool
+		golden      string
+	}{
+		{"Prometheus", false, prometheusGolden},
+		{"OpenMetrics", true, openMetricsGolden},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		writeMetrics(&buf, testMetrics, test.openMetrics)
+		if buf.String() != test.golden {
+			t.Errorf("%s: got\n%s\nexpected\n%s", test.name, buf.String(), test.golden)
+		}
+	}
+}
+
+func TestAcceptsOpenMetrics(t *testing.T) {
+	tests := []struct {
+		accept      string
+		openMetrics bool
+	}{
+		{"", false},
+		{"text/plain", false},
+		{"application/openmetrics-text", true},
+		// Prometheus 2.x
+		{"application/openmetrics-text;version=1.0.0,application/openmetrics-text;version=0.0.1;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", true},
+		{"text/plain;version=0.0.4, application/openmetrics-text; q=0.5", true},
+		{"application/openmetrics-text;q=0", false},
+		{"application/openmetrics-text;q=0.0, text/plain", false},
+		{"application/openmetrics-text;q=0.001", true},
+		{"application/openmetrics-text;;", false},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		if test.accept != "" {
+			r.Header.Set("Accept", test.accept)
+		}
+		if openMetrics := acceptsOpenMetrics(r); openMetrics != test.openMetrics {
+			t.Errorf("%q: openMetrics is %t", test.accept, openMetrics)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package reseed
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/martin61/i2p-tools/internal/testutil"
+)
+
+// publishedHoursAgo publishes router i i and a half hours ago, routers 192
+// and later are older than the 192 hours routerInfos are served
+func publishedHoursAgo(now time.Time) func(i int) time.Time {
+	return func(i int) time.Time {
+		return now.Add(-time.Duration(i)*time.Hour - 30*time.Minute).Truncate(time.Second)
+	}
+}
+
+func TestLocalNetDbRouterInfos(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	routers, err := testutil.WriteNetDb(dir, 200, testutil.NetDbOptions{Published: publishedHoursAgo(now)})
+	if nil != err {
+		t.Fatal(err)
+	}
+	// not routerInfos
+	if err := ioutil.WriteFile(filepath.Join(dir, "routerInfo-A.dat.tmp"), []byte("partial"), 0644); nil != err {
+		t.Fatal(err)
+	}
+
+	db := NewLocalNetDb(dir)
+	ris, err := db.RouterInfos()
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]routerInfo)
+	for _, ri := range ris {
+		byName[ri.Name] = ri
+	}
+	if len(byName) != len(ris) {
+		t.Errorf("%d routerInfos with %d names", len(ris), len(byName))
+	}
+	for i, r := range routers {
+		ri, ok := byName[r.Name]
+		if i >= 192 {
+			if ok {
+				t.Errorf("router %d published %s ago was read", i, now.Sub(r.Published).Round(time.Minute))
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("router %d is missing", i)
+			continue
+		}
+		if nil == ri.Info {
+			t.Errorf("router %d wasn't parsed", i)
+			continue
+		}
+		if ri.Info.HashBase64() != r.Name[len("routerInfo-"):len(r.Name)-len(".dat")] {
+			t.Errorf("router %d has hash %s", i, ri.Info.HashBase64())
+		}
+		if !ri.ModTime.Equal(r.Published) {
+			t.Errorf("router %d modified %s, published %s", i, ri.ModTime, r.Published)
+		}
+	}
+	if len(ris) != 192 {
+		t.Errorf("%d routerInfos read, 192 expected", len(ris))
+	}
+
+	// a changed file is read again, a removed one is dropped
+	changed, err := testutil.WriteNetDb(t.TempDir(), 1, testutil.NetDbOptions{Published: func(int) time.Time { return now }, Options: map[string]string{"caps": "XR", "netId": "2"}})
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(routers[0].Path, changed[0].Data, 0644); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(routers[0].Path, now.Add(time.Second), now.Add(time.Second)); nil != err {
+		t.Fatal(err)
+	}
+	if err := os.Remove(routers[1].Path); nil != err {
+		t.Fatal(err)
+	}
+
+	ris, err = db.RouterInfos()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ris) != 191 {
+		t.Errorf("%d routerInfos read after removing one, 191 expected", len(ris))
+	}
+	for _, ri := range ris {
+		if ri.Name == routers[1].Name {
+			t.Error("the removed router was read")
+		}
+		if ri.Name == routers[0].Name && ri.Info.Options["caps"] != "XR" {
+			t.Errorf("the changed router has caps %s", ri.Info.Options["caps"])
+		}
+	}
+}
+
+func TestMultiNetDbRouterInfos(t *testing.T) {
+	now := time.Now()
+	older := testutil.TempNetDb(t, 10, testutil.NetDbOptions{Published: func(i int) time.Time { return now.Add(-time.Hour) }, Options: map[string]string{"caps": "LR", "netId": "2"}})
+	newer := testutil.TempNetDb(t, 5, testutil.NetDbOptions{Published: func(i int) time.Time { return now }, Options: map[string]string{"caps": "XR", "netId": "2"}})
+
+	ris, err := NewMultiNetDb([]string{newer, older}).RouterInfos()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(ris) != 10 {
+		t.Fatalf("%d routerInfos merged, 10 expected", len(ris))
+	}
+
+	// routers 0-4 are in both and the newer copy is kept
+	xr := 0
+	for _, ri := range ris {
+		if ri.Info.Options["caps"] == "XR" {
+			xr++
+		}
+	}
+	if xr != 5 {
+		t.Errorf("%d routerInfos from the newer netDb, 5 expected", xr)
+	}
+}
+
+func TestLocalNetDbMaxCached(t *testing.T) {
+	now := time.Now()
+	dir := testutil.TempNetDb(t, 20, testutil.NetDbOptions{Published: publishedHoursAgo(now)})
+
+	db := NewLocalNetDb(dir)
+	db.MaxCached = 5
+	for scan := 0; scan < 3; scan++ {
+		ris, err := db.RouterInfos()
+		if nil != err {
+			t.Fatal(err)
+		}
+		if len(ris) != 20 {
+			t.Fatalf("%d routerInfos read, 20 expected", len(ris))
+		}
+
+		// always the newest, routers 0-4
+		if len(db.cache) != 5 {
+			t.Fatalf("%d routerInfos cached", len(db.cache))
+		}
+		for path, ri := range db.cache {
+			if now.Sub(ri.ModTime) > 5*time.Hour {
+				t.Errorf("scan %d cached %s published %s ago", scan, filepath.Base(path), now.Sub(ri.ModTime).Round(time.Minute))
+			}
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package reseed
+
+import (
+	"log"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DEFAULT_SCANNER_PATHS are requested by web scanners, never by routers. A
+// pattern ending in / matches everything below it, others are path.Match
+// patterns of the whole path.
+var DEFAULT_SCANNER_PATHS = []string{
+	"/wp-login.php",
+	"/xmlrpc.php",
+	"/*.php",
+	"/.env",
+	"/wp-admin/",
+	"/wp-content/",
+	"/wp-includes/",
+	"/.git/",
+	"/phpmyadmin/",
+	"/cgi-bin/",
+	"/vendor/",
+	"/actuator/",
+}
+
+// scannerPath reports if p matches one of patterns
+func scannerPath(patterns []string, p string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "/") {
+			if strings.HasPrefix(p, pattern) {
+				return true
+			}
+		} else if ok, _ := path.Match(pattern, p); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// scannerMiddleware closes the connection of requests for ScannerPaths
+// without a response, like the 444 of nginx. They are not logged.
+func (s *Server) scannerMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !scannerPath(s.ScannerPaths, r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if hj, ok := w.(http.Hijacker); ok {
+			if conn, _, err := hj.Hijack(); nil == err {
+				conn.Close()
+				return
+			}
+		}
+		// HTTP/2 streams can't be hijacked, reset the stream instead
+		panic(http.ErrAbortHandler)
+	})
+}
+
+// notFoundHandler answers requests for unknown paths with NotFoundRedirect,
+// or NotFoundBody and NotFoundStatus
+func (s *Server) notFoundHandler(w http.ResponseWriter, r *http.Request) {
+	if s.NotFoundRedirect != "" {
+		http.Redirect(w, r, s.NotFoundRedirect, http.StatusFound)
+		return
+	}
+
+	status := s.NotFoundStatus
+	if status == 0 {
+		status = http.StatusNotFound
+	}
+	if len(s.NotFoundBody) > 0 {
+		contentType := s.NotFoundContentType
+		if contentType == "" {
+			contentType = http.DetectContentType(s.NotFoundBody)
+		}
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	w.WriteHeader(status)
+	if _, err := w.Write(s.NotFoundBody); nil != err {
+		log.Println(err)
+	}
+}
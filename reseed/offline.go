@@ -0,0 +1,31 @@
+package reseed
+
+import (
+	"fmt"
+)
+
+// UnsignedContent picks NumRi routerInfos like a rebuild and returns their
+// zip without signing it, so it can be signed on a host without network
+func (rs *ReseederImpl) UnsignedContent() ([]byte, error) {
+	ris, err := rs.netdb.RouterInfos()
+	if nil != err {
+		return nil, fmt.Errorf("Unable to get routerInfos: %w", err)
+	}
+	if 0 == len(ris) {
+		return nil, ErrEmptyNetDb
+	}
+
+	if rs.VerifyRouterInfos {
+		ris = verifiedRouterInfos(ris)
+	}
+
+	// use only 75% of routerInfos
+	ris = ris[len(ris)/4:]
+	if rs.NumRi > len(ris) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughRouterInfos, len(ris), rs.NumRi)
+	}
+
+	seeds := <-rs.seedsProducer(ris, rs.NumRi, 1)
+
+	return rs.zipSeeds(seeds)
+}
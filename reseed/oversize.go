@@ -0,0 +1,73 @@
+package reseed
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/martin61/i2p-tools/su3"
+)
+
+const (
+	// drop routerInfos until the su3 file fits
+	OVERSIZE_TRIM = "trim"
+	// fail the rebuild
+	OVERSIZE_FAIL = "fail"
+)
+
+// trimSu3 rebuilds su3File with fewer seeds until it is no larger than
+// MaxBundleBytes. Reachable routers are kept first, then the newest.
+func (rs *ReseederImpl) trimSu3(su3File *su3.Su3File, seeds []routerInfo) (*su3.Su3File, error) {
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		return nil, err
+	}
+	if len(data) <= rs.MaxBundleBytes {
+		return su3File, nil
+	}
+
+	kept := make([]routerInfo, len(seeds))
+	copy(kept, seeds)
+	sort.SliceStable(kept, func(i, j int) bool {
+		ri, rj := reachable(kept[i]), reachable(kept[j])
+		if ri != rj {
+			return ri
+		}
+		return kept[i].published().After(kept[j].published())
+	})
+
+	originalSize := len(data)
+	for len(data) > rs.MaxBundleBytes {
+		// drop about as many routerInfos as the excess takes up
+		perSeed := len(su3File.Content) / len(kept)
+		drop := 1
+		if perSeed > 0 {
+			drop += (len(data) - rs.MaxBundleBytes) / perSeed
+		}
+		if drop >= len(kept) {
+			return nil, fmt.Errorf("su3 file of %d bytes can't be trimmed to %d bytes", originalSize, rs.MaxBundleBytes)
+		}
+		kept = kept[:len(kept)-drop]
+
+		zipped, err := rs.zipSeeds(kept)
+		if nil != err {
+			return nil, err
+		}
+		su3File.Content = zipped
+		if err := su3File.Sign(rs.SigningKey); nil != err {
+			return nil, err
+		}
+
+		if data, err = su3File.MarshalBinary(); nil != err {
+			return nil, err
+		}
+	}
+
+	log.Printf("Trimmed su3 file from %d bytes to %d bytes, dropped %d of %d routerInfos.\n", originalSize, len(data), len(seeds)-len(kept), len(seeds))
+
+	return su3File, nil
+}
+
+func reachable(ri routerInfo) bool {
+	return nil != ri.Info && ri.Info.Reachable()
+}
@@ -0,0 +1,129 @@
+package reseed
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/martin61/i2p-tools/internal/testutil"
+)
+
+// oversizeReseeder returns a reseeder and numRi routerInfos, every other
+// one firewalled
+func oversizeReseeder(t *testing.T, numRi int) (*ReseederImpl, []routerInfo) {
+	t.Helper()
+
+	dir := testutil.TempNetDb(t, numRi, testutil.NetDbOptions{Published: publishedHoursAgo(time.Now())})
+	ris, err := NewLocalNetDb(dir).RouterInfos()
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i := range ris {
+		if i%2 == 0 {
+			ris[i].Info.Addresses = nil
+		}
+	}
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	rs := NewReseeder(nil)
+	rs.SigningKey = priv
+	rs.SignerId = []byte("test@mail.i2p")
+	rs.NumRi = numRi
+
+	return rs, ris
+}
+
+func TestTrimSu3(t *testing.T) {
+	rs, ris := oversizeReseeder(t, 40)
+
+	full, err := rs.createSu3(ris)
+	if nil != err {
+		t.Fatal(err)
+	}
+	data, err := full.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	// room for fewer than the 20 reachable routers
+	rs.MaxBundleBytes = len(data) / 3
+	rs.OnOversize = OVERSIZE_TRIM
+	trimmed, err := rs.createSu3(ris)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if data, err = trimmed.MarshalBinary(); nil != err {
+		t.Fatal(err)
+	}
+	if len(data) > rs.MaxBundleBytes {
+		t.Errorf("trimmed su3 file has %d bytes, the maximum is %d", len(data), rs.MaxBundleBytes)
+	}
+
+	kept, err := uzipSeeds(trimmed.Content)
+	if nil != err {
+		t.Fatal(err)
+	}
+	var newestReachable []routerInfo
+	for _, ri := range ris {
+		if reachable(ri) {
+			newestReachable = append(newestReachable, ri)
+		}
+	}
+	sort.Slice(newestReachable, func(i, j int) bool {
+		return newestReachable[i].published().After(newestReachable[j].published())
+	})
+	if len(kept) == 0 || len(kept) >= len(newestReachable) {
+		t.Fatalf("kept %d routerInfos, expected between 1 and %d", len(kept), len(newestReachable)-1)
+	}
+	want := make(map[string]bool)
+	for _, ri := range newestReachable[:len(kept)] {
+		want[ri.Name] = true
+	}
+	for _, ri := range kept {
+		if !want[ri.Name] {
+			t.Errorf("kept %s, not one of the %d newest reachable routerInfos", ri.Name, len(kept))
+		}
+	}
+}
+
+func TestBuildProfileOversize(t *testing.T) {
+	rs, ris := oversizeReseeder(t, 20)
+
+	tests := []struct {
+		name           string
+		onOversize     string
+		maxBundleBytes int
+		err            error
+	}{
+		{"fail", OVERSIZE_FAIL, 1000, ErrBundleTooLarge},
+		{"trim impossible", OVERSIZE_TRIM, 100, nil},
+	}
+	for _, test := range tests {
+		rs.OnOversize = test.onOversize
+		rs.MaxBundleBytes = test.maxBundleBytes
+
+		pc, err := rs.buildProfile(Profile{Name: DEFAULT_PROFILE, NumSu3: 2}, ris)
+		if nil == err {
+			t.Errorf("%s: rebuild succeeded with %d su3 files", test.name, len(pc.su3s))
+			continue
+		}
+		if nil != test.err && !errors.Is(err, test.err) {
+			t.Errorf("%s: got error %v, expected %v", test.name, err, test.err)
+		}
+	}
+
+	rs.MaxBundleBytes = 0
+	pc, err := rs.buildProfile(Profile{Name: DEFAULT_PROFILE, NumSu3: 2}, ris)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(pc.su3s) != 2 {
+		t.Errorf("built %d su3 files, expected 2", len(pc.su3s))
+	}
+}
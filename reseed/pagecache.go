@@ -0,0 +1,140 @@
+package reseed
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ChangeCounter is implemented by reseeders that count the changes to what
+// they serve. The index and the stats are rendered once per change.
+type ChangeCounter interface {
+	Changes() uint64
+}
+
+// renderedPage is a response rendered on the first request, with its gzip
+// compressed form and ETag. It is never modified after being rendered, a
+// failed render is tried again by the next request.
+type renderedPage struct {
+	rendered    atomic.Bool
+	mu          sync.Mutex
+	contentType string
+	body        []byte
+	gzipped     []byte
+	etag        string
+}
+
+// pageCache holds the pages rendered for one change of the reseeder
+type pageCache struct {
+	changes uint64
+	index   renderedPage
+	// the *statsPage of the current ban count, which changes between
+	// rebuilds
+	stats atomic.Value
+}
+
+type statsPage struct {
+	banned int
+	page   renderedPage
+}
+
+// statsPage returns the stats page for a ban count, rendered again when it
+// changes
+func (pc *pageCache) statsPage(banned int) *renderedPage {
+	if sp, _ := pc.stats.Load().(*statsPage); nil != sp && sp.banned == banned {
+		return &sp.page
+	}
+
+	sp := &statsPage{banned: banned}
+	pc.stats.Store(sp)
+
+	return &sp.page
+}
+
+// pages returns the cached pages of the current change, nil if the reseeder
+// doesn't count its changes and every request has to be rendered
+func (s *Server) pages() *pageCache {
+	counter, ok := s.Reseeder.(ChangeCounter)
+	if !ok {
+		return nil
+	}
+
+	changes := counter.Changes()
+	if pc, _ := s.pageCache.Load().(*pageCache); nil != pc && pc.changes == changes {
+		return pc
+	}
+
+	// concurrent requests may render the same change twice, never serve
+	// an older one for long
+	pc := &pageCache{changes: changes}
+	s.pageCache.Store(pc)
+
+	return pc
+}
+
+// servePage renders the page on the first request and serves the cached
+// bytes, compressed for clients accepting gzip, to the following ones
+func servePage(w http.ResponseWriter, r *http.Request, page *renderedPage, contentType string, render func(io.Writer) error) {
+	if nil == page {
+		page = &renderedPage{}
+	}
+
+	if !page.rendered.Load() {
+		if err := page.render(contentType, render); nil != err {
+			log.Println(err)
+			http.Error(w, "500 Unable to render the page", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	body, etag := page.body, page.etag
+	w.Header().Set("Content-Type", page.contentType)
+	w.Header().Add("Vary", "Accept-Encoding")
+	if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+		// a different representation needs a different ETag
+		body, etag = page.gzipped, strings.TrimSuffix(page.etag, `"`)+`-gzip"`
+		w.Header().Set("Content-Encoding", "gzip")
+	}
+	w.Header().Set("ETag", etag)
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+}
+
+// render renders the page once, errors are not kept
+func (page *renderedPage) render(contentType string, render func(io.Writer) error) error {
+	page.mu.Lock()
+	defer page.mu.Unlock()
+
+	if page.rendered.Load() {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf); nil != err {
+		return err
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(buf.Bytes())
+	if err := zw.Close(); nil != err {
+		return err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	page.contentType = contentType
+	page.body = buf.Bytes()
+	page.gzipped = gz.Bytes()
+	page.etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	page.rendered.Store(true)
+
+	return nil
+}
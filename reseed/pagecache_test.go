@@ -0,0 +1,97 @@
+package reseed
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeReseeder serves no su3 files and counts its changes
+type fakeReseeder struct {
+	changes uint64
+	stats   Stats
+}
+
+func (f *fakeReseeder) PeerSu3Bytes(profile string, peer Peer) ([]byte, string, time.Time, error) {
+	return nil, "", time.Time{}, ErrNoSu3
+}
+
+func (f *fakeReseeder) Stats() Stats {
+	return f.stats
+}
+
+func (f *fakeReseeder) Changes() uint64 {
+	return f.changes
+}
+
+func TestServePageRetriesErrors(t *testing.T) {
+	page := &renderedPage{}
+	renders := 0
+	fail := true
+	render := func(w io.Writer) error {
+		renders++
+		if fail {
+			return errors.New("template failed")
+		}
+		_, err := io.WriteString(w, "page")
+		return err
+	}
+
+	w := httptest.NewRecorder()
+	servePage(w, httptest.NewRequest("GET", "/", nil), page, "text/plain", render)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("status %d after a failed render", w.Code)
+	}
+
+	fail = false
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		servePage(w, httptest.NewRequest("GET", "/", nil), page, "text/plain", render)
+		if w.Code != http.StatusOK || w.Body.String() != "page" {
+			t.Fatalf("status %d, body %q", w.Code, w.Body.String())
+		}
+	}
+	if renders != 2 {
+		t.Errorf("rendered %d times, 2 expected", renders)
+	}
+}
+
+func TestStatsBanned(t *testing.T) {
+	reseeder := &fakeReseeder{stats: Stats{NumSu3: 3}}
+	s := &Server{Reseeder: reseeder, Banlist: NewBanlist(1, time.Minute, time.Hour, time.Hour, 100)}
+
+	banned := func() int {
+		w := httptest.NewRecorder()
+		s.statsHandler(w, httptest.NewRequest("GET", "/stats.json", nil))
+		var stats struct {
+			NumSu3 int `json:"numSu3"`
+			Banned int `json:"banned"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &stats); nil != err {
+			t.Fatal(err)
+		}
+		if stats.NumSu3 != reseeder.stats.NumSu3 {
+			t.Errorf("numSu3 %d, %d expected", stats.NumSu3, reseeder.stats.NumSu3)
+		}
+		return stats.Banned
+	}
+
+	if n := banned(); n != 0 {
+		t.Errorf("%d banned", n)
+	}
+	s.Banlist.Violation("192.0.2.1")
+	if n := banned(); n != 1 {
+		t.Errorf("%d banned after a ban without rebuild", n)
+	}
+
+	// the rest is only rendered again after a change
+	reseeder.stats.NumSu3 = 4
+	reseeder.changes++
+	if n := banned(); n != 1 {
+		t.Errorf("%d banned after a rebuild", n)
+	}
+}
@@ -0,0 +1,85 @@
+package reseed
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// the profile built from the reseeder's own settings
+	DEFAULT_PROFILE = "default"
+)
+
+// profile names are used as directory names in the bundle cache
+var profileNameRegexp = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+// Profile is a named set of su3 files built from the routerInfos matching
+// its filters. All profiles are built from the same netDb scan and signed
+// with the same key.
+type Profile struct {
+	Name string
+	// served at, relative to the server prefix
+	Path string
+	// routerInfos per su3 file, 0 uses the reseeder's NumRi
+	NumRi int
+	// number of su3 files, 0 is automatic
+	NumSu3 int
+	// skip routerInfos published longer ago, 0 is no limit
+	MaxAge time.Duration
+	// only routers with a published address
+	ReachableOnly bool
+	// only floodfill routers
+	FloodfillOnly bool
+}
+
+func (p *Profile) Validate() error {
+	if p.Name == "" || p.Name == DEFAULT_PROFILE {
+		return fmt.Errorf("A profile needs a name other than '%s'", DEFAULT_PROFILE)
+	}
+	if !profileNameRegexp.MatchString(p.Name) {
+		return fmt.Errorf("Profile '%s': names may only contain letters, digits, _ and -", p.Name)
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		return fmt.Errorf("Profile '%s': path must start with /", p.Name)
+	}
+	if p.NumRi < 0 || p.NumSu3 < 0 || p.MaxAge < 0 {
+		return fmt.Errorf("Profile '%s': numRi, numSu3 and maxAge can't be negative", p.Name)
+	}
+
+	return nil
+}
+
+// reasons a profile excludes a routerInfo
+const (
+	EXCLUDED_BY_AGE          = "age"
+	EXCLUDED_BY_REACHABILITY = "reachability"
+	EXCLUDED_BY_FLOODFILL    = "floodfill"
+)
+
+func (p *Profile) filter(ris []routerInfo) []routerInfo {
+	var filtered []routerInfo
+	for _, ri := range ris {
+		if p.excludes(ri) == "" {
+			filtered = append(filtered, ri)
+		}
+	}
+
+	return filtered
+}
+
+// excludes returns why the profile leaves out ri, or "" if it is included
+func (p *Profile) excludes(ri routerInfo) string {
+	if p.MaxAge > 0 && time.Since(ri.published()) > p.MaxAge {
+		return EXCLUDED_BY_AGE
+	}
+	if p.ReachableOnly && (nil == ri.Info || !ri.Info.Reachable()) {
+		return EXCLUDED_BY_REACHABILITY
+	}
+	if p.FloodfillOnly && (nil == ri.Info || !strings.Contains(ri.Info.Options["caps"], "f")) {
+		return EXCLUDED_BY_FLOODFILL
+	}
+
+	return ""
+}
@@ -0,0 +1,196 @@
+package reseed
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+	"github.com/martin61/i2p-tools/su3"
+)
+
+const (
+	// largest su3 file downloaded from another reseed server, far over the
+	// size of reseeds of a few hundred routerInfos
+	REMOTE_SU3_MAX_BYTES = 4 << 20
+)
+
+// RemoteNetDbImpl reads the routerInfos of su3 files downloaded from other
+// reseed servers or mirrors. Each su3 file is verified against the
+// certificates of its signer in KeyStore. Routers found in more than one
+// of them are included once, using the newest copy.
+type RemoteNetDbImpl struct {
+	URLs     []string
+	KeyStore KeyStore
+	Client   *http.Client
+}
+
+func NewRemoteNetDb(urls []string, certificates string) *RemoteNetDbImpl {
+	return &RemoteNetDbImpl{
+		URLs:     urls,
+		KeyStore: KeyStore{Path: certificates},
+		Client:   &http.Client{Timeout: time.Minute},
+	}
+}
+
+// NewProxyTransport returns a transport connecting through a socks5:// or
+// http:// proxy, ex. socks5://127.0.0.1:9050 for Tor or http://127.0.0.1:4444
+// for the I2P HTTP proxy. Host names are resolved by the proxy, the local
+// resolver never sees them.
+func NewProxyTransport(proxy string) (*http.Transport, error) {
+	u, err := url.Parse(proxy)
+	if nil != err {
+		return nil, err
+	}
+	if (u.Scheme != "socks5" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("'%s' is not a socks5:// or http:// proxy URL", proxy)
+	}
+
+	// circuits through Tor and tunnels through I2P take a while to build
+	return &http.Transport{
+		Proxy:                 http.ProxyURL(u),
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   30 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		DisableKeepAlives:     true,
+	}, nil
+}
+
+func (db *RemoteNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
+	newest := make(map[string]routerInfo)
+	for _, url := range db.URLs {
+		ris, err := db.fetch(url)
+		if nil != err {
+			log.Printf("Unable to fetch routerInfos from %s: %s\n", url, err)
+			continue
+		}
+		log.Printf("Fetched %d routerInfos from %s\n", len(ris), url)
+
+		for _, ri := range ris {
+			if current, ok := newest[ri.Name]; !ok || ri.published().After(current.published()) {
+				newest[ri.Name] = ri
+			}
+		}
+	}
+	if len(newest) == 0 {
+		return nil, fmt.Errorf("No routerInfos from any of %d URLs", len(db.URLs))
+	}
+
+	for _, ri := range newest {
+		routerInfos = append(routerInfos, ri)
+	}
+
+	return
+}
+
+// Su3Files downloads the su3 files at URLs and returns the ones that
+// verify, unchanged, to be served as they are
+func (db *RemoteNetDbImpl) Su3Files() ([][]byte, error) {
+	var su3s [][]byte
+	for _, url := range db.URLs {
+		_, data, err := db.fetchSu3(url)
+		if nil != err {
+			log.Printf("Unable to fetch the su3 file from %s: %s\n", url, err)
+			continue
+		}
+		su3s = append(su3s, data)
+	}
+	if len(su3s) == 0 {
+		return nil, fmt.Errorf("No su3 file from any of %d URLs", len(db.URLs))
+	}
+
+	return su3s, nil
+}
+
+func (db *RemoteNetDbImpl) fetch(url string) ([]routerInfo, error) {
+	su3File, _, err := db.fetchSu3(url)
+	if nil != err {
+		return nil, err
+	}
+
+	seeds, err := uzipSeeds(su3File.Content)
+	if nil != err {
+		return nil, err
+	}
+
+	var ris []routerInfo
+	for _, seed := range seeds {
+		if seed.Name == MANIFEST_NAME {
+			continue
+		}
+
+		// name by the router hash, a mirror may name the files differently
+		info, err := router.ParseRouterInfo(seed.Data)
+		if nil != err {
+			continue
+		}
+		seed.Info = info
+		seed.Name = "routerInfo-" + info.HashBase64() + ".dat"
+		seed.ModTime = seed.published()
+
+		// ignore outdated routerInfos, like LocalNetDbImpl
+		if time.Since(seed.ModTime).Hours() > 192 {
+			continue
+		}
+		ris = append(ris, seed)
+	}
+
+	return ris, nil
+}
+
+// fetchSu3 downloads the su3 file at url and verifies it is a reseed signed
+// by one of the certificates in KeyStore
+func (db *RemoteNetDbImpl) fetchSu3(url string) (*su3.Su3File, []byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if nil != err {
+		return nil, nil, err
+	}
+	req.Header.Set("User-Agent", I2P_USER_AGENT)
+
+	resp, err := db.Client.Do(req)
+	if nil != err {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	// one byte more tells a file at the limit from a larger one
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, REMOTE_SU3_MAX_BYTES+1))
+	if nil != err {
+		return nil, nil, err
+	}
+	if len(data) > REMOTE_SU3_MAX_BYTES {
+		return nil, nil, fmt.Errorf("su3 file larger than %d bytes", REMOTE_SU3_MAX_BYTES)
+	}
+	su3File, err := su3.Parse(data)
+	if nil != err {
+		return nil, nil, err
+	}
+	if su3File.ContentType != su3.CONTENT_TYPE_RESEED || su3File.FileType != su3.FILE_TYPE_ZIP {
+		return nil, nil, fmt.Errorf("not a reseed su3 file")
+	}
+
+	certs, err := db.KeyStore.SignerCertificates(su3File.SignerId)
+	if nil != err {
+		return nil, nil, err
+	}
+	verified := false
+	for _, cert := range certs {
+		if nil == su3File.VerifySignature(cert.Certificate) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, nil, fmt.Errorf("invalid signature of signer '%s'", su3File.SignerId)
+	}
+
+	return su3File, data, nil
+}
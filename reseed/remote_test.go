@@ -0,0 +1,27 @@
+package reseed
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/martin61/i2p-tools/su3"
+)
+
+func TestFetchSu3TooLarge(t *testing.T) {
+	for _, size := range []int{REMOTE_SU3_MAX_BYTES, REMOTE_SU3_MAX_BYTES + 1} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(su3.MAGIC_BYTES)
+			w.Write(bytes.Repeat([]byte{0}, size-len(su3.MAGIC_BYTES)))
+		}))
+
+		_, _, err := NewRemoteNetDb([]string{ts.URL}, t.TempDir()).fetchSu3(ts.URL)
+		tooLarge := nil != err && strings.Contains(err.Error(), "larger than")
+		if tooLarge != (size > REMOTE_SU3_MAX_BYTES) {
+			t.Errorf("%d bytes: %v", size, err)
+		}
+		ts.Close()
+	}
+}
@@ -0,0 +1,270 @@
+package reseed
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevocationStore keeps revoked serials in a file, one per line:
+// <decimal serial> <unix revocation time> <reason code>
+type RevocationStore struct {
+	Path string
+
+	mu sync.Mutex
+}
+
+// Revoked returns the entries of the store, an empty list if the file
+// doesn't exist yet
+func (rs *RevocationStore) Revoked() ([]x509.RevocationListEntry, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	return rs.revoked()
+}
+
+func (rs *RevocationStore) revoked() ([]x509.RevocationListEntry, error) {
+	f, err := os.Open(rs.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if nil != err {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []x509.RevocationListEntry
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s:%d: expected serial, time and reason", rs.Path, line)
+		}
+		serial, ok := new(big.Int).SetString(fields[0], 10)
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: invalid serial '%s'", rs.Path, line, fields[0])
+		}
+		revoked, err := strconv.ParseInt(fields[1], 10, 64)
+		if nil != err {
+			return nil, fmt.Errorf("%s:%d: %s", rs.Path, line, err)
+		}
+		reason, err := strconv.Atoi(fields[2])
+		if nil != err {
+			return nil, fmt.Errorf("%s:%d: %s", rs.Path, line, err)
+		}
+		entries = append(entries, x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Unix(revoked, 0).UTC(), ReasonCode: reason})
+	}
+
+	return entries, scanner.Err()
+}
+
+// Revoke adds serial to the store. It returns false if it was already
+// revoked.
+func (rs *RevocationStore) Revoke(serial *big.Int, reason int) (bool, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	entries, err := rs.revoked()
+	if nil != err {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.SerialNumber.Cmp(serial) == 0 {
+			return false, nil
+		}
+	}
+
+	f, err := os.OpenFile(rs.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if nil != err {
+		return false, err
+	}
+	if _, err := fmt.Fprintf(f, "%s %d %d\n", serial, time.Now().Unix(), reason); nil != err {
+		f.Close()
+		return false, err
+	}
+	if err := f.Sync(); nil != err {
+		f.Close()
+		return false, err
+	}
+
+	return true, f.Close()
+}
+
+// CRLIssuer signs CRLs of the serials in a RevocationStore
+type CRLIssuer struct {
+	Store    *RevocationStore
+	Cert     *x509.Certificate
+	Key      crypto.Signer
+	Validity time.Duration
+	// the PEM encoded CRL is written to <Base>.crl and its number kept in
+	// <Base>.crlnumber, like the CRLs of keygen
+	Base string
+}
+
+// Issue signs a new CRL, writes it to Base.crl and returns it DER encoded
+func (ci *CRLIssuer) Issue() ([]byte, error) {
+	entries, err := ci.Store.Revoked()
+	if nil != err {
+		return nil, err
+	}
+	number, err := NextCRLNumber(ci.Base + ".crlnumber")
+	if nil != err {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.RevocationList{
+		Number:                    number,
+		ThisUpdate:                now,
+		NextUpdate:                now.Add(ci.Validity),
+		RevokedCertificateEntries: entries,
+	}
+	der, err := x509.CreateRevocationList(rand.Reader, template, ci.Cert, ci.Key)
+	if nil != err {
+		return nil, fmt.Errorf("error creating CRL: %w", err)
+	}
+
+	crlFile := ci.Base + ".crl"
+	if err := WriteFileAtomic(crlFile, pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}), 0600); nil != err {
+		return nil, fmt.Errorf("failed to write %s: %w", crlFile, err)
+	}
+
+	return der, nil
+}
+
+// NextCRLNumber increments the decimal number stored in path, starting at 1
+func NextCRLNumber(path string) (*big.Int, error) {
+	number := big.NewInt(0)
+	if data, err := ioutil.ReadFile(path); nil == err {
+		if _, ok := number.SetString(strings.TrimSpace(string(data)), 10); !ok {
+			return nil, fmt.Errorf("Invalid CRL number in '%s'", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	number.Add(number, big.NewInt(1))
+	if err := WriteFileAtomic(path, []byte(number.String()+"\n"), 0600); nil != err {
+		return nil, err
+	}
+
+	return number, nil
+}
+
+// HandleCRL serves the CRLs of issuer at path, relative to the server
+// prefix, and lets operators revoke serials at /admin/revoke. The CRL is
+// issued now and again every half of its validity, so it never expires
+// while the server runs.
+func (s *Server) HandleCRL(path string, issuer *CRLIssuer) error {
+	der, err := issuer.Issue()
+	if nil != err {
+		return err
+	}
+	s.certMu.Lock()
+	s.crl, s.crlIssuer = der, issuer
+	s.certMu.Unlock()
+
+	s.mux.Handle(s.prefix+path, s.certChain.Then(http.HandlerFunc(s.crlHandler)))
+
+	go func() {
+		for range time.Tick(issuer.Validity / 2) {
+			if err := s.reissueCRL(); nil != err {
+				log.Println("Unable to renew the CRL:", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// reissueCRL issues a new CRL, one at a time so a CRL is never replaced by
+// an older one
+func (s *Server) reissueCRL() error {
+	s.crlMu.Lock()
+	defer s.crlMu.Unlock()
+
+	der, err := s.crlIssuer.Issue()
+	if nil != err {
+		return err
+	}
+	s.certMu.Lock()
+	s.crl = der
+	s.certMu.Unlock()
+
+	return nil
+}
+
+func (s *Server) crlHandler(w http.ResponseWriter, r *http.Request) {
+	s.certMu.RLock()
+	der := s.crl
+	s.certMu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Write(der)
+}
+
+// revokeHandler adds the serial of a POST to the revocation store and
+// publishes a new CRL right away. It is only served with AdminAuth
+// configured.
+func (s *Server) revokeHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.AdminAuth.enabled() {
+		http.Error(w, "403 Revocations require admin auth to be configured", http.StatusForbidden)
+		return
+	}
+	if nil == s.crlIssuer {
+		http.Error(w, "501 No CRL is published", http.StatusNotImplemented)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	serial, ok := new(big.Int).SetString(r.FormValue("serial"), 0)
+	if !ok || serial.Sign() <= 0 {
+		http.Error(w, "400 serial must be a positive decimal or 0x hex number", http.StatusBadRequest)
+		return
+	}
+	reason := 0
+	if r.FormValue("reason") != "" {
+		var err error
+		if reason, err = strconv.Atoi(r.FormValue("reason")); nil != err || reason < 0 || reason > 10 || reason == 7 {
+			http.Error(w, "400 reason must be a CRL reason code", http.StatusBadRequest)
+			return
+		}
+	}
+
+	added, err := s.crlIssuer.Store.Revoke(serial, reason)
+	if nil != err {
+		logRequest(r, "Unable to revoke serial %s: %s", serial, err)
+		http.Error(w, "500 Unable to revoke: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !added {
+		fmt.Fprintf(w, "serial %s is already revoked\n", serial)
+		return
+	}
+	logRequest(r, "Revoked serial %s, reason %d", serial, reason)
+	if err := s.reissueCRL(); nil != err {
+		logRequest(r, "Unable to publish the CRL: %s", err)
+		http.Error(w, "500 Revoked, but the CRL was not published: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Fprintf(w, "serial %s revoked\n", serial)
+}
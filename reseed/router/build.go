@@ -0,0 +1,77 @@
+package router
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// NewEd25519RouterInfo builds a routerInfo signed with key. It is meant for
+// synthetic netDbs in tests, real routerInfos are published by I2P routers.
+func NewEd25519RouterInfo(key ed25519.PrivateKey, published time.Time, addresses []RouterAddress, options map[string]string) []byte {
+	var buf bytes.Buffer
+
+	// router identity: a placeholder X25519 key padded to 256 bytes, the
+	// signing key aligned at the end of the keys and a key certificate
+	keys := make([]byte, KEYS_LENGTH)
+	cryptoKey := sha256.Sum256(key.Seed())
+	copy(keys, cryptoKey[:])
+	copy(keys[KEYS_LENGTH-ed25519.PublicKeySize:], key.Public().(ed25519.PublicKey))
+	buf.Write(keys)
+	buf.WriteByte(CERT_KEY)
+	binary.Write(&buf, binary.BigEndian, uint16(4))
+	binary.Write(&buf, binary.BigEndian, SIGTYPE_EDDSA_SHA512)
+	binary.Write(&buf, binary.BigEndian, CRYPTO_X25519)
+
+	writeDate(&buf, published)
+	buf.WriteByte(uint8(len(addresses)))
+	for _, addr := range addresses {
+		buf.WriteByte(addr.Cost)
+		writeDate(&buf, addr.Expiration)
+		writeString(&buf, addr.Transport)
+		writeMapping(&buf, addr.Options)
+	}
+	// no peers
+	buf.WriteByte(0)
+	writeMapping(&buf, options)
+
+	buf.Write(ed25519.Sign(key, buf.Bytes()))
+
+	return buf.Bytes()
+}
+
+func writeDate(buf *bytes.Buffer, t time.Time) {
+	var ms uint64
+	if !t.IsZero() {
+		ms = uint64(t.UnixNano() / int64(time.Millisecond))
+	}
+	binary.Write(buf, binary.BigEndian, ms)
+}
+
+func writeString(buf *bytes.Buffer, s string) {
+	buf.WriteByte(uint8(len(s)))
+	buf.WriteString(s)
+}
+
+// mappings are sorted by key so the signed bytes are canonical
+func writeMapping(buf *bytes.Buffer, m map[string]string) {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var pairs bytes.Buffer
+	for _, k := range keys {
+		writeString(&pairs, k)
+		pairs.WriteByte('=')
+		writeString(&pairs, m[k])
+		pairs.WriteByte(';')
+	}
+
+	binary.Write(buf, binary.BigEndian, uint16(pairs.Len()))
+	buf.Write(pairs.Bytes())
+}
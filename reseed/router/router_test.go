@@ -0,0 +1,92 @@
+package router
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// what the README in testdata says about each fixture
+var fixtureReachable = map[string]bool{
+	"routerInfo-7tNEI719kpLzN7SYLBrqQTrStXBe5phzXeBUhsXdIMQ=.dat": true,
+	"routerInfo-gAnY0LbpzZ8lfVzjInjzxQnwLmJX2VpnwYL0hz62jaw=.dat": false,
+	"routerInfo-lx~-VTafMP5aUs2RVIcJzrECKLUDmajXQmZMtvng9E4=.dat": true,
+}
+
+// readFixtures returns the routerInfos in testdata by file name
+func readFixtures(t *testing.T) map[string][]byte {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join("testdata", "routerInfo-*.dat"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatal("no routerInfo fixtures in testdata")
+	}
+
+	fixtures := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := ioutil.ReadFile(path)
+		if nil != err {
+			t.Fatal(err)
+		}
+		fixtures[filepath.Base(path)] = data
+	}
+
+	return fixtures
+}
+
+func TestFixtures(t *testing.T) {
+	fixtures := readFixtures(t)
+	if len(fixtures) != len(fixtureReachable) {
+		t.Errorf("%d fixtures in testdata, %d expected", len(fixtures), len(fixtureReachable))
+	}
+
+	for name, data := range fixtures {
+		ri, err := ParseRouterInfo(data)
+		if nil != err {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if err := ri.Verify(); nil != err {
+			t.Errorf("%s: %s", name, err)
+		}
+		if hash := strings.TrimSuffix(strings.TrimPrefix(name, "routerInfo-"), ".dat"); ri.HashBase64() != hash {
+			t.Errorf("%s: router hash %s", name, ri.HashBase64())
+		}
+
+		if ri.CryptoType != CRYPTO_X25519 || len(ri.EncryptionKey) != 32 {
+			t.Errorf("%s: crypto type %d with a %d byte key", name, ri.CryptoType, len(ri.EncryptionKey))
+		}
+		transports := make(map[string]bool)
+		for _, addr := range ri.Addresses {
+			transports[addr.Transport] = true
+		}
+		if !transports[TRANSPORT_NTCP2] || !transports[TRANSPORT_SSU2] {
+			t.Errorf("%s: transports %v", name, transports)
+		}
+
+		reachable, ok := fixtureReachable[name]
+		if !ok {
+			t.Errorf("%s: not described in testdata/README", name)
+		} else if ri.Reachable() != reachable {
+			t.Errorf("%s: reachable is %t", name, ri.Reachable())
+		}
+
+		// a byte of the published date, the options and the signature
+		for _, off := range []int{len(ri.Identity), len(ri.Signed) - 1, len(data) - 1} {
+			corrupted := append([]byte{}, data...)
+			corrupted[off] ^= 0x01
+
+			ri, err := ParseRouterInfo(corrupted)
+			if nil != err {
+				continue
+			}
+			if err := ri.Verify(); nil == err {
+				t.Errorf("%s: verified with byte %d changed", name, off)
+			}
+		}
+	}
+}
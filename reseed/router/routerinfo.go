@@ -0,0 +1,299 @@
+package router
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	CERT_NULL = uint8(0)
+	CERT_KEY  = uint8(5)
+
+	SIGTYPE_DSA_SHA1          = uint16(0)
+	SIGTYPE_ECDSA_SHA256_P256 = uint16(1)
+	SIGTYPE_ECDSA_SHA384_P384 = uint16(2)
+	SIGTYPE_ECDSA_SHA512_P521 = uint16(3)
+	SIGTYPE_RSA_SHA256_2048   = uint16(4)
+	SIGTYPE_RSA_SHA384_3072   = uint16(5)
+	SIGTYPE_RSA_SHA512_4096   = uint16(6)
+	SIGTYPE_EDDSA_SHA512      = uint16(7)
+	// Ed25519 keys with randomized signatures, verified like SIGTYPE_EDDSA_SHA512
+	SIGTYPE_REDDSA_SHA512 = uint16(11)
+
+	CRYPTO_ELGAMAL = uint16(0)
+	// routers since 0.9.48 (i2pd 2.33), ECIES-X25519
+	CRYPTO_X25519 = uint16(4)
+
+	TRANSPORT_NTCP  = "NTCP"
+	TRANSPORT_SSU   = "SSU"
+	TRANSPORT_NTCP2 = "NTCP2"
+	TRANSPORT_SSU2  = "SSU2"
+
+	// the public key and signing key fields of a router identity
+	KEYS_LENGTH = 384
+)
+
+// Base64 is the I2P base64 alphabet used for router hashes and file names
+var Base64 = base64.NewEncoding("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-~")
+
+type sigLengths struct {
+	pub, sig int
+}
+
+var sigTypes = map[uint16]sigLengths{
+	SIGTYPE_DSA_SHA1:          {128, 40},
+	SIGTYPE_ECDSA_SHA256_P256: {64, 64},
+	SIGTYPE_ECDSA_SHA384_P384: {96, 96},
+	SIGTYPE_ECDSA_SHA512_P521: {132, 132},
+	SIGTYPE_RSA_SHA256_2048:   {256, 256},
+	SIGTYPE_RSA_SHA384_3072:   {384, 384},
+	SIGTYPE_RSA_SHA512_4096:   {512, 512},
+	SIGTYPE_EDDSA_SHA512:      {32, 64},
+	SIGTYPE_REDDSA_SHA512:     {32, 64},
+}
+
+// encryption public key lengths, at the start of the router identity
+var cryptoTypes = map[uint16]int{
+	CRYPTO_ELGAMAL: 256,
+	CRYPTO_X25519:  32,
+}
+
+// RouterInfo holds the fields of a routerInfo needed to filter routers. It
+// is not a full validation of the structure.
+type RouterInfo struct {
+	// the raw router identity, its sha256 is the router hash
+	Identity   []byte
+	SigType    uint16
+	CryptoType uint16
+	SigningKey []byte
+	// nil for unknown crypto types, the router can still be verified
+	EncryptionKey []byte
+
+	Published time.Time
+	Addresses []RouterAddress
+	Options   map[string]string
+
+	// everything covered by the signature
+	Signed    []byte
+	Signature []byte
+
+	verifyOnce sync.Once
+	verifyErr  error
+}
+
+type RouterAddress struct {
+	Cost       uint8
+	Expiration time.Time
+	Transport  string
+	Options    map[string]string
+}
+
+func (ri *RouterInfo) Hash() [sha256.Size]byte {
+	return sha256.Sum256(ri.Identity)
+}
+
+// HashBase64 returns the router hash as used in routerInfo file names
+func (ri *RouterInfo) HashBase64() string {
+	h := ri.Hash()
+	return Base64.EncodeToString(h[:])
+}
+
+// Reachable reports whether the router published at least one address peers
+// can connect to
+func (ri *RouterInfo) Reachable() bool {
+	for _, addr := range ri.Addresses {
+		if addr.Reachable() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reachable reports whether the address has a host. NTCP2 and SSU2 addresses
+// also need the router's static key, firewalled routers publish them without
+// a host or with SSU2 introducers only.
+func (addr RouterAddress) Reachable() bool {
+	if addr.Options["host"] == "" {
+		return false
+	}
+
+	switch addr.Transport {
+	case TRANSPORT_NTCP2, TRANSPORT_SSU2:
+		return nil != addr.StaticKey()
+	}
+
+	return true
+}
+
+// StaticKey returns the X25519 static key ("s") of an NTCP2 or SSU2 address,
+// nil if there is none or it is invalid
+func (addr RouterAddress) StaticKey() []byte {
+	key, err := Base64.DecodeString(addr.Options["s"])
+	if nil != err || len(key) != 32 {
+		return nil
+	}
+
+	return key
+}
+
+func ParseRouterInfo(data []byte) (*RouterInfo, error) {
+	r := &reader{data: data}
+	ri := &RouterInfo{}
+
+	// router identity: public key, signing key, certificate
+	keys := r.next(KEYS_LENGTH)
+	certType := r.byte()
+	cert := r.next(int(r.uint16()))
+	if nil != r.err {
+		return nil, r.err
+	}
+
+	switch certType {
+	case CERT_NULL:
+		ri.SigType = SIGTYPE_DSA_SHA1
+		ri.CryptoType = CRYPTO_ELGAMAL
+	case CERT_KEY:
+		if len(cert) < 4 {
+			return nil, errors.New("routerInfo: key certificate too short")
+		}
+		ri.SigType = binary.BigEndian.Uint16(cert[0:2])
+		ri.CryptoType = binary.BigEndian.Uint16(cert[2:4])
+	default:
+		return nil, fmt.Errorf("routerInfo: unsupported certificate type %d", certType)
+	}
+
+	lengths, ok := sigTypes[ri.SigType]
+	if !ok {
+		return nil, fmt.Errorf("routerInfo: unsupported signature type %d", ri.SigType)
+	}
+
+	// the signing key is aligned at the end of the keys, larger keys
+	// continue in the key certificate
+	if lengths.pub <= KEYS_LENGTH-256 {
+		ri.SigningKey = keys[KEYS_LENGTH-lengths.pub:]
+	} else {
+		excess := lengths.pub - (KEYS_LENGTH - 256)
+		if len(cert) < 4+excess {
+			return nil, errors.New("routerInfo: key certificate too short")
+		}
+		ri.SigningKey = append(append([]byte{}, keys[256:]...), cert[4:4+excess]...)
+	}
+	if n, ok := cryptoTypes[ri.CryptoType]; ok {
+		ri.EncryptionKey = keys[:n]
+	}
+	ri.Identity = data[:r.off]
+
+	ri.Published = r.date()
+	numAddresses := int(r.byte())
+	for i := 0; i < numAddresses && nil == r.err; i++ {
+		var addr RouterAddress
+		addr.Cost = r.byte()
+		addr.Expiration = r.date()
+		addr.Transport = r.string()
+		addr.Options = r.mapping()
+		ri.Addresses = append(ri.Addresses, addr)
+	}
+
+	// peers, always empty
+	r.next(int(r.byte()) * sha256.Size)
+
+	ri.Options = r.mapping()
+	ri.Signed = data[:r.off]
+	ri.Signature = r.next(lengths.sig)
+
+	if nil != r.err {
+		return nil, r.err
+	}
+
+	return ri, nil
+}
+
+type reader struct {
+	data []byte
+	off  int
+	err  error
+}
+
+var errShort = errors.New("routerInfo: unexpected end of data")
+
+func (r *reader) next(n int) []byte {
+	if nil != r.err {
+		return nil
+	}
+	if n < 0 || r.off+n > len(r.data) {
+		r.err = errShort
+		return nil
+	}
+
+	b := r.data[r.off : r.off+n]
+	r.off += n
+
+	return b
+}
+
+func (r *reader) byte() uint8 {
+	if b := r.next(1); nil != b {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) uint16() uint16 {
+	if b := r.next(2); nil != b {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+// an I2P date is the number of milliseconds since the epoch, 0 means undefined
+func (r *reader) date() time.Time {
+	b := r.next(8)
+	if nil == b {
+		return time.Time{}
+	}
+
+	ms := int64(binary.BigEndian.Uint64(b))
+	if 0 == ms {
+		return time.Time{}
+	}
+
+	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
+}
+
+func (r *reader) string() string {
+	return string(r.next(int(r.byte())))
+}
+
+// a mapping is a size prefixed list of key=value; pairs
+func (r *reader) mapping() map[string]string {
+	data := r.next(int(r.uint16()))
+	if nil == data {
+		return nil
+	}
+
+	m := make(map[string]string)
+	pairs := &reader{data: data}
+	for pairs.off < len(data) {
+		key := pairs.string()
+		if !bytes.Equal(pairs.next(1), []byte("=")) {
+			break
+		}
+		value := pairs.string()
+		if !bytes.Equal(pairs.next(1), []byte(";")) {
+			break
+		}
+		m[key] = value
+	}
+	if nil != pairs.err || pairs.off != len(data) {
+		r.err = errors.New("routerInfo: malformed mapping")
+	}
+
+	return m
+}
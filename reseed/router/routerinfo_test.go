@@ -0,0 +1,150 @@
+package router
+
+import (
+	"bytes"
+	"crypto/dsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// routerInfoFixture is a routerInfo encoded field by field as the I2P spec
+// describes it, independently of NewEd25519RouterInfo
+type routerInfoFixture struct {
+	name       string
+	data       []byte
+	identity   int
+	sigType    uint16
+	cryptoType uint16
+	published  time.Time
+	transports []string
+}
+
+// fixtureBytes writes the router identity, published date, addresses, no
+// peers and the options. Addresses are pairs of transport and host.
+func fixtureBytes(keys []byte, cert []byte, published time.Time, addresses [][2]string, options string) []byte {
+	var buf bytes.Buffer
+	buf.Write(keys)
+	buf.Write(cert)
+	binary.Write(&buf, binary.BigEndian, uint64(published.UnixNano()/int64(time.Millisecond)))
+	buf.WriteByte(byte(len(addresses)))
+	for _, addr := range addresses {
+		// cost, no expiration
+		buf.WriteByte(10)
+		buf.Write(make([]byte, 8))
+		buf.WriteByte(byte(len(addr[0])))
+		buf.WriteString(addr[0])
+		mapping := "\x04host=" + string(rune(len(addr[1]))) + addr[1] + ";\x04port=\x0512345;"
+		binary.Write(&buf, binary.BigEndian, uint16(len(mapping)))
+		buf.WriteString(mapping)
+	}
+	buf.WriteByte(0)
+	binary.Write(&buf, binary.BigEndian, uint16(len(options)))
+	buf.WriteString(options)
+
+	return buf.Bytes()
+}
+
+func routerInfoFixtures(t *testing.T) []routerInfoFixture {
+	t.Helper()
+
+	// routers before 0.9.16: ElGamal and DSA-SHA1 keys with a null certificate
+	dsaKey := new(dsa.PrivateKey)
+	dsaKey.Parameters = dsaParameters
+	if err := dsa.GenerateKey(dsaKey, rand.Reader); nil != err {
+		t.Fatal(err)
+	}
+	keys := make([]byte, KEYS_LENGTH)
+	rand.Read(keys[:256])
+	dsaKey.Y.FillBytes(keys[256:])
+	dsaPublished := time.Date(2015, 3, 1, 12, 0, 0, 0, time.UTC)
+	dsaData := fixtureBytes(keys, []byte{CERT_NULL, 0, 0}, dsaPublished,
+		[][2]string{{TRANSPORT_NTCP, "192.0.2.1"}, {TRANSPORT_SSU, "192.0.2.1"}},
+		"\x04caps=\x02fR;\x05netId=\x012;\x0erouter.version=\x060.9.18;")
+	digest := sha1.Sum(dsaData)
+	r, s, err := dsa.Sign(rand.Reader, dsaKey, digest[:])
+	if nil != err {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 40)
+	r.FillBytes(sig[:20])
+	s.FillBytes(sig[20:])
+	dsaData = append(dsaData, sig...)
+
+	// routers from 0.9.16 to 0.9.47: ElGamal and Ed25519 keys with a key
+	// certificate, the signing key aligned at the end of the keys
+	edSeed := sha256.Sum256([]byte("routerinfo_test"))
+	edKey := ed25519.NewKeyFromSeed(edSeed[:])
+	keys = make([]byte, KEYS_LENGTH)
+	rand.Read(keys[:256])
+	copy(keys[KEYS_LENGTH-ed25519.PublicKeySize:], edKey.Public().(ed25519.PublicKey))
+	edPublished := time.Date(2019, 7, 1, 12, 0, 0, 0, time.UTC)
+	edData := fixtureBytes(keys, []byte{CERT_KEY, 0, 4, 0, byte(SIGTYPE_EDDSA_SHA512), 0, byte(CRYPTO_ELGAMAL)}, edPublished,
+		[][2]string{{TRANSPORT_NTCP, "2001:db8::1"}},
+		"\x04caps=\x02LR;\x05netId=\x012;\x0erouter.version=\x060.9.41;")
+	edData = append(edData, ed25519.Sign(edKey, edData)...)
+
+	return []routerInfoFixture{
+		{"DSA", dsaData, KEYS_LENGTH + 3, SIGTYPE_DSA_SHA1, CRYPTO_ELGAMAL, dsaPublished, []string{TRANSPORT_NTCP, TRANSPORT_SSU}},
+		{"Ed25519", edData, KEYS_LENGTH + 7, SIGTYPE_EDDSA_SHA512, CRYPTO_ELGAMAL, edPublished, []string{TRANSPORT_NTCP}},
+	}
+}
+
+func TestParseRouterInfo(t *testing.T) {
+	for _, fixture := range routerInfoFixtures(t) {
+		name, data := fixture.name, fixture.data
+
+		ri, err := ParseRouterInfo(data)
+		if nil != err {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+
+		if !bytes.Equal(ri.Identity, data[:fixture.identity]) {
+			t.Errorf("%s: router identity of %d bytes, expected %d", name, len(ri.Identity), fixture.identity)
+		}
+		if ri.HashBase64() != Base64.EncodeToString(sha256Of(data[:fixture.identity])) {
+			t.Errorf("%s: router hash %s", name, ri.HashBase64())
+		}
+		if ri.SigType != fixture.sigType || ri.CryptoType != fixture.cryptoType {
+			t.Errorf("%s: signature type %d and crypto type %d", name, ri.SigType, ri.CryptoType)
+		}
+		if len(ri.EncryptionKey) != 256 || !bytes.Equal(ri.EncryptionKey, data[:256]) {
+			t.Errorf("%s: encryption key of %d bytes", name, len(ri.EncryptionKey))
+		}
+		if !ri.Published.Equal(fixture.published) {
+			t.Errorf("%s: published %s", name, ri.Published)
+		}
+		if len(ri.Addresses) != len(fixture.transports) {
+			t.Errorf("%s: %d addresses", name, len(ri.Addresses))
+		} else {
+			for i, addr := range ri.Addresses {
+				if addr.Transport != fixture.transports[i] || addr.Options["port"] != "12345" || !addr.Reachable() {
+					t.Errorf("%s: address %d is %v", name, i, addr)
+				}
+			}
+		}
+		if ri.Options["netId"] != "2" || len(ri.Options) != 3 {
+			t.Errorf("%s: options %v", name, ri.Options)
+		}
+		if err := ri.Verify(); nil != err {
+			t.Errorf("%s: %s", name, err)
+		}
+
+		for n := 0; n < len(data); n++ {
+			if _, err := ParseRouterInfo(data[:n]); nil == err {
+				t.Errorf("%s: parsed the first %d of %d bytes", name, n, len(data))
+				break
+			}
+		}
+	}
+}
+
+func sha256Of(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:]
+}
@@ -0,0 +1,104 @@
+package router
+
+import (
+	"crypto"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var errBadSignature = errors.New("routerInfo: signature verification failed")
+
+// the DSA group used by I2P for SIGTYPE_DSA_SHA1
+var dsaParameters = dsa.Parameters{
+	P: fromHex("9C05B2AA960D9B97B8931963C9CC9E8C3026E9B8ED92FAD0A69CC886D5BF8015FCADAE31A0AD18FAB3F01B00A358DE237655C4964AFAA2B337E96AD316B9FB1CC564B5AEC5B69A9FF6C3E4548707FEF8503D91DD8602E867E6D35D2235C1869CE2479C3B9D5401DE04E0727FB33D6511285D4CF29538D9E3B6051F5B22CC1C93"),
+	Q: fromHex("A5DFC28FEF4CA1E286744CD8EED9D29D684046B7"),
+	G: fromHex("0C1F4D27D40093B429E962D7223824E0BBC47E7C832A39236FC683AF84889581075FF9082ED32353D4374D7301CDA1D23C431F4698599DDA02451824FF369752593647CC3DDC197DE985E43D136CDCFC6BD5409CD2F450821142A5E6F8EB1C3AB5D0484B8129FCF17BCE4F7F33321C3CB3DBB14A905E7B2B3E93BE4708CBCC82"),
+}
+
+func fromHex(s string) *big.Int {
+	n, _ := new(big.Int).SetString(s, 16)
+	return n
+}
+
+// Verify checks the routerInfo's signature against the signing key in its
+// own router identity. The result is kept, netDb caches hand out the same
+// RouterInfo on every rebuild until the file changes.
+func (ri *RouterInfo) Verify() error {
+	ri.verifyOnce.Do(func() {
+		ri.verifyErr = ri.verify()
+	})
+
+	return ri.verifyErr
+}
+
+func (ri *RouterInfo) verify() error {
+	switch ri.SigType {
+	case SIGTYPE_DSA_SHA1:
+		digest := sha1.Sum(ri.Signed)
+		pub := &dsa.PublicKey{Parameters: dsaParameters, Y: new(big.Int).SetBytes(ri.SigningKey)}
+		r, s := splitSignature(ri.Signature)
+		if !dsa.Verify(pub, digest[:], r, s) {
+			return errBadSignature
+		}
+	case SIGTYPE_ECDSA_SHA256_P256:
+		return ri.verifyECDSA(elliptic.P256(), crypto.SHA256)
+	case SIGTYPE_ECDSA_SHA384_P384:
+		return ri.verifyECDSA(elliptic.P384(), crypto.SHA384)
+	case SIGTYPE_ECDSA_SHA512_P521:
+		return ri.verifyECDSA(elliptic.P521(), crypto.SHA512)
+	case SIGTYPE_RSA_SHA256_2048:
+		return ri.verifyRSA(crypto.SHA256)
+	case SIGTYPE_RSA_SHA384_3072:
+		return ri.verifyRSA(crypto.SHA384)
+	case SIGTYPE_RSA_SHA512_4096:
+		return ri.verifyRSA(crypto.SHA512)
+	case SIGTYPE_EDDSA_SHA512, SIGTYPE_REDDSA_SHA512:
+		if !ed25519.Verify(ed25519.PublicKey(ri.SigningKey), ri.Signed, ri.Signature) {
+			return errBadSignature
+		}
+	default:
+		return fmt.Errorf("routerInfo: unsupported signature type %d", ri.SigType)
+	}
+
+	return nil
+}
+
+// ECDSA keys are the raw X and Y coordinates, signatures the raw R and S values
+func (ri *RouterInfo) verifyECDSA(curve elliptic.Curve, hash crypto.Hash) error {
+	x, y := splitSignature(ri.SigningKey)
+	pub := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+
+	h := hash.New()
+	h.Write(ri.Signed)
+	r, s := splitSignature(ri.Signature)
+	if !ecdsa.Verify(pub, h.Sum(nil), r, s) {
+		return errBadSignature
+	}
+
+	return nil
+}
+
+// RSA keys are the raw modulus, the exponent is always 65537
+func (ri *RouterInfo) verifyRSA(hash crypto.Hash) error {
+	pub := &rsa.PublicKey{N: new(big.Int).SetBytes(ri.SigningKey), E: 65537}
+
+	h := hash.New()
+	h.Write(ri.Signed)
+	if err := rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), ri.Signature); nil != err {
+		return errBadSignature
+	}
+
+	return nil
+}
+
+func splitSignature(b []byte) (*big.Int, *big.Int) {
+	half := len(b) / 2
+	return new(big.Int).SetBytes(b[:half]), new(big.Int).SetBytes(b[half:])
+}
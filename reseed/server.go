@@ -3,12 +3,22 @@ package reseed
 import (
 	"bytes"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/throttled/throttled"
 	"github.com/throttled/throttled/store"
@@ -18,12 +28,111 @@ import (
 
 const (
 	I2P_USER_AGENT = "Wget/1.11.4"
+
+	// the path stock I2P routers request the reseed file from
+	DEFAULT_SU3_PATH = "/i2pseeds.su3"
+
+	// minimum time between rebuilds requested at /admin/rebuild
+	MIN_MANUAL_REBUILD_INTERVAL = time.Minute
+
+	// when routers should come back while the server is in maintenance
+	MAINTENANCE_RETRY_AFTER = 30 * time.Minute
 )
 
+// DefaultHeaders are added to every response unless changed in Server.Headers
+var DefaultHeaders = map[string]string{
+	"X-Content-Type-Options": "nosniff",
+	"X-Frame-Options":        "DENY",
+	"Referrer-Policy":        "no-referrer",
+}
+
+// Rebuilder is implemented by reseeders that can rebuild on request
+type Rebuilder interface {
+	Rebuild() error
+}
+
+// Scheduler is implemented by reseeders that rebuild on a schedule
+type Scheduler interface {
+	NextRebuild() time.Time
+}
+
 type Server struct {
 	*http.Server
 	Reseeder  Reseeder
 	Blacklist *Blacklist
+	Banlist   *Banlist
+	AdminAuth AdminAuth
+	// added to every response, ex. "Server"
+	Headers map[string]string
+	// the URL routers reach us at, used for absolute links
+	PublicURL string
+	// serve an index page at the prefix
+	Index bool
+	// permissions of unix sockets created by ListenAndServeProxied
+	SocketMode os.FileMode
+	// /healthz fails once the served su3 files are older, 0 is no limit
+	MaxBundleAge time.Duration
+	// also refuse to serve su3 files older than MaxBundleAge
+	RefuseStaleBundle bool
+	// serve the admin listener over TLS with this certificate and key,
+	// independent of the public one
+	AdminCertFile, AdminKeyFile string
+	// DER encoded intermediates sent after the chain of the TLS certificate
+	// file, when it lacks them
+	Intermediates [][]byte
+	// where the access log is written, stdout by default
+	AccessLog io.Writer
+	// tell clients how long the su3 files they get are current, in
+	// X-Reseed-Next-Rebuild and Cache-Control
+	NextRebuildHeader bool
+	// answer unknown paths with a redirect to NotFoundRedirect, or
+	// NotFoundBody with NotFoundStatus, an empty 404 by default
+	NotFoundRedirect    string
+	NotFoundStatus      int
+	NotFoundBody        []byte
+	NotFoundContentType string
+	// close the connection of requests for these paths without a response,
+	// see DEFAULT_SCANNER_PATHS
+	ScannerPaths []string
+
+	// 1 while in maintenance, su3 files are not served
+	maintenance int32
+	// fraction of successful requests written to the access log, the
+	// bits of a float64 accessed atomically, see SetAccessLogSample
+	accessLogSample uint64
+	// bytes per second of each su3 download, accessed atomically, see
+	// SetBandwidthPerConn
+	bandwidthPerConn int64
+	// the *pageCache of the current change of the reseeder
+	pageCache atomic.Value
+
+	// certFile, keyFile and cert are guarded by certMu
+	certFile, keyFile string
+	certMu            sync.RWMutex
+	cert              *tls.Certificate
+	// host names the certificate of the main listener is for, see AllowSNI
+	allowedSni map[string]bool
+	signerCert []byte
+	// the current CRL, DER encoded, and what issues it
+	crl       []byte
+	crlIssuer *CRLIssuer
+	crlMu     sync.Mutex
+
+	mux         *http.ServeMux
+	prefix      string
+	reseedChain alice.Chain
+	// reseedChain without the rate limit
+	peerChain alice.Chain
+	certChain alice.Chain
+	// served su3 paths by profile, relative to the prefix
+	su3Paths map[string]string
+	proxied  *http.Server
+	// started by ListenAndServeListener
+	listeners   []*http.Server
+	listenersMu sync.Mutex
+
+	rebuildMu     sync.Mutex
+	lastRebuildAt time.Time
 }
 
 func (srv *Server) ListenAndServe() error {
@@ -39,6 +148,43 @@ func (srv *Server) ListenAndServe() error {
 	return srv.Serve(newBlacklistListener(ln, srv.Blacklist))
 }
 
+// ListenAndServeProxied serves plain HTTP on addr for a TLS terminating proxy
+// in front of it, addr may be unix:/path/to/sock. X-Forwarded-For is always
+// trusted on this listener, so both the rate limit and the blacklist apply to
+// the real client address.
+func (srv *Server) ListenAndServeProxied(addr string) error {
+	socketMode := srv.SocketMode
+	if socketMode == 0 {
+		socketMode = DEFAULT_SOCKET_MODE
+	}
+	ln, err := listen(addr, socketMode)
+	if err != nil {
+		return err
+	}
+
+	srv.proxied = &http.Server{
+		Handler:  proxiedMiddleware(srv.blacklistMiddleware(srv.Handler)),
+		ErrorLog: srv.ErrorLog,
+	}
+	return srv.proxied.Serve(ln)
+}
+
+// Close closes all listeners, which removes their unix sockets
+func (srv *Server) Close() error {
+	if nil != srv.proxied {
+		srv.proxied.Close()
+	}
+	srv.listenersMu.Lock()
+	for _, h := range srv.listeners {
+		h.Close()
+	}
+	srv.listenersMu.Unlock()
+
+	return srv.Server.Close()
+}
+
+// ListenAndServeTLS serves TLS using the certificate and key files. If both
+// are the same file, it is read as a combined PEM bundle.
 func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	addr := srv.Addr
 	if addr == "" {
@@ -46,18 +192,22 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	}
 	config := &tls.Config{}
 	if srv.TLSConfig != nil {
-		*config = *srv.TLSConfig
+		config = srv.TLSConfig.Clone()
 	}
 	if config.NextProtos == nil {
 		config.NextProtos = []string{"http/1.1"}
 	}
 
-	var err error
-	config.Certificates = make([]tls.Certificate, 1)
-	config.Certificates[0], err = tls.LoadX509KeyPair(certFile, keyFile)
-	if err != nil {
+	srv.certMu.Lock()
+	srv.certFile, srv.keyFile = certFile, keyFile
+	srv.certMu.Unlock()
+	if err := srv.ReloadCertificate(); err != nil {
 		return err
 	}
+	config.GetCertificate = srv.getCertificate
+	if nil != srv.allowedSni {
+		config.GetConfigForClient = srv.checkSNI(config.GetConfigForClient)
+	}
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -68,7 +218,152 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	return srv.Serve(tlsListener)
 }
 
-func NewServer(prefix string, trustProxy bool) *Server {
+// ListenAndServeAdmin serves the operator endpoints on their own listener,
+// with the net/http/pprof profiling handlers if withPprof is set. An addr
+// without a host is bound to DEFAULT_ADMIN_HOST, set AdminCertFile and
+// AdminAuth before binding it to a public address.
+func (srv *Server) ListenAndServeAdmin(addr string, withPprof bool) error {
+	return srv.ListenAndServeListener(Listener{
+		Handlers: HANDLERS_ADMIN,
+		Addr:     addr,
+		CertFile: srv.AdminCertFile,
+		KeyFile:  srv.AdminKeyFile,
+		Pprof:    withPprof,
+	})
+}
+
+// ReloadCertificate reads the TLS certificate and key again, new handshakes
+// will use the new certificate
+func (srv *Server) ReloadCertificate() error {
+	srv.certMu.RLock()
+	certFile, keyFile := srv.certFile, srv.keyFile
+	srv.certMu.RUnlock()
+	if certFile == "" {
+		// not serving TLS (yet)
+		return nil
+	}
+
+	cert, err := LoadKeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	for _, der := range srv.Intermediates {
+		if !hasCertificate(cert.Certificate, der) {
+			cert.Certificate = append(cert.Certificate, der)
+		}
+	}
+
+	srv.certMu.Lock()
+	defer srv.certMu.Unlock()
+	srv.cert = &cert
+
+	return nil
+}
+
+// LoadKeyPair reads a certificate and key, or a combined PEM bundle if both
+// are the same file
+func LoadKeyPair(certFile, keyFile string) (tls.Certificate, error) {
+	if certFile == keyFile {
+		return LoadTLSBundle(certFile)
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// hasCertificate reports if chain includes der, an intermediate may have
+// been added to a renewed certificate file
+func hasCertificate(chain [][]byte, der []byte) bool {
+	for _, c := range chain {
+		if bytes.Equal(c, der) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (srv *Server) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	srv.certMu.RLock()
+	defer srv.certMu.RUnlock()
+
+	return srv.cert, nil
+}
+
+// SetSignerCertificate sets the su3 signing certificate (DER) served to clients
+func (srv *Server) SetSignerCertificate(der []byte) {
+	srv.certMu.Lock()
+	defer srv.certMu.Unlock()
+
+	srv.signerCert = der
+}
+
+// RequireClientCerts only completes TLS handshakes with clients presenting a
+// certificate issued by one of cas, for private reseeds
+func (srv *Server) RequireClientCerts(cas *x509.CertPool) {
+	srv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	srv.TLSConfig.ClientCAs = cas
+}
+
+// AllowSNI only completes TLS handshakes of clients asking for one of names
+// on the main listener, clients without SNI, like scanners probing by IP,
+// are rejected too. Listeners with their own certificate are not affected.
+func (srv *Server) AllowSNI(names []string) {
+	srv.allowedSni = make(map[string]bool)
+	for _, name := range names {
+		srv.allowedSni[NormalizeSNI(name)] = true
+	}
+}
+
+// checkSNI wraps the GetConfigForClient of the main listener to reject the
+// names AllowSNI doesn't allow, after next so EnableTLSDebug still logs them
+func (srv *Server) checkSNI(next func(*tls.ClientHelloInfo) (*tls.Config, error)) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		var config *tls.Config
+		if nil != next {
+			var err error
+			if config, err = next(hello); nil != err {
+				return nil, err
+			}
+		}
+		if !srv.allowedSni[NormalizeSNI(hello.ServerName)] {
+			return nil, fmt.Errorf("unexpected SNI %q", hello.ServerName)
+		}
+
+		return config, nil
+	}
+}
+
+// NormalizeSNI lower cases a host name and drops its trailing dot
+func NormalizeSNI(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// EnableTLSDebug logs the client hello and the negotiated parameters of every
+// TLS handshake. Failed handshakes are logged by net/http with the remote address.
+func (srv *Server) EnableTLSDebug() {
+	next := srv.TLSConfig.GetConfigForClient
+	srv.TLSConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		var versions []string
+		for _, v := range hello.SupportedVersions {
+			versions = append(versions, tls.VersionName(v))
+		}
+		log.Printf("TLS hello from %s: SNI=%q ALPN=%q versions=%q\n", hello.Conn.RemoteAddr(), hello.ServerName, hello.SupportedProtos, versions)
+		if nil != next {
+			return next(hello)
+		}
+		return nil, nil
+	}
+	srv.TLSConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		log.Printf("TLS handshake: version=%s cipher=%s SNI=%q ALPN=%q\n", tls.VersionName(cs.Version), tls.CipherSuiteName(cs.CipherSuite), cs.ServerName, cs.NegotiatedProtocol)
+		return nil
+	}
+}
+
+func NewServer(prefix, su3Path string, trustProxy bool) *Server {
+	if su3Path == "" {
+		su3Path = DEFAULT_SU3_PATH
+	}
+
 	config := &tls.Config{
 //		MinVersion:               tls.VersionTLS10,
 //		PreferServerCipherSuites: true,
@@ -91,50 +386,285 @@ func NewServer(prefix string, trustProxy bool) *Server {
 		CurvePreferences: []tls.CurveID{tls.CurveP384, tls.CurveP521},		// default CurveP256 removed
 	}
 	h := &http.Server{TLSConfig: config}
-	server := Server{Server: h, Reseeder: nil}
+	server := Server{Server: h, Reseeder: nil, Headers: make(map[string]string), AccessLog: os.Stdout, accessLogSample: math.Float64bits(1)}
+	for k, v := range DefaultHeaders {
+		server.Headers[k] = v
+	}
 
 //	th := throttled.RateLimit(throttled.PerDay(4), &throttled.VaryBy{RemoteAddr: true}, store.NewMemStore(200000))
 	th := throttled.RateLimit(throttled.PerHour(4), &throttled.VaryBy{RemoteAddr: true}, store.NewMemStore(200000))
+	th.DeniedHandler = server.violationHandler(throttled.DefaultDeniedHandler)
 
-	middlewareChain := alice.New()
+	middlewareChain := alice.New(requestIdMiddleware, server.headersMiddleware)
 	if trustProxy {
 		middlewareChain = middlewareChain.Append(proxiedMiddleware)
 	}
 
 	errorHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNotFound)
-		if _, err := w.Write(nil); nil != err {
-			log.Println(err)
+		if server.Index && r.URL.Path == prefix+"/" {
+			server.indexHandler(w, r)
+			return
 		}
+
+		server.notFoundHandler(w, r)
 	})
 
 	mux := http.NewServeMux()
-	mux.Handle("/", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware).Then(errorHandler))
-	mux.Handle(prefix+"/i2pseeds.su3", middlewareChain.Append(disableKeepAliveMiddleware, loggingMiddleware, verifyMiddleware, th.Throttle).Then(http.HandlerFunc(server.reseedHandler)))
+	mux.Handle("/", middlewareChain.Append(disableKeepAliveMiddleware, server.scannerMiddleware, server.loggingMiddleware).Then(errorHandler))
+	server.mux, server.prefix = mux, prefix
+	server.su3Paths = map[string]string{DEFAULT_PROFILE: su3Path}
+	server.peerChain = middlewareChain.Append(disableKeepAliveMiddleware, server.loggingMiddleware, server.banlistMiddleware, server.verifyMiddleware, server.bandwidthMiddleware)
+	server.reseedChain = middlewareChain.Append(disableKeepAliveMiddleware, server.loggingMiddleware, server.banlistMiddleware, server.verifyMiddleware, th.Throttle, server.bandwidthMiddleware)
+	mux.Handle(prefix+su3Path, server.reseedChain.Then(server.reseedHandler(DEFAULT_PROFILE)))
+
+	// redirect misconfigured routers asking at the canonical path to the real one
+	redirect := middlewareChain.Append(disableKeepAliveMiddleware, server.loggingMiddleware).Then(http.RedirectHandler(prefix+su3Path, http.StatusFound))
+	aliases := map[string]bool{DEFAULT_SU3_PATH: true, prefix + DEFAULT_SU3_PATH: true}
+	delete(aliases, prefix+su3Path)
+	for alias := range aliases {
+		mux.Handle(alias, redirect)
+	}
+
+	// the certificate to verify our su3 files with
+	certChain := middlewareChain.Append(disableKeepAliveMiddleware, server.loggingMiddleware)
+	mux.Handle(prefix+"/reseed.crt", certChain.Then(http.HandlerFunc(server.signerCertHandler)))
+	mux.Handle(prefix+"/reseed.der", certChain.Then(http.HandlerFunc(server.signerCertHandler)))
+	server.certChain = certChain
+
+	// operator endpoints
+	adminChain := middlewareChain.Append(disableKeepAliveMiddleware, server.loggingMiddleware, server.adminMiddleware)
+	mux.Handle(prefix+"/stats.json", adminChain.Then(http.HandlerFunc(server.statsHandler)))
+	mux.Handle(prefix+"/metrics", adminChain.Then(http.HandlerFunc(server.metricsHandler)))
+	mux.Handle(prefix+"/version", adminChain.Then(http.HandlerFunc(server.versionHandler)))
+	mux.Handle(prefix+"/admin/rebuild", adminChain.Then(http.HandlerFunc(server.rebuildHandler)))
+	mux.Handle(prefix+"/admin/maintenance", adminChain.Then(http.HandlerFunc(server.maintenanceHandler)))
+	mux.Handle(prefix+"/admin/revoke", adminChain.Then(http.HandlerFunc(server.revokeHandler)))
+	mux.Handle(prefix+"/healthz", certChain.Then(http.HandlerFunc(server.healthHandler)))
 	server.Handler = mux
 
 	return &server
 }
 
-func (s *Server) reseedHandler(w http.ResponseWriter, r *http.Request) {
-	var peer Peer
-	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
-		peer = Peer(ip)
+// HandleProfile serves the su3 files of a reseeder profile at path, relative
+// to the server prefix. It shares the rate limit of the default path.
+func (s *Server) HandleProfile(name, path string) {
+	s.su3Paths[name] = path
+	s.mux.Handle(s.prefix+path, s.reseedChain.Then(s.reseedHandler(name)))
+}
+
+func (s *Server) reseedHandler(profile string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.Maintenance() {
+			w.Header().Set("Retry-After", fmt.Sprint(int(MAINTENANCE_RETRY_AFTER.Seconds())))
+			http.Error(w, "503 Down for maintenance, please try again later", http.StatusServiceUnavailable)
+			return
+		}
+		peer := Peer(remoteIp(r))
+
+		su3Bytes, hash, built, err := s.Reseeder.PeerSu3Bytes(profile, peer)
+		if nil != err {
+			logRequest(r, "Unable to serve su3 of profile '%s': %s", profile, err)
+			http.Error(w, "500 Unable to serve su3", http.StatusInternalServerError)
+			return
+		}
+		if s.RefuseStaleBundle && s.stale(built) {
+			logRequest(r, "Not serving the su3 of profile '%s' built %s ago", profile, time.Since(built).Round(time.Second))
+			http.Error(w, "503 su3 files are outdated", http.StatusServiceUnavailable)
+			return
+		}
+
+		// a peer always gets the same file until the next rebuild, so interrupted
+		// downloads can be resumed with a Range request
+		w.Header().Set("ETag", `"`+hash[:32]+`"`)
+		w.Header().Set("Content-Disposition", "attachment; filename=i2pseeds.su3")
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if s.NextRebuildHeader {
+			s.setNextRebuild(w)
+		}
+
+		http.ServeContent(w, r, "i2pseeds.su3", built, bytes.NewReader(su3Bytes))
+	}
+}
+
+// setNextRebuild sets when the su3 files served now are replaced, on the
+// schedule whenever they were built. A peer gets the same file until then,
+// but another peer a different one, so shared caches must not keep it.
+func (s *Server) setNextRebuild(w http.ResponseWriter) {
+	scheduler, ok := s.Reseeder.(Scheduler)
+	if !ok {
+		return
+	}
+	next := scheduler.NextRebuild()
+	if next.IsZero() {
+		return
+	}
+
+	w.Header().Set("X-Reseed-Next-Rebuild", next.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(next).Seconds())))
+}
+
+func (s *Server) signerCertHandler(w http.ResponseWriter, r *http.Request) {
+	s.certMu.RLock()
+	der := s.signerCert
+	s.certMu.RUnlock()
+
+	if nil == der {
+		http.NotFound(w, r)
+		return
+	}
+
+	// the certificate rarely changes
+	w.Header().Set("Cache-Control", "public, max-age=604800")
+	if strings.HasSuffix(r.URL.Path, ".der") {
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(der)
 	} else {
-		peer = Peer(r.RemoteAddr)
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	}
+}
+
+// statsHandler serves the stats rendered after the last change of the
+// reseeder, the banned count is as of then
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	// the ban count changes between rebuilds, the page is cached per count
+	banned := 0
+	if nil != s.Banlist {
+		banned = s.Banlist.Banned()
 	}
 
-	su3Bytes, err := s.Reseeder.PeerSu3Bytes(peer)
-	if nil != err {
-		http.Error(w, "500 Unable to serve su3", http.StatusInternalServerError)
+	var page *renderedPage
+	if pc := s.pages(); nil != pc {
+		page = pc.statsPage(banned)
+	}
+	servePage(w, r, page, "application/json", func(w io.Writer) error {
+		stats := struct {
+			Stats
+			Banned int       `json:"banned"`
+			Build  BuildInfo `json:"build"`
+		}{Stats: s.Reseeder.Stats(), Banned: banned, Build: GetBuildInfo()}
+
+		return json.NewEncoder(w).Encode(stats)
+	})
+}
+
+func (s *Server) versionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(GetBuildInfo()); nil != err {
+		log.Println(err)
+	}
+}
+
+// rebuildHandler rebuilds the su3 files on POST and returns the new stats.
+// It is only served with AdminAuth configured and at most once every
+// MIN_MANUAL_REBUILD_INTERVAL, a rebuild keeps the CPU busy.
+func (s *Server) rebuildHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.AdminAuth.enabled() {
+		http.Error(w, "403 Rebuilds require admin auth to be configured", http.StatusForbidden)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rebuilder, ok := s.Reseeder.(Rebuilder)
+	if !ok {
+		http.Error(w, "501 This reseeder can't rebuild on request", http.StatusNotImplemented)
 		return
 	}
 
-	w.Header().Set("Content-Disposition", "attachment; filename=i2pseeds.su3")
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", strconv.FormatInt(int64(len(su3Bytes)), 10))
+	if !s.rebuildMu.TryLock() {
+		http.Error(w, "429 A rebuild is already running", http.StatusTooManyRequests)
+		return
+	}
+	defer s.rebuildMu.Unlock()
+	if wait := MIN_MANUAL_REBUILD_INTERVAL - time.Since(s.lastRebuildAt); wait > 0 {
+		w.Header().Set("Retry-After", fmt.Sprint(int(wait.Seconds())+1))
+		http.Error(w, "429 Too many rebuilds", http.StatusTooManyRequests)
+		return
+	}
+	s.lastRebuildAt = time.Now()
+
+	logRequest(r, "Rebuild requested")
+	if err := rebuilder.Rebuild(); nil != err {
+		logRequest(r, "Requested rebuild failed: %s", err)
+		http.Error(w, "500 Rebuild failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	io.Copy(w, bytes.NewReader(su3Bytes))
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.Reseeder.Stats()); nil != err {
+		log.Println(err)
+	}
+}
+
+// SetMaintenance stops or resumes serving su3 files, routers get a 503 with
+// a Retry-After in the meantime
+func (s *Server) SetMaintenance(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&s.maintenance, v)
+}
+
+func (s *Server) Maintenance() bool {
+	return atomic.LoadInt32(&s.maintenance) == 1
+}
+
+// maintenanceHandler reports the maintenance state and changes it on a POST
+// with enabled=true or enabled=false. Like rebuilds it requires AdminAuth.
+func (s *Server) maintenanceHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		if !s.AdminAuth.enabled() {
+			http.Error(w, "403 Maintenance requires admin auth to be configured", http.StatusForbidden)
+			return
+		}
+		enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+		if nil != err {
+			http.Error(w, "400 enabled must be true or false", http.StatusBadRequest)
+			return
+		}
+		s.SetMaintenance(enabled)
+		logRequest(r, "Maintenance mode set to %t", enabled)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"maintenance": s.Maintenance()}); nil != err {
+		log.Println(err)
+	}
+}
+
+// healthHandler fails if there are no su3 files yet or they are older than
+// MaxBundleAge, ex. because every rebuild fails. In maintenance it fails with
+// a Retry-After and a body starting with "maintenance", so monitoring can
+// tell a planned outage from a broken server.
+func (s *Server) healthHandler(w http.ResponseWriter, r *http.Request) {
+	built := s.Reseeder.Stats().LastRebuild
+
+	w.Header().Set("Cache-Control", "no-store")
+	switch {
+	case s.Maintenance():
+		w.Header().Set("Retry-After", fmt.Sprint(int(MAINTENANCE_RETRY_AFTER.Seconds())))
+		http.Error(w, "maintenance, su3 files are not served", http.StatusServiceUnavailable)
+	case built.IsZero():
+		http.Error(w, "no su3 files built yet", http.StatusServiceUnavailable)
+	case s.stale(built):
+		http.Error(w, fmt.Sprintf("su3 files built %s ago, more than %s", time.Since(built).Round(time.Second), s.MaxBundleAge), http.StatusServiceUnavailable)
+	default:
+		fmt.Fprintf(w, "ok, su3 files built %s ago\n", time.Since(built).Round(time.Second))
+	}
+}
+
+func (s *Server) stale(built time.Time) bool {
+	return s.MaxBundleAge > 0 && time.Since(built) > s.MaxBundleAge
 }
 
 func disableKeepAliveMiddleware(next http.Handler) http.Handler {
@@ -145,13 +675,45 @@ func disableKeepAliveMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
-func loggingMiddleware(next http.Handler) http.Handler {
-	return handlers.CombinedLoggingHandler(os.Stdout, next)
+func (s *Server) loggingMiddleware(next http.Handler) http.Handler {
+	return handlers.CustomLoggingHandler(os.Stdout, next, s.writeAccessLog)
+}
+
+// SetAccessLogSample sets the fraction of successful requests written to
+// the access log, errors and rate limited requests are always logged
+func (s *Server) SetAccessLogSample(sample float64) {
+	atomic.StoreUint64(&s.accessLogSample, math.Float64bits(sample))
+}
+
+// writeAccessLog writes errors in full and a random sample of the other
+// requests to AccessLog, which may be set after the handlers were built
+func (s *Server) writeAccessLog(_ io.Writer, params handlers.LogFormatterParams) {
+	sample := math.Float64frombits(atomic.LoadUint64(&s.accessLogSample))
+	if params.StatusCode < 400 && sample < 1 && rand.Float64() >= sample {
+		return
+	}
+
+	writeCombinedLog(s.AccessLog, params)
 }
 
-func verifyMiddleware(next http.Handler) http.Handler {
+func (s *Server) verifyMiddleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
 		if I2P_USER_AGENT != r.UserAgent() {
+			logRequest(r, "Rejected user agent %q", r.UserAgent())
+			s.violation(r)
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (s *Server) banlistMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if nil != s.Banlist && s.Banlist.isBanned(remoteIp(r)) {
+			logRequest(r, "Rejected banned %s", remoteIp(r))
 			http.Error(w, "403 Forbidden", http.StatusForbidden)
 			return
 		}
@@ -161,10 +723,78 @@ func verifyMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(fn)
 }
 
+// violationHandler counts the request against the banlist before handing it
+// to the denied handler
+func (s *Server) violationHandler(denied http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		logRequest(r, "Rate limited %s", remoteIp(r))
+		s.violation(r)
+		denied.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (s *Server) violation(r *http.Request) {
+	if nil != s.Banlist {
+		s.Banlist.Violation(remoteIp(r))
+	}
+}
+
+func remoteIp(r *http.Request) string {
+	return addrIp(r.RemoteAddr)
+}
+
+func (s *Server) headersMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		for k, v := range s.Headers {
+			w.Header().Set(k, v)
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (s *Server) adminMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if s.AdminAuth.enabled() && !s.AdminAuth.authorized(r) {
+			s.AdminAuth.challenge(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func (s *Server) blacklistMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if s.Blacklist.isBlocked(remoteIp(r)) {
+			http.Error(w, "403 Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// forwardedFor returns the right-most X-Forwarded-For entry, the address the
+// trusted proxy appended. The entries before it come from the client.
+func forwardedFor(r *http.Request) string {
+	prior := r.Header["X-Forwarded-For"]
+	if len(prior) == 0 {
+		return ""
+	}
+	entries := strings.Split(prior[len(prior)-1], ",")
+
+	return strings.TrimSpace(entries[len(entries)-1])
+}
+
 func proxiedMiddleware(next http.Handler) http.Handler {
 	fn := func(w http.ResponseWriter, r *http.Request) {
-		if prior, ok := r.Header["X-Forwarded-For"]; ok {
-			r.RemoteAddr = prior[0]
+		if client := forwardedFor(r); client != "" {
+			r.RemoteAddr = client
 		}
 
 		next.ServeHTTP(w, r)
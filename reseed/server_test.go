@@ -0,0 +1,76 @@
+package reseed
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckSNI(t *testing.T) {
+	srv := &Server{}
+	srv.AllowSNI([]string{"Reseed.example.", "www.reseed.example"})
+
+	var seen []string
+	check := srv.checkSNI(func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		seen = append(seen, hello.ServerName)
+		return nil, nil
+	})
+
+	tests := []struct {
+		sni     string
+		allowed bool
+	}{
+		{"reseed.example", true},
+		{"RESEED.EXAMPLE.", true},
+		{"www.reseed.example", true},
+		{"other.example", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		_, err := check(&tls.ClientHelloInfo{ServerName: test.sni})
+		if (nil == err) != test.allowed {
+			t.Errorf("SNI %q: %v", test.sni, err)
+		}
+	}
+
+	// the hook before, ex. EnableTLSDebug, sees the rejected hellos too
+	if len(seen) != len(tests) {
+		t.Errorf("%d of %d hellos passed on", len(seen), len(tests))
+	}
+}
+
+func TestReseedHandlerETag(t *testing.T) {
+	rs := NewReseeder(nil)
+	cache := testCache(1, 4)
+	cache.profiles["mobile"] = testCache(2, 2).profiles[DEFAULT_PROFILE]
+	rs.publish(cache)
+	s := &Server{Reseeder: rs}
+
+	for _, profile := range []string{DEFAULT_PROFILE, "mobile"} {
+		handler := s.reseedHandler(profile)
+		for i := 0; i < 8; i++ {
+			r := httptest.NewRequest("GET", "/i2pseeds.su3", nil)
+			r.RemoteAddr = fmt.Sprintf("192.0.2.%d:54321", i)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			sum := sha256.Sum256(w.Body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			if w.Code != http.StatusOK || w.Header().Get("ETag") != etag {
+				t.Errorf("%s %s: status %d with ETag %s, expected %s", profile, r.RemoteAddr, w.Code, w.Header().Get("ETag"), etag)
+				continue
+			}
+
+			r.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			handler(w, r)
+			if w.Code != http.StatusNotModified {
+				t.Errorf("%s %s: status %d for a current ETag", profile, r.RemoteAddr, w.Code)
+			}
+		}
+	}
+}
@@ -1,9 +1,13 @@
 package reseed
 
 import (
+	"bytes"
+	"compress/flate"
+	"crypto"
 	"crypto/rsa"
 	"crypto/sha256"
-	"errors"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
 	"hash/crc32"
 	"io/ioutil"
@@ -12,16 +16,39 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/martin61/i2p-tools/reseed/router"
 	"github.com/martin61/i2p-tools/su3"
 )
 
+const (
+	// routerInfos a LocalNetDbImpl keeps parsed between rebuilds, far more
+	// than a router's netDb holds
+	DEFAULT_NETDB_CACHE_SIZE = 100000
+	// goroutines building su3 files in a rebuild
+	DEFAULT_REBUILD_WORKERS = 3
+)
+
 type routerInfo struct {
 	Name    string
 	ModTime time.Time
 	Data    []byte
+	// nil if the routerInfo could not be parsed
+	Info *router.RouterInfo
+}
+
+// published returns the date the router published this routerInfo, falling
+// back to the file's modification time
+func (ri routerInfo) published() time.Time {
+	if nil != ri.Info && !ri.Info.Published.IsZero() {
+		return ri.Info.Published
+	}
+
+	return ri.ModTime
 }
 
 type Peer string
@@ -34,58 +61,162 @@ func (p Peer) Hash() int {
 }
 
 type Reseeder interface {
-	// get an su3 file (bytes) for a peer
-	PeerSu3Bytes(peer Peer) ([]byte, error)
+	// get an su3 file (bytes) of a profile for a peer, its SHA-256 in hex and
+	// the time it was built
+	PeerSu3Bytes(profile string, peer Peer) ([]byte, string, time.Time, error)
+	// get statistics about the current su3 cache
+	Stats() Stats
+}
+
+type Stats struct {
+	NumSu3      int                     `json:"numSu3"`
+	NumRi       int                     `json:"numRi"`
+	LastRebuild time.Time               `json:"lastRebuild"`
+	Profiles    map[string]ProfileStats `json:"profiles"`
+	// SHA-256 over the su3 files of the default profile
+	BundleHash string `json:"bundleHash"`
+	// rebuilds rejected because an su3 file didn't verify
+	SelfVerificationFailures int64 `json:"selfVerificationFailures"`
+}
+
+type ProfileStats struct {
+	NumSu3 int `json:"numSu3"`
+	NumRi  int `json:"numRi"`
+}
+
+// su3Cache is the result of a single rebuild. It is never modified after
+// being published, but for the legacy files extracted once.
+type su3Cache struct {
+	profiles map[string]*profileCache
+	numRi    int
+	built    time.Time
+	// SHA-256 over the su3 files of the default profile
+	bundleHash string
+	// the routerInfos of each su3 file of the default profile, only set
+	// on first use
+	legacy []legacyFiles
+}
+
+type profileCache struct {
+	su3s [][]byte
+	// SHA-256 of each su3 file in hex, set by index
+	hashes []string
+	numRi  int
 }
 
 type ReseederImpl struct {
 	netdb NetDbProvider
-	su3s  chan [][]byte
+	// the *su3Cache served, replaced as a whole by each rebuild
+	current atomic.Value
 
 	SigningKey      *rsa.PrivateKey
 	SignerId        []byte
 	NumRi           int
 	RebuildInterval time.Duration
 	NumSu3          int
+	// skip routerInfos whose signature doesn't verify
+	VerifyRouterInfos bool
+	// built in addition to the default profile
+	Profiles []Profile
+	// maximum size of an su3 file, 0 is no limit
+	MaxBundleBytes int
+	// what to do with su3 files over MaxBundleBytes, OVERSIZE_TRIM or OVERSIZE_FAIL
+	OnOversize string
+	// add a manifest of the routerInfos to each su3 zip
+	IncludeManifest bool
+	// goroutines building su3 files, DEFAULT_REBUILD_WORKERS if 0, and
+	// how long each pauses after an su3 file to leave CPU to other
+	// services on the host
+	RebuildWorkers int
+	RebuildPause   time.Duration
+
+	// every new su3 file is verified with this certificate before the
+	// rebuild is used, with the public key of SigningKey if nil
+	SignerCertificate *x509.Certificate
+
+	// publish every rebuild to this directory
+	BundleCache string
+	// publish a .sha256 sidecar with each su3 file
+	BundleCacheChecksums bool
+	// don't build, serve what another instance publishes to BundleCache
+	FollowBundleCache bool
+	BundleCachePoll   time.Duration
+
+	// don't build, serve the su3 files downloaded from these URLs unchanged
+	Verbatim *RemoteNetDbImpl
+
+	compressionLevel int
+	// one rebuild at a time, scheduled or requested
+	rebuildMu sync.Mutex
+	// counted atomically
+	selfVerificationFailures int64
+	// counted atomically, see Changes
+	changes uint64
+	// unix nanos of the first scheduled rebuild, 0 if rebuilds are not
+	// scheduled, accessed atomically
+	scheduleStart int64
+	// the rebuild interval in effect, see SetRebuildInterval, accessed
+	// atomically
+	interval int64
+	// new intervals for the scheduler
+	reschedule chan time.Duration
+
+	// called in the background after each successful rebuild with the new su3 files
+	OnRebuild []func(su3s [][]byte)
+	// called in the background after each failed rebuild
+	OnRebuildError []func(err error)
 }
 
 func NewReseeder(netdb NetDbProvider) *ReseederImpl {
 	return &ReseederImpl{
 		netdb:           netdb,
-		su3s:            make(chan [][]byte),
 		NumRi:           77,
 		RebuildInterval: 90 * time.Hour,
+		BundleCachePoll: 30 * time.Second,
+
+		compressionLevel: flate.DefaultCompression,
+		reschedule:       make(chan time.Duration, 1),
 	}
 }
 
+// SetCompression sets the compression of the zip inside the su3 files,
+// COMPRESSION_DEFAULT, COMPRESSION_BEST or a deflate level from 0 to 9
+func (rs *ReseederImpl) SetCompression(compression string) error {
+	level, err := CompressionLevel(compression)
+	if nil != err {
+		return err
+	}
+	rs.compressionLevel = level
+
+	return nil
+}
+
+// cache returns the su3Cache served, nil before the first rebuild
+func (rs *ReseederImpl) cache() *su3Cache {
+	m, _ := rs.current.Load().(*su3Cache)
+	return m
+}
+
 func (rs *ReseederImpl) Start() chan bool {
-	// atomic swapper
-	go func() {
-		var m [][]byte
-		for {
-			select {
-			case m = <-rs.su3s:
-			case rs.su3s <- m:
-			}
-		}
-	}()
+	quit := make(chan bool)
+	if rs.FollowBundleCache {
+		rs.followBundleCache(quit)
+		return quit
+	}
 
 	// init the cache
-	err := rs.rebuild()
-	if nil != err {
-		log.Println(err)
-	}
+	rs.tryRebuild()
 
 	ticker := time.NewTicker(rs.RebuildInterval)
-	quit := make(chan bool)
+	atomic.StoreInt64(&rs.interval, int64(rs.RebuildInterval))
+	atomic.StoreInt64(&rs.scheduleStart, time.Now().UnixNano())
 	go func() {
 		for {
 			select {
 			case <-ticker.C:
-				err := rs.rebuild()
-				if nil != err {
-					log.Println(err)
-				}
+				rs.tryRebuild()
+			case interval := <-rs.reschedule:
+				ticker.Reset(interval)
 			case <-quit:
 				ticker.Stop()
 				return
@@ -96,70 +227,219 @@ func (rs *ReseederImpl) Start() chan bool {
 	return quit
 }
 
+func (rs *ReseederImpl) tryRebuild() {
+	if err := rs.Rebuild(); nil != err {
+		log.Println(err)
+	}
+}
+
+// Rebuild builds new su3 files now, after a rebuild already running
+func (rs *ReseederImpl) Rebuild() error {
+	if rs.FollowBundleCache {
+		return fmt.Errorf("The su3 files are built by the instance publishing to the bundle cache")
+	}
+
+	rs.rebuildMu.Lock()
+	defer rs.rebuildMu.Unlock()
+
+	err := rs.rebuild()
+	if nil != err {
+		for _, fn := range rs.OnRebuildError {
+			go fn(err)
+		}
+	}
+
+	return err
+}
+
 func (rs *ReseederImpl) rebuild() error {
+	if nil != rs.Verbatim {
+		return rs.mirror()
+	}
+
 	log.Println("Rebuilding su3 cache...")
 
 	// get all RIs from netdb provider
 	ris, err := rs.netdb.RouterInfos()
 	if nil != err {
-		return fmt.Errorf("Unable to get routerInfos: %s", err)
+		return fmt.Errorf("Unable to get routerInfos: %w", err)
+	}
+	if 0 == len(ris) {
+		return ErrEmptyNetDb
+	}
+
+	if rs.VerifyRouterInfos {
+		ris = verifiedRouterInfos(ris)
 	}
 
 	// use only 75% of routerInfos
 	ris = ris[len(ris)/4:]
 
+	cache := &su3Cache{profiles: make(map[string]*profileCache), numRi: len(ris)}
+
+	// the default profile must build, others are skipped on failure
+	profiles := append([]Profile{{Name: DEFAULT_PROFILE, NumRi: rs.NumRi, NumSu3: rs.NumSu3}}, rs.Profiles...)
+	for _, profile := range profiles {
+		pc, err := rs.buildProfile(profile, ris)
+		if nil != err {
+			if profile.Name == DEFAULT_PROFILE {
+				return err
+			}
+			log.Printf("Unable to build profile '%s': %s\n", profile.Name, err)
+			continue
+		}
+
+		cache.profiles[profile.Name] = pc
+	}
+
+	// use this new set of su3s
+	rs.publish(cache)
+	log.Println("Done rebuilding.")
+
+	return nil
+}
+
+// mirror replaces the su3 files with the ones downloaded from Verbatim
+func (rs *ReseederImpl) mirror() error {
+	log.Println("Fetching the upstream su3 files...")
+
+	su3s, err := rs.Verbatim.Su3Files()
+	if nil != err {
+		return err
+	}
+
+	// the routerInfos are not counted, they are never unpacked
+	cache := &su3Cache{profiles: map[string]*profileCache{DEFAULT_PROFILE: {su3s: su3s}}}
+	rs.publish(cache)
+	log.Printf("Serving %d upstream su3 files.\n", len(su3s))
+
+	return nil
+}
+
+// publish serves the new set of su3s and hands them to the bundle cache and
+// OnRebuild
+func (rs *ReseederImpl) publish(cache *su3Cache) {
+	cache.built = time.Now()
+	cache.index()
+	rs.current.Store(cache)
+	atomic.AddUint64(&rs.changes, 1)
+
+	if rs.BundleCache != "" {
+		if err := writeBundleCache(rs.BundleCache, cache, rs.BundleCacheChecksums); nil != err {
+			log.Println("Unable to publish to the bundle cache:", err)
+		}
+	}
+
+	newSu3s := cache.profiles[DEFAULT_PROFILE].su3s
+	for _, fn := range rs.OnRebuild {
+		go fn(newSu3s)
+	}
+}
+
+func (rs *ReseederImpl) buildProfile(profile Profile, ris []routerInfo) (*profileCache, error) {
+	ris = profile.filter(ris)
+
+	numRi := profile.NumRi
+	if 0 == numRi {
+		numRi = rs.NumRi
+	}
+
 	// fail if we don't have enough RIs to make a single reseed file
-	if rs.NumRi > len(ris) {
-		return fmt.Errorf("Not enough routerInfos.")
+	if numRi > len(ris) {
+		return nil, fmt.Errorf("%w: %d of %d", ErrNotEnoughRouterInfos, len(ris), numRi)
+	}
+
+	if profile.Name != DEFAULT_PROFILE {
+		log.Printf("Building profile '%s'.\n", profile.Name)
 	}
 
 	// build a pipeline ris -> seeds -> su3
-	seedsChan := rs.seedsProducer(ris)
+	seedsChan := rs.seedsProducer(ris, numRi, profile.NumSu3)
 	// fan-in multiple builders
-	su3Chan := fanIn(rs.su3Builder(seedsChan), rs.su3Builder(seedsChan), rs.su3Builder(seedsChan))
+	workers := rs.RebuildWorkers
+	if workers <= 0 {
+		workers = DEFAULT_REBUILD_WORKERS
+	}
+	var builders []<-chan builtSu3
+	for i := 0; i < workers; i++ {
+		builders = append(builders, rs.su3Builder(seedsChan))
+	}
+	su3Chan := fanIn(builders...)
 
 	// read from su3 chan and append to su3s slice
 	var newSu3s [][]byte
-	for gs := range su3Chan {
-		data, err := gs.MarshalBinary()
-		if nil != err {
-			return err
+	var err error
+	for built := range su3Chan {
+		if nil != built.err {
+			err = built.err
+			continue
+		}
+		data, marshalErr := built.su3File.MarshalBinary()
+		if nil != marshalErr {
+			err = marshalErr
+			continue
+		}
+		if rs.MaxBundleBytes > 0 && len(data) > rs.MaxBundleBytes {
+			err = fmt.Errorf("%w: %d bytes, the maximum is %d bytes", ErrBundleTooLarge, len(data), rs.MaxBundleBytes)
+			continue
+		}
+		if verifyErr := rs.verifySu3(data); nil != verifyErr {
+			atomic.AddInt64(&rs.selfVerificationFailures, 1)
+			atomic.AddUint64(&rs.changes, 1)
+			err = fmt.Errorf("%w: %s", ErrSelfVerification, verifyErr)
+			continue
 		}
 
 		newSu3s = append(newSu3s, data)
 	}
+	// the builders are done, nothing leaks when failing now
+	if nil != err {
+		return nil, err
+	}
+	// never serve a partial rebuild
+	if numSu3s := su3Count(len(ris), profile.NumSu3); len(newSu3s) < numSu3s {
+		return nil, fmt.Errorf("Built %d of %d su3 files", len(newSu3s), numSu3s)
+	}
 
-	// use this new set of su3s
-	rs.su3s <- newSu3s
+	return &profileCache{su3s: newSu3s, numRi: len(ris)}, nil
+}
 
-	log.Println("Done rebuilding.")
+// verifySu3 checks a new su3 file like a router would, so a key and
+// certificate mismatch or a signing bug never reaches one
+func (rs *ReseederImpl) verifySu3(data []byte) error {
+	var pub crypto.PublicKey
+	if nil != rs.SignerCertificate {
+		pub = rs.SignerCertificate.PublicKey
+	} else if nil != rs.SigningKey {
+		pub = &rs.SigningKey.PublicKey
+	} else {
+		return fmt.Errorf("No signing key")
+	}
 
-	return nil
+	_, err := su3.Verify(bytes.NewReader(data), pub)
+	return err
 }
 
-func (rs *ReseederImpl) seedsProducer(ris []routerInfo) <-chan []routerInfo {
-	lenRis := len(ris)
-
-	// if NumSu3 is not specified, then we determine the "best" number based on the number of RIs
-	var numSu3s int
-	if rs.NumSu3 != 0 {
-		numSu3s = rs.NumSu3
-	} else {
-		switch {
-		case lenRis > 4000:
-			numSu3s = 300
-		case lenRis > 3000:
-			numSu3s = 200
-		case lenRis > 2000:
-			numSu3s = 100
-		case lenRis > 1000:
-			numSu3s = 75
-		default:
-			numSu3s = 50
+func verifiedRouterInfos(ris []routerInfo) []routerInfo {
+	var verified []routerInfo
+	for _, ri := range ris {
+		if nil != ri.Info && nil == ri.Info.Verify() {
+			verified = append(verified, ri)
 		}
 	}
 
-	log.Printf("Building %d su3 files each containing %d out of %d routerInfos.\n", numSu3s, rs.NumRi, lenRis)
+	if skipped := len(ris) - len(verified); skipped > 0 {
+		log.Printf("Skipped %d routerInfos with an invalid signature\n", skipped)
+	}
+
+	return verified
+}
+
+func (rs *ReseederImpl) seedsProducer(ris []routerInfo, numRi, numSu3 int) <-chan []routerInfo {
+	lenRis := len(ris)
+	numSu3s := su3Count(lenRis, numSu3)
+
+	log.Printf("Building %d su3 files each containing %d out of %d routerInfos.\n", numSu3s, numRi, lenRis)
 
 	out := make(chan []routerInfo)
 
@@ -167,7 +447,7 @@ func (rs *ReseederImpl) seedsProducer(ris []routerInfo) <-chan []routerInfo {
 		for i := 0; i < numSu3s; i++ {
 			var seeds []routerInfo
 			unsorted := rand.Perm(lenRis)
-			for z := 0; z < rs.NumRi; z++ {
+			for z := 0; z < numRi; z++ {
 				seeds = append(seeds, ris[unsorted[z]])
 			}
 
@@ -179,32 +459,151 @@ func (rs *ReseederImpl) seedsProducer(ris []routerInfo) <-chan []routerInfo {
 	return out
 }
 
-func (rs *ReseederImpl) su3Builder(in <-chan []routerInfo) <-chan *su3.Su3File {
-	out := make(chan *su3.Su3File)
+// su3Count returns numSu3, or if it is not specified the "best" number of su3
+// files for lenRis routerInfos
+func su3Count(lenRis, numSu3 int) int {
+	if numSu3 != 0 {
+		return numSu3
+	}
+
+	switch {
+	case lenRis > 4000:
+		return 300
+	case lenRis > 3000:
+		return 200
+	case lenRis > 2000:
+		return 100
+	case lenRis > 1000:
+		return 75
+	default:
+		return 50
+	}
+}
+
+// builtSu3 is an su3 file from su3Builder, or why it couldn't be built
+type builtSu3 struct {
+	su3File *su3.Su3File
+	err     error
+}
+
+func (rs *ReseederImpl) su3Builder(in <-chan []routerInfo) <-chan builtSu3 {
+	out := make(chan builtSu3)
 	go func() {
 		for seeds := range in {
 			gs, err := rs.createSu3(seeds)
+			out <- builtSu3{su3File: gs, err: err}
 			if nil != err {
-				log.Println(err)
 				continue
 			}
 
-			out <- gs
+			time.Sleep(rs.RebuildPause)
 		}
 		close(out)
 	}()
 	return out
 }
 
-func (rs *ReseederImpl) PeerSu3Bytes(peer Peer) ([]byte, error) {
-	m := <-rs.su3s
-	defer func() { rs.su3s <- m }()
+func (rs *ReseederImpl) PeerSu3Bytes(profile string, peer Peer) ([]byte, string, time.Time, error) {
+	m := rs.cache()
+
+	if nil == m || nil == m.profiles[profile] || 0 == len(m.profiles[profile].su3s) {
+		return nil, "", time.Time{}, ErrNoSu3
+	}
+
+	pc := m.profiles[profile]
+	i := peer.Hash() % len(pc.su3s)
+	return pc.su3s[i], pc.hashes[i], m.built, nil
+}
+
+// NextRebuild returns when the next scheduled rebuild starts, the zero time
+// if rebuilds are not scheduled here. Rebuilds on request don't move the
+// schedule.
+func (rs *ReseederImpl) NextRebuild() time.Time {
+	start := atomic.LoadInt64(&rs.scheduleStart)
+	interval := time.Duration(atomic.LoadInt64(&rs.interval))
+	if start == 0 || interval <= 0 {
+		return time.Time{}
+	}
+
+	elapsed := time.Since(time.Unix(0, start))
+	return time.Unix(0, start).Add((elapsed/interval + 1) * interval)
+}
+
+// SetRebuildInterval changes the interval of scheduled rebuilds while
+// running, the next one is an interval from now
+func (rs *ReseederImpl) SetRebuildInterval(interval time.Duration) {
+	if 0 == atomic.LoadInt64(&rs.scheduleStart) {
+		// not started or not scheduled
+		return
+	}
+
+	atomic.StoreInt64(&rs.interval, int64(interval))
+	atomic.StoreInt64(&rs.scheduleStart, time.Now().UnixNano())
+	// replace an interval the scheduler didn't pick up yet
+	select {
+	case <-rs.reschedule:
+	default:
+	}
+	rs.reschedule <- interval
+}
+
+// SetProfiles replaces the profiles built with the default one while
+// running, used from the next rebuild
+func (rs *ReseederImpl) SetProfiles(profiles []Profile) {
+	rs.rebuildMu.Lock()
+	defer rs.rebuildMu.Unlock()
+
+	rs.Profiles = profiles
+}
 
-	if 0 == len(m) {
-		return nil, errors.New("404")
+// Changes counts the new su3 files served and the failed self-verifications,
+// what Stats reports changes only when it does
+func (rs *ReseederImpl) Changes() uint64 {
+	return atomic.LoadUint64(&rs.changes)
+}
+
+func (rs *ReseederImpl) Stats() Stats {
+	m := rs.cache()
+
+	failures := atomic.LoadInt64(&rs.selfVerificationFailures)
+	if nil == m {
+		return Stats{SelfVerificationFailures: failures}
 	}
 
-	return m[peer.Hash()%len(m)], nil
+	stats := Stats{NumRi: m.numRi, LastRebuild: m.built, Profiles: make(map[string]ProfileStats), SelfVerificationFailures: failures}
+	for name, pc := range m.profiles {
+		stats.Profiles[name] = ProfileStats{NumSu3: len(pc.su3s), NumRi: pc.numRi}
+	}
+	stats.NumSu3 = stats.Profiles[DEFAULT_PROFILE].NumSu3
+	stats.BundleHash = m.bundleHash
+
+	return stats
+}
+
+// index prepares what is served besides the su3 files, before the cache is
+// published
+func (c *su3Cache) index() {
+	for _, pc := range c.profiles {
+		pc.hashes = make([]string, len(pc.su3s))
+		for i, su3 := range pc.su3s {
+			sum := sha256.Sum256(su3)
+			pc.hashes[i] = hex.EncodeToString(sum[:])
+		}
+	}
+
+	su3s := c.profiles[DEFAULT_PROFILE].su3s
+	c.bundleHash = bundleHash(su3s)
+	c.legacy = make([]legacyFiles, len(su3s))
+}
+
+// bundleHash is the SHA-256 over su3s, computed once per rebuild
+func bundleHash(su3s [][]byte) string {
+	h := sha256.New()
+	for _, su3 := range su3s {
+		h.Write(su3)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
 }
 
 func (rs *ReseederImpl) createSu3(seeds []routerInfo) (*su3.Su3File, error) {
@@ -212,18 +611,32 @@ func (rs *ReseederImpl) createSu3(seeds []routerInfo) (*su3.Su3File, error) {
 	su3File.FileType = su3.FILE_TYPE_ZIP
 	su3File.ContentType = su3.CONTENT_TYPE_RESEED
 
-	zipped, err := zipSeeds(seeds)
+	zipped, err := rs.zipSeeds(seeds)
 	if nil != err {
 		return nil, err
 	}
 	su3File.Content = zipped
 
 	su3File.SignerId = rs.SignerId
-	su3File.Sign(rs.SigningKey)
+	if err := su3File.Sign(rs.SigningKey); nil != err {
+		return nil, err
+	}
+
+	if rs.MaxBundleBytes > 0 && rs.OnOversize == OVERSIZE_TRIM {
+		return rs.trimSu3(su3File, seeds)
+	}
 
 	return su3File, nil
 }
 
+func (rs *ReseederImpl) zipSeeds(seeds []routerInfo) ([]byte, error) {
+	if rs.IncludeManifest {
+		seeds = append(seeds[:len(seeds):len(seeds)], newManifest(seeds, string(rs.SignerId)))
+	}
+
+	return zipSeeds(seeds, rs.compressionLevel)
+}
+
 type NetDbProvider interface {
 	// Get all router infos
 	RouterInfos() ([]routerInfo, error)
@@ -231,20 +644,38 @@ type NetDbProvider interface {
 
 type LocalNetDbImpl struct {
 	Path string
+	// routerInfos kept between scans, the newest ones, 0 is no limit
+	MaxCached int
+	// pause after reading each file, see ReadPacer
+	ReadPause time.Duration
+
+	// routerInfos read by the previous scan, by path. Files are only read
+	// and parsed again if their modification time or size changed, or they
+	// were modified after the checkpoint.
+	cache      map[string]routerInfo
+	checkpoint time.Time
 }
 
 func NewLocalNetDb(path string) *LocalNetDbImpl {
 	return &LocalNetDbImpl{
-		Path: path,
+		Path:      path,
+		MaxCached: DEFAULT_NETDB_CACHE_SIZE,
 	}
 }
 
 func (db *LocalNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
 	r, _ := regexp.Compile("^routerInfo-[A-Za-z0-9-=~]+.dat$")
 
+	// a checkpoint in the future means the clock was set back, rescan everything
+	scanStart := time.Now()
+	if nil == db.cache || db.checkpoint.After(scanStart) {
+		db.cache = make(map[string]routerInfo)
+		db.checkpoint = time.Time{}
+	}
+
 	files := make(map[string]os.FileInfo)
 	walkpath := func(path string, f os.FileInfo, err error) error {
-		if r.MatchString(f.Name()) {
+		if nil == err && r.MatchString(f.Name()) {
 			files[path] = f
 		}
 		return nil
@@ -252,37 +683,146 @@ func (db *LocalNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
 
 	filepath.Walk(db.Path, walkpath)
 
+	cache := make(map[string]routerInfo)
+	unparsed, reread := 0, 0
 	for path, file := range files {
-		riBytes, err := ioutil.ReadFile(path)
-		if nil != err {
-			log.Println(err)
-			continue
-		}
-
 		// ignore outdate routerInfos
 		age := time.Since(file.ModTime())
 		if age.Hours() > 192 {
 			continue
 		}
 
-		// added 6h+6h random time delta to increase Anonymity
-		//rr := rand.New(rand.NewSource(time.Now().UnixNano()))
-		//now := file.ModTime()
-		//then := now.Add(-1 * time.Duration(rr.Intn(60*60*6) + 60*60*6) * time.Second)
+		ri, cached := db.cache[path]
+		if !cached || !ri.ModTime.Equal(file.ModTime()) || !file.ModTime().Before(db.checkpoint) || int64(len(ri.Data)) != file.Size() {
+			riBytes, err := ioutil.ReadFile(path)
+			time.Sleep(db.ReadPause)
+			if nil != err {
+				log.Println(err)
+				continue
+			}
+			reread++
+
+			// added 6h+6h random time delta to increase Anonymity
+			//rr := rand.New(rand.NewSource(time.Now().UnixNano()))
+			//now := file.ModTime()
+			//then := now.Add(-1 * time.Duration(rr.Intn(60*60*6) + 60*60*6) * time.Second)
+
+			info, _ := router.ParseRouterInfo(riBytes)
+			ri = routerInfo{
+				Name:    file.Name(),
+				ModTime: file.ModTime(),
+				//ModTime: then,
+				Data: riBytes,
+				Info: info,
+			}
+		}
+
+		if nil == ri.Info {
+			unparsed++
+		}
+		cache[path] = ri
+		routerInfos = append(routerInfos, ri)
+	}
+	db.evict(cache)
+
+	if !db.checkpoint.IsZero() {
+		log.Printf("Read %d new or changed routerInfos in %s\n", reread, db.Path)
+	}
+	if unparsed > 0 {
+		log.Printf("Unable to parse %d routerInfos in %s\n", unparsed, db.Path)
+	}
+
+	if db.MaxCached > 0 && len(files) > db.MaxCached {
+		log.Printf("%s has %d routerInfos, only %d are cached between rebuilds\n", db.Path, len(files), db.MaxCached)
+	}
+
+	// files that disappeared are dropped from the cache, files modified
+	// during this scan are read again next time
+	db.cache, db.checkpoint = cache, scanStart
+
+	return
+}
+
+// evict drops the oldest routerInfos of cache beyond MaxCached, the newest
+// stay in the netDb longest and the same ones are kept on every scan
+func (db *LocalNetDbImpl) evict(cache map[string]routerInfo) {
+	if db.MaxCached == 0 || len(cache) <= db.MaxCached {
+		return
+	}
+
+	paths := make([]string, 0, len(cache))
+	for path := range cache {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := cache[paths[i]].ModTime, cache[paths[j]].ModTime
+		if !a.Equal(b) {
+			return a.After(b)
+		}
+		return paths[i] < paths[j]
+	})
+	for _, path := range paths[db.MaxCached:] {
+		delete(cache, path)
+	}
+}
+
+// ReadPacer is implemented by netDb providers reading routerInfo files,
+// they pause after each one to spread the load of a rebuild
+type ReadPacer interface {
+	SetReadPause(pause time.Duration)
+}
+
+func (db *LocalNetDbImpl) SetReadPause(pause time.Duration) {
+	db.ReadPause = pause
+}
+
+// MultiNetDbImpl merges the routerInfos of several local netdbs. Routers
+// found in more than one of them are included once, using the newest copy.
+type MultiNetDbImpl struct {
+	netdbs []*LocalNetDbImpl
+}
+
+func NewMultiNetDb(paths []string) *MultiNetDbImpl {
+	db := &MultiNetDbImpl{}
+	for _, path := range paths {
+		db.netdbs = append(db.netdbs, NewLocalNetDb(path))
+	}
+
+	return db
+}
+
+func (db *MultiNetDbImpl) SetReadPause(pause time.Duration) {
+	for _, netdb := range db.netdbs {
+		netdb.ReadPause = pause
+	}
+}
+
+func (db *MultiNetDbImpl) RouterInfos() (routerInfos []routerInfo, err error) {
+	newest := make(map[string]routerInfo)
+	for _, netdb := range db.netdbs {
+		ris, err := netdb.RouterInfos()
+		if nil != err {
+			return nil, err
+		}
+		log.Printf("Found %d routerInfos in %s\n", len(ris), netdb.Path)
+
+		for _, ri := range ris {
+			if current, ok := newest[ri.Name]; !ok || ri.published().After(current.published()) {
+				newest[ri.Name] = ri
+			}
+		}
+	}
 
-		routerInfos = append(routerInfos, routerInfo{
-			Name:    file.Name(),
-			ModTime: file.ModTime(),
-			//ModTime: then,
-			Data:    riBytes,
-		})
+	for _, ri := range newest {
+		routerInfos = append(routerInfos, ri)
 	}
+	log.Printf("Merged %d unique routerInfos from %d netDbs\n", len(routerInfos), len(db.netdbs))
 
 	return
 }
 
-func fanIn(inputs ...<-chan *su3.Su3File) <-chan *su3.Su3File {
-	out := make(chan *su3.Su3File, len(inputs))
+func fanIn(inputs ...<-chan builtSu3) <-chan builtSu3 {
+	out := make(chan builtSu3, len(inputs))
 
 	var wg sync.WaitGroup
 	wg.Add(len(inputs))
@@ -294,7 +834,7 @@ func fanIn(inputs ...<-chan *su3.Su3File) <-chan *su3.Su3File {
 
 	// fan-in all the inputs to a single output
 	for _, input := range inputs {
-		go func(in <-chan *su3.Su3File) {
+		go func(in <-chan builtSu3) {
 			defer wg.Done()
 			for n := range in {
 				out <- n
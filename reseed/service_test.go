@@ -0,0 +1,66 @@
+package reseed
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func testCache(generation, numSu3 int) *su3Cache {
+	pc := &profileCache{}
+	for i := 0; i < numSu3; i++ {
+		pc.su3s = append(pc.su3s, []byte(fmt.Sprintf("generation %d su3 %d", generation, i)))
+	}
+
+	return &su3Cache{profiles: map[string]*profileCache{DEFAULT_PROFILE: pc}}
+}
+
+func TestPublishDuringDownloads(t *testing.T) {
+	// downloads overlap publish only if they run in parallel
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	rs := NewReseeder(nil)
+	rs.publish(testCache(0, 4))
+
+	for generation := 1; generation <= 500; generation++ {
+		quit := make(chan bool)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(peer Peer) {
+				defer wg.Done()
+				for {
+					select {
+					case <-quit:
+						return
+					default:
+					}
+					if _, _, _, err := rs.PeerSu3Bytes(DEFAULT_PROFILE, peer); nil != err {
+						t.Error(err)
+						return
+					}
+					rs.Stats()
+				}
+			}(Peer(fmt.Sprintf("192.0.2.%d", i)))
+		}
+
+		cache := testCache(generation, 4)
+		rs.publish(cache)
+		close(quit)
+		wg.Wait()
+
+		// nothing a download or Stats did may bring back an older cache
+		if stats := rs.Stats(); stats.BundleHash != cache.bundleHash {
+			t.Fatalf("generation %d published, another one served", generation)
+		}
+		su3, _, _, err := rs.PeerSu3Bytes(DEFAULT_PROFILE, "198.51.100.1")
+		if nil != err {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(su3, []byte(fmt.Sprintf("generation %d ", generation))) {
+			t.Fatalf("generation %d published, served %q", generation, su3)
+		}
+	}
+}
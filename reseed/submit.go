@@ -0,0 +1,214 @@
+package reseed
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+	"github.com/throttled/throttled"
+	"github.com/throttled/throttled/store"
+)
+
+const (
+	// routerInfos are 1-2 KiB, larger submissions are refused unread
+	SUBMIT_MAX_BYTES = 4096
+	// submitted routers kept at most, new ones are refused beyond
+	SUBMIT_MAX_ROUTERS = 10000
+	// submitted routers kept per client IP, a host runs a router or a few
+	SUBMIT_MAX_PER_CLIENT = 4
+	// only routers of the I2P network are accepted
+	SUBMIT_NET_ID = "2"
+	// routerInfos published further in the future are refused
+	SUBMIT_MAX_CLOCK_SKEW = 10 * time.Minute
+	// like the routerInfo files LocalNetDbImpl reads
+	SUBMIT_MAX_AGE = 192 * time.Hour
+)
+
+// SubmittedNetDbImpl adds the routerInfos routers POST to the reseed to those
+// of another netDb. They are kept in memory and included in the following
+// rebuilds until they are outdated.
+type SubmittedNetDbImpl struct {
+	Source NetDbProvider
+
+	mu        sync.Mutex
+	submitted map[string]routerInfo
+	// the client IP each submitted router came from
+	clients map[string]string
+}
+
+func NewSubmittedNetDb(source NetDbProvider) *SubmittedNetDbImpl {
+	return &SubmittedNetDbImpl{Source: source, submitted: make(map[string]routerInfo), clients: make(map[string]string)}
+}
+
+// Submit adds a routerInfo sent from clientIp after checking its size,
+// signature, published date and network, and returns its file name. The
+// router must publish an address on clientIp, and each client IP gets at
+// most SUBMIT_MAX_PER_CLIENT routers in.
+func (db *SubmittedNetDbImpl) Submit(data []byte, clientIp string) (string, error) {
+	if len(data) > SUBMIT_MAX_BYTES {
+		return "", fmt.Errorf("routerInfo is larger than %d bytes", SUBMIT_MAX_BYTES)
+	}
+	info, err := router.ParseRouterInfo(data)
+	if nil != err {
+		return "", err
+	}
+	if err := info.Verify(); nil != err {
+		return "", err
+	}
+	if age := time.Since(info.Published); age > SUBMIT_MAX_AGE || age < -SUBMIT_MAX_CLOCK_SKEW {
+		return "", fmt.Errorf("routerInfo was published %s, outside the accepted range", info.Published.UTC().Format(time.RFC3339))
+	}
+	if netId := info.Options["netId"]; netId != SUBMIT_NET_ID {
+		return "", fmt.Errorf("routerInfo is for network '%s', not %s", netId, SUBMIT_NET_ID)
+	}
+	if !publishesIp(info, clientIp) {
+		return "", fmt.Errorf("routerInfo has no address on %s", clientIp)
+	}
+
+	ri := routerInfo{Name: "routerInfo-" + info.HashBase64() + ".dat", ModTime: info.Published, Data: data, Info: info}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.expire()
+	current, ok := db.submitted[ri.Name]
+	if !ok && len(db.submitted) >= SUBMIT_MAX_ROUTERS {
+		return "", fmt.Errorf("%d routerInfos are waiting for a rebuild, try again later", len(db.submitted))
+	}
+	if ok && !ri.published().After(current.published()) {
+		return ri.Name, nil
+	}
+	if db.clients[ri.Name] != clientIp && db.countClient(clientIp) >= SUBMIT_MAX_PER_CLIENT {
+		return "", fmt.Errorf("%s already submitted %d routerInfos", clientIp, SUBMIT_MAX_PER_CLIENT)
+	}
+	db.submitted[ri.Name] = ri
+	db.clients[ri.Name] = clientIp
+
+	return ri.Name, nil
+}
+
+// countClient returns how many submitted routers came from clientIp, db.mu
+// must be held
+func (db *SubmittedNetDbImpl) countClient(clientIp string) int {
+	n := 0
+	for _, ip := range db.clients {
+		if ip == clientIp {
+			n++
+		}
+	}
+
+	return n
+}
+
+// expire drops the outdated submissions, db.mu must be held
+func (db *SubmittedNetDbImpl) expire() {
+	for name, ri := range db.submitted {
+		if time.Since(ri.published()) > SUBMIT_MAX_AGE {
+			delete(db.submitted, name)
+			delete(db.clients, name)
+		}
+	}
+}
+
+// publishesIp reports whether one of the router's addresses is on ip
+func publishesIp(info *router.RouterInfo, ip string) bool {
+	clientIp := net.ParseIP(ip)
+	if nil == clientIp {
+		return false
+	}
+	for _, addr := range info.Addresses {
+		if host := net.ParseIP(addr.Options["host"]); nil != host && host.Equal(clientIp) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RouterInfos returns the routerInfos of Source with the submitted ones,
+// using the newest copy of routers in both
+func (db *SubmittedNetDbImpl) RouterInfos() ([]routerInfo, error) {
+	ris, err := db.Source.RouterInfos()
+	if nil != err {
+		return nil, err
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.expire()
+	if len(db.submitted) == 0 {
+		return ris, nil
+	}
+	newest := make(map[string]routerInfo, len(ris)+len(db.submitted))
+	for _, ri := range ris {
+		newest[ri.Name] = ri
+	}
+	for name, ri := range db.submitted {
+		if current, ok := newest[name]; !ok || ri.published().After(current.published()) {
+			newest[name] = ri
+		}
+	}
+	log.Printf("Including %d submitted routerInfos\n", len(db.submitted))
+
+	var merged []routerInfo
+	for _, ri := range newest {
+		merged = append(merged, ri)
+	}
+
+	return merged, nil
+}
+
+// HandleSubmissions accepts routerInfos POSTed to /router with token as
+// Bearer token into db, at most perHour per client IP, the one a trusted
+// proxy forwarded. The token is not the admin one, routers only get to
+// submit, and the submitted routers end up in the su3 files.
+func (s *Server) HandleSubmissions(db *SubmittedNetDbImpl, token string, perHour int) {
+	// failed attempts count too, the token can't be guessed faster
+	th := throttled.RateLimit(throttled.PerHour(perHour), &throttled.VaryBy{Custom: remoteIp}, store.NewMemStore(10000))
+	th.DeniedHandler = s.violationHandler(throttled.DefaultDeniedHandler)
+
+	auth := &AdminAuth{Token: token}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		s.submitHandler(w, r, auth, db)
+	}
+	s.mux.Handle(s.prefix+"/router", s.certChain.Append(th.Throttle).Then(http.HandlerFunc(handler)))
+}
+
+func (s *Server) submitHandler(w http.ResponseWriter, r *http.Request, auth *AdminAuth, db *SubmittedNetDbImpl) {
+	if !auth.enabled() {
+		http.Error(w, "403 Submissions require a token to be configured", http.StatusForbidden)
+		return
+	}
+	if !auth.authorized(r) {
+		auth.challenge(w)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "405 Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, SUBMIT_MAX_BYTES))
+	if nil != err {
+		http.Error(w, fmt.Sprintf("413 routerInfos are at most %d bytes", SUBMIT_MAX_BYTES), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	name, err := db.Submit(data, remoteIp(r))
+	if nil != err {
+		logRequest(r, "Refused routerInfo submission: %s", err)
+		http.Error(w, "400 "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	logRequest(r, "Accepted %s", name)
+
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "%s accepted, it is used from the next rebuild\n", name)
+}
@@ -0,0 +1,99 @@
+package reseed
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/martin61/i2p-tools/internal/testutil"
+	"github.com/martin61/i2p-tools/reseed/router"
+)
+
+func TestSubmitHandler(t *testing.T) {
+	routers, err := testutil.WriteNetDb(t.TempDir(), 1, testutil.NetDbOptions{Published: func(int) time.Time { return time.Now() }})
+	if nil != err {
+		t.Fatal(err)
+	}
+	s := &Server{AdminAuth: AdminAuth{Token: "admin-token"}}
+	auth := &AdminAuth{Token: "submit-token"}
+	db := NewSubmittedNetDb(nil)
+
+	tests := []struct {
+		name   string
+		token  string
+		body   []byte
+		status int
+	}{
+		{"no token", "", routers[0].Data, http.StatusUnauthorized},
+		{"admin token", "admin-token", routers[0].Data, http.StatusUnauthorized},
+		{"wrong token", "submit-tokeN", routers[0].Data, http.StatusUnauthorized},
+		{"not a routerInfo", "submit-token", []byte("routerInfo"), http.StatusBadRequest},
+		{"routerInfo", "submit-token", routers[0].Data, http.StatusAccepted},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/router", bytes.NewReader(test.body))
+		// the host of the first testutil router
+		r.RemoteAddr = "10.0.0.0:54321"
+		if test.token != "" {
+			r.Header.Set("Authorization", "Bearer "+test.token)
+		}
+		w := httptest.NewRecorder()
+		s.submitHandler(w, r, auth, db)
+		if w.Code != test.status {
+			t.Errorf("%s: status %d, %d expected", test.name, w.Code, test.status)
+		}
+	}
+
+	if _, ok := db.submitted[routers[0].Name]; !ok || len(db.submitted) != 1 {
+		t.Errorf("%d routerInfos submitted", len(db.submitted))
+	}
+}
+
+// submittedRouterInfo returns a routerInfo of router i with an NTCP address
+// on host
+func submittedRouterInfo(i int, host, netId string) []byte {
+	seed := sha256.Sum256([]byte(fmt.Sprintf("submit_test %d", i)))
+	addresses := []router.RouterAddress{{Cost: 10, Transport: router.TRANSPORT_NTCP, Options: map[string]string{"host": host, "port": "12345"}}}
+
+	return router.NewEd25519RouterInfo(ed25519.NewKeyFromSeed(seed[:]), time.Now(), addresses, map[string]string{"caps": "LR", "netId": netId})
+}
+
+type submitTest struct {
+	name     string
+	data     []byte
+	clientIp string
+	ok       bool
+}
+
+func TestSubmit(t *testing.T) {
+	db := NewSubmittedNetDb(nil)
+
+	tests := []submitTest{
+		{"other network", submittedRouterInfo(0, "192.0.2.1", "3"), "192.0.2.1", false},
+		{"other IP", submittedRouterInfo(0, "192.0.2.1", "2"), "192.0.2.2", false},
+		{"IPv6", submittedRouterInfo(0, "2001:db8::1", "2"), "2001:db8:0::1", true},
+	}
+	for i := 1; i <= SUBMIT_MAX_PER_CLIENT; i++ {
+		tests = append(tests, submitTest{fmt.Sprintf("router %d", i), submittedRouterInfo(i, "192.0.2.1", "2"), "192.0.2.1", true})
+	}
+	tests = append(tests,
+		submitTest{"over the client limit", submittedRouterInfo(SUBMIT_MAX_PER_CLIENT+1, "192.0.2.1", "2"), "192.0.2.1", false},
+		submitTest{"update within the limit", submittedRouterInfo(1, "192.0.2.1", "2"), "192.0.2.1", true},
+	)
+
+	for _, test := range tests {
+		if _, err := db.Submit(test.data, test.clientIp); test.ok != (nil == err) {
+			t.Errorf("%s: got error %v", test.name, err)
+		}
+	}
+
+	if len(db.submitted) != 1+SUBMIT_MAX_PER_CLIENT {
+		t.Errorf("%d routerInfos submitted, %d expected", len(db.submitted), 1+SUBMIT_MAX_PER_CLIENT)
+	}
+}
@@ -0,0 +1,55 @@
+package reseed
+
+import (
+	"strings"
+	"time"
+
+	"github.com/martin61/i2p-tools/reseed/router"
+)
+
+// SeedsSummary describes the routerInfos in the zip of an su3 file
+type SeedsSummary struct {
+	RouterInfos int
+	// routerInfos the parser doesn't understand
+	Unparsed  int
+	Reachable int
+	Floodfill int
+	// publishing dates of the parsed routerInfos
+	Oldest, Newest time.Time
+}
+
+// SummarizeSeeds counts the routerInfos in a reseed zip, ignoring the manifest
+func SummarizeSeeds(zipped []byte) (*SeedsSummary, error) {
+	seeds, err := uzipSeeds(zipped)
+	if nil != err {
+		return nil, err
+	}
+
+	summary := &SeedsSummary{}
+	for _, seed := range seeds {
+		if seed.Name == MANIFEST_NAME {
+			continue
+		}
+		summary.RouterInfos++
+
+		info, err := router.ParseRouterInfo(seed.Data)
+		if nil != err {
+			summary.Unparsed++
+			continue
+		}
+		if info.Reachable() {
+			summary.Reachable++
+		}
+		if strings.Contains(info.Options["caps"], "f") {
+			summary.Floodfill++
+		}
+		if summary.Oldest.IsZero() || info.Published.Before(summary.Oldest) {
+			summary.Oldest = info.Published
+		}
+		if info.Published.After(summary.Newest) {
+			summary.Newest = info.Published
+		}
+	}
+
+	return summary, nil
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package reseed
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+// NewSyslogWriter fails, there is no log/syslog on this platform
+func NewSyslogWriter(facility, addr string) (io.Writer, error) {
+	return nil, fmt.Errorf("Logging to syslog is not supported on %s", runtime.GOOS)
+}
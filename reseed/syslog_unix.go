@@ -0,0 +1,54 @@
+//go:build !windows && !plan9
+
+package reseed
+
+import (
+	"fmt"
+	"io"
+	"log/syslog"
+	"strings"
+)
+
+// the program name of syslog messages
+const SYSLOG_TAG = "i2p-tools"
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":   syslog.LOG_KERN,
+	"user":   syslog.LOG_USER,
+	"mail":   syslog.LOG_MAIL,
+	"daemon": syslog.LOG_DAEMON,
+	"auth":   syslog.LOG_AUTH,
+	"syslog": syslog.LOG_SYSLOG,
+	"local0": syslog.LOG_LOCAL0,
+	"local1": syslog.LOG_LOCAL1,
+	"local2": syslog.LOG_LOCAL2,
+	"local3": syslog.LOG_LOCAL3,
+	"local4": syslog.LOG_LOCAL4,
+	"local5": syslog.LOG_LOCAL5,
+	"local6": syslog.LOG_LOCAL6,
+	"local7": syslog.LOG_LOCAL7,
+}
+
+// NewSyslogWriter connects to syslog with the facility name, ex. daemon or
+// local0. addr is empty for the local syslog daemon, or host:port (UDP),
+// udp://host:port or tcp://host:port for a remote one. Every write is sent as
+// one message at the info level.
+func NewSyslogWriter(facility, addr string) (io.Writer, error) {
+	priority, ok := syslogFacilities[facility]
+	if !ok {
+		return nil, fmt.Errorf("Unknown syslog facility '%s'", facility)
+	}
+
+	network := ""
+	if addr != "" {
+		network = "udp"
+		if i := strings.Index(addr, "://"); i >= 0 {
+			network, addr = addr[:i], addr[i+3:]
+		}
+		if network != "udp" && network != "tcp" {
+			return nil, fmt.Errorf("Syslog address must be udp:// or tcp://, not '%s://'", network)
+		}
+	}
+
+	return syslog.Dial(network, addr, priority|syslog.LOG_INFO, SYSLOG_TAG)
+}
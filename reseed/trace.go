@@ -0,0 +1,84 @@
+package reseed
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/handlers"
+)
+
+const (
+	REQUEST_ID_HEADER = "X-Request-ID"
+)
+
+type requestIdKey struct{}
+
+// incoming IDs are logged, so only accept short and harmless ones
+var requestIdRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestIdMiddleware tags each request with the ID from its X-Request-ID
+// header, or a new random one, and echoes it in the response
+func requestIdMiddleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(REQUEST_ID_HEADER)
+		if !requestIdRegexp.MatchString(id) {
+			b := make([]byte, 6)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+
+		w.Header().Set(REQUEST_ID_HEADER, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIdKey{}, id)))
+	}
+	return http.HandlerFunc(fn)
+}
+
+func requestId(r *http.Request) string {
+	id, _ := r.Context().Value(requestIdKey{}).(string)
+	return id
+}
+
+// logRequest logs a line prefixed with the ID of the request
+func logRequest(r *http.Request, format string, v ...interface{}) {
+	log.Printf("[%s] %s", requestId(r), fmt.Sprintf(format, v...))
+}
+
+// writeCombinedLog writes the Apache combined log format followed by the
+// request ID
+func writeCombinedLog(w io.Writer, params handlers.LogFormatterParams) {
+	r := params.Request
+	host := r.RemoteAddr
+	if i := strings.LastIndex(host, ":"); i > 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	uri := r.RequestURI
+	if uri == "" {
+		uri = params.URL.RequestURI()
+	}
+
+	// the subject of a verified client certificate is the user
+	user := "-"
+	if nil != r.TLS && len(r.TLS.VerifiedChains) > 0 {
+		user = strings.Replace(r.TLS.VerifiedChains[0][0].Subject.String(), " ", "%20", -1)
+	}
+
+	fmt.Fprintf(w, "%s - %s [%s] %q %d %d %q %q [%s]\n",
+		host,
+		user,
+		params.TimeStamp.Format("02/Jan/2006:15:04:05 -0700"),
+		r.Method+" "+uri+" "+r.Proto,
+		params.StatusCode,
+		params.Size,
+		r.Referer(),
+		r.UserAgent(),
+		requestId(r),
+	)
+}
@@ -0,0 +1,53 @@
+package reseed
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+const (
+	// listen addresses starting with this are unix socket paths
+	UNIX_PREFIX = "unix:"
+
+	DEFAULT_SOCKET_MODE os.FileMode = 0660
+)
+
+// listen listens on a TCP address or on unix:/path/to/sock
+func listen(addr string, socketMode os.FileMode) (net.Listener, error) {
+	if !strings.HasPrefix(addr, UNIX_PREFIX) {
+		return net.Listen("tcp", addr)
+	}
+
+	return listenUnix(strings.TrimPrefix(addr, UNIX_PREFIX), socketMode)
+}
+
+// listenUnix creates a unix socket with mode, replacing a stale socket left
+// behind by an unclean exit. The socket is removed when the listener closes.
+func listenUnix(path string, mode os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); nil == err {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		// never steal the socket of a running server
+		if conn, err := net.Dial("unix", path); nil == err {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); nil != err {
+			return nil, err
+		}
+	}
+
+	ln, err := net.Listen("unix", path)
+	if nil != err {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); nil != err {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
@@ -4,13 +4,18 @@ import (
 	"crypto/rand"
 //	"crypto/rsa"
 	"crypto/ecdsa"
+	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
 	"math/big"
 	"net"
+	"net/url"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 )
@@ -30,43 +35,201 @@ func (ks *KeyStore) ReseederCertificate(signer []byte) (*x509.Certificate, error
 	return x509.ParseCertificate(certPem.Bytes)
 }
 
+// SignerCertificate is a certificate found in a KeyStore
+type SignerCertificate struct {
+	*x509.Certificate
+	Path string
+}
+
+// SignerCertificates returns the certificates whose subject common name is
+// signer from all .crt files in the reseed directory of the store, like the
+// certificates/reseed directory of a router, or from the store directory
+// itself if it has no reseed directory. Files may hold several certificates,
+// ex. after a key rotation.
+func (ks *KeyStore) SignerCertificates(signer []byte) ([]SignerCertificate, error) {
+	dir := filepath.Join(ks.Path, "reseed")
+	if fi, err := os.Stat(dir); nil != err || !fi.IsDir() {
+		dir = ks.Path
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.crt"))
+	if nil != err {
+		return nil, err
+	}
+
+	var certs []SignerCertificate
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if nil != err {
+			return nil, err
+		}
+
+		for block, rest := pem.Decode(data); nil != block; block, rest = pem.Decode(rest) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if nil != err {
+				continue
+			}
+			if cert.Subject.CommonName == string(signer) {
+				certs = append(certs, SignerCertificate{Certificate: cert, Path: file})
+			}
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%w: no certificate for signer '%s' in %s", ErrNoSignerCertificate, signer, dir)
+	}
+
+	return certs, nil
+}
+
+// ParsePublicURL checks that raw is an absolute http(s) URL the server can
+// be reached at and returns it without a trailing slash
+func ParsePublicURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if nil != err {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("'%s' is not an absolute http(s) URL", raw)
+	}
+	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return "", fmt.Errorf("'%s': the public URL can't have credentials, a query or a fragment", raw)
+	}
+
+	return strings.TrimSuffix(u.String(), "/"), nil
+}
+
+// LoadTLSBundle reads a certificate chain and its private key from a single
+// PEM file, as provided by ACME clients and many secret stores
+func LoadTLSBundle(path string) (tls.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if nil != err {
+		return tls.Certificate{}, err
+	}
+
+	var certPEM, keyPEM []byte
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch {
+		case block.Type == "CERTIFICATE":
+			certPEM = append(certPEM, pem.EncodeToMemory(block)...)
+		case strings.HasSuffix(block.Type, "PRIVATE KEY") && nil == keyPEM:
+			keyPEM = pem.EncodeToMemory(block)
+		}
+	}
+
+	if nil == certPEM {
+		return tls.Certificate{}, fmt.Errorf("No CERTIFICATE found in TLS bundle '%s'", path)
+	}
+	if nil == keyPEM {
+		return tls.Certificate{}, fmt.Errorf("No PRIVATE KEY found in TLS bundle '%s'", path)
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// URLHost formats host and an optional port for a URL. IPv6 literals are
+// bracketed and the % of a zone (fe80::1%eth0) is escaped as RFC 6874 requires.
+func URLHost(host, port string) string {
+	if strings.Contains(host, ":") {
+		host = strings.Replace(host, "%", "%25", 1)
+		if port == "" {
+			return "[" + host + "]"
+		}
+	}
+	if port == "" {
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+// addrIp returns the IP of a host:port address, or of a bare one set by a
+// proxy, without its IPv6 zone, so link-local peers match the blacklist and
+// banlist entries of their address
+func addrIp(addr string) string {
+	ip, _, err := net.SplitHostPort(addr)
+	if nil != err {
+		ip = addr
+	}
+	if i := strings.IndexByte(ip, '%'); i >= 0 {
+		ip = ip[:i]
+	}
+
+	return ip
+}
+
 func SignerFilename(signer string) string {
 	return strings.Replace(signer, "@", "_at_", 1) + ".crt"
 }
 
-//func NewTLSCertificate(host string, priv *rsa.PrivateKey) ([]byte, error) {
-func NewTLSCertificate(host string, priv *ecdsa.PrivateKey) ([]byte, error) {
-	notBefore := time.Now()
-	notAfter := notBefore.Add(5 * 365 * 24 * time.Hour)
+var hostnameRegexp = regexp.MustCompile(`^(\*\.)?([A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)*[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?$`)
 
+// ValidTLSHost reports whether h is an IP address or a hostname, optionally
+// with a leading wildcard label (ex. *.reseed.example)
+func ValidTLSHost(h string) bool {
+	if net.ParseIP(h) != nil {
+		return true
+	}
+
+	return hostnameRegexp.MatchString(h)
+}
+
+//func NewTLSCertificate(host string, priv *rsa.PrivateKey) ([]byte, error) {
+// NewTLSCertificate returns a self-signed certificate for hosts, the
+// CommonName of subject is replaced by the first host
+func NewTLSCertificate(hosts []string, subject pkix.Name, priv *ecdsa.PrivateKey) ([]byte, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
 		return nil, err
 	}
 
-	template := x509.Certificate{
+	template, err := TLSCertificateTemplate(hosts, subject, serialNumber, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	return derBytes, nil
+}
+
+// TLSCertificateTemplate returns the template of a self-signed TLS
+// certificate for hosts valid for 5 years from notBefore
+func TLSCertificateTemplate(hosts []string, subject pkix.Name, serialNumber *big.Int, notBefore time.Time) (*x509.Certificate, error) {
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("At least one TLS host is required")
+	}
+	for _, h := range hosts {
+		if !ValidTLSHost(h) {
+			return nil, fmt.Errorf("'%s' is not a valid hostname or IP address", h)
+		}
+	}
+
+	notAfter := notBefore.Add(5 * 365 * 24 * time.Hour)
+
+	subject.CommonName = hosts[0]
+
+	template := &x509.Certificate{
 		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization:       []string{"I2P Anonymous Network"},
-			OrganizationalUnit: []string{"I2P"},
-			Locality:           []string{"XX"},
-			StreetAddress:      []string{"XX"},
-			Country:            []string{"XX"},
-			CommonName:         host,
-		},
+		Subject:      subject,
 		NotBefore:          notBefore,
 		NotAfter:           notAfter,
 //              SignatureAlgorithm: x509.SHA256WithRSA,
 		SignatureAlgorithm: x509.ECDSAWithSHA512,
 
-		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 		BasicConstraintsValid: true,
 		IsCA: true,
 	}
 
-	hosts := strings.Split(host, ",")
 	for _, h := range hosts {
 		if ip := net.ParseIP(h); ip != nil {
 			template.IPAddresses = append(template.IPAddresses, ip)
@@ -75,10 +238,5 @@ func NewTLSCertificate(host string, priv *ecdsa.PrivateKey) ([]byte, error) {
 		}
 	}
 
-	derBytes, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
-	if err != nil {
-		return nil, err
-	}
-
-	return derBytes, nil
+	return template, nil
 }
@@ -0,0 +1,43 @@
+package reseed
+
+import (
+	"testing"
+)
+
+func TestURLHost(t *testing.T) {
+	tests := []struct {
+		host, port, want string
+	}{
+		{"reseed.example", "", "reseed.example"},
+		{"reseed.example", "8443", "reseed.example:8443"},
+		{"192.0.2.1", "443", "192.0.2.1:443"},
+		{"2001:db8::1", "", "[2001:db8::1]"},
+		{"2001:db8::1", "443", "[2001:db8::1]:443"},
+		{"fe80::1%eth0", "", "[fe80::1%25eth0]"},
+		{"fe80::1%eth0", "443", "[fe80::1%25eth0]:443"},
+	}
+
+	for _, test := range tests {
+		if got := URLHost(test.host, test.port); got != test.want {
+			t.Errorf("URLHost(%q, %q) = %q, %q expected", test.host, test.port, got, test.want)
+		}
+	}
+}
+
+func TestAddrIp(t *testing.T) {
+	tests := []struct {
+		addr, want string
+	}{
+		{"192.0.2.1:34567", "192.0.2.1"},
+		{"[2001:db8::1]:34567", "2001:db8::1"},
+		{"[fe80::1%eth0]:34567", "fe80::1"},
+		{"192.0.2.1", "192.0.2.1"},
+		{"fe80::1%eth0", "fe80::1"},
+	}
+
+	for _, test := range tests {
+		if got := addrIp(test.addr); got != test.want {
+			t.Errorf("addrIp(%q) = %q, %q expected", test.addr, got, test.want)
+		}
+	}
+}
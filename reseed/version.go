@@ -0,0 +1,39 @@
+package reseed
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Build metadata, set at build time with
+// -ldflags "-X github.com/martin61/i2p-tools/reseed.Commit=$(git rev-parse HEAD)"
+var (
+	Version   = "0.1.7"
+	Commit    = "unknown"
+	BuildDate = "unknown"
+)
+
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"buildDate"`
+	GoVersion string `json:"goVersion"`
+}
+
+// GetBuildInfo returns the build metadata. Without -ldflags the commit and
+// date are taken from the VCS information go build embeds, if any.
+func GetBuildInfo() BuildInfo {
+	info := BuildInfo{Version: Version, Commit: Commit, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "unknown":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+
+	return info
+}
@@ -0,0 +1,109 @@
+package reseed
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	EVENT_STARTUP         = "startup"
+	EVENT_SHUTDOWN        = "shutdown"
+	EVENT_REBUILD_SUCCESS = "rebuild_success"
+	EVENT_REBUILD_FAILURE = "rebuild_failure"
+	EVENT_CERT_EXPIRY     = "cert_expiry"
+	EVENT_BUNDLE_STALE    = "bundle_stale"
+
+	// header carrying the hex encoded HMAC-SHA256 of the request body
+	WEBHOOK_SIGNATURE_HEADER = "X-Reseed-Signature"
+)
+
+type WebhookEvent struct {
+	Event   string                 `json:"event"`
+	Time    time.Time              `json:"time"`
+	Signer  string                 `json:"signer,omitempty"`
+	URL     string                 `json:"url,omitempty"`
+	Details map[string]interface{} `json:"details,omitempty"`
+	Build   BuildInfo              `json:"build"`
+}
+
+// Webhook POSTs a small JSON payload describing an event to URL. If a Secret
+// is set, receivers can authenticate the payload with the signature header.
+type Webhook struct {
+	URL    string
+	Secret string
+	Signer string
+	// the public URL of the reseed server sending the events
+	PublicURL string
+
+	client *http.Client
+}
+
+func NewWebhook(url, secret string) *Webhook {
+	return &Webhook{
+		URL:    url,
+		Secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Notify sends the event in the background and logs failures
+func (wh *Webhook) Notify(event string, details map[string]interface{}) {
+	go func() {
+		if err := wh.Send(event, details); nil != err {
+			log.Printf("Webhook '%s' failed: %s\n", event, err)
+		}
+	}()
+}
+
+func (wh *Webhook) Send(event string, details map[string]interface{}) error {
+	body, err := json.Marshal(WebhookEvent{Event: event, Time: time.Now().UTC(), Signer: wh.Signer, URL: wh.PublicURL, Details: details, Build: GetBuildInfo()})
+	if nil != err {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", wh.URL, bytes.NewReader(body))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Secret != "" {
+		req.Header.Set(WEBHOOK_SIGNATURE_HEADER, "sha256="+WebhookSignature(wh.Secret, body))
+	}
+
+	resp, err := wh.client.Do(req)
+	if nil != err {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// WebhookSignature returns the hex encoded HMAC-SHA256 of body
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature reports if header, the X-Reseed-Signature of a
+// webhook, is the signature of body with secret. For receivers written in Go.
+func VerifyWebhookSignature(secret string, body []byte, header string) bool {
+	if !strings.HasPrefix(header, "sha256=") {
+		return false
+	}
+
+	return SecretEqual([]byte(strings.TrimPrefix(header, "sha256=")), []byte(WebhookSignature(secret, body)))
+}
@@ -3,15 +3,48 @@ package reseed
 import (
 	"archive/zip"
 	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"strconv"
 )
 
-func zipSeeds(seeds []routerInfo) ([]byte, error) {
+const (
+	// the deflate level of archive/zip
+	COMPRESSION_DEFAULT = "deflate"
+	// maximum deflate level, smaller su3 files for more CPU on rebuilds
+	COMPRESSION_BEST = "best"
+)
+
+// CompressionLevel returns the deflate level of a compression name or of a
+// level from 0 to 9. Routers only read deflated zips, so other algorithms are
+// not supported.
+func CompressionLevel(compression string) (int, error) {
+	switch compression {
+	case "", COMPRESSION_DEFAULT:
+		return flate.DefaultCompression, nil
+	case COMPRESSION_BEST:
+		return flate.BestCompression, nil
+	}
+
+	level, err := strconv.Atoi(compression)
+	if nil != err || level < flate.NoCompression || level > flate.BestCompression {
+		return 0, fmt.Errorf("Unknown compression '%s', use '%s', '%s' or a level from %d to %d", compression, COMPRESSION_DEFAULT, COMPRESSION_BEST, flate.NoCompression, flate.BestCompression)
+	}
+
+	return level, nil
+}
+
+func zipSeeds(seeds []routerInfo, level int) ([]byte, error) {
 	// Create a buffer to write our archive to.
 	buf := new(bytes.Buffer)
 
 	// Create a new zip archive.
 	zipWriter := zip.NewWriter(buf)
+	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+		return flate.NewWriter(w, level)
+	})
 
 	// Add some files to the archive.
 	for _, file := range seeds {
@@ -54,7 +87,7 @@ func uzipSeeds(c []byte) ([]routerInfo, error) {
 			return nil, err
 		}
 
-		seeds = append(seeds, routerInfo{Name: f.Name, Data: data})
+		seeds = append(seeds, routerInfo{Name: f.Name, ModTime: f.Modified, Data: data})
 	}
 
 	return seeds, nil
@@ -0,0 +1,57 @@
+package reseed
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"testing"
+	"time"
+)
+
+func TestCompressionLevel(t *testing.T) {
+	tests := []struct {
+		compression string
+		level       int
+		ok          bool
+	}{
+		{"", flate.DefaultCompression, true},
+		{COMPRESSION_DEFAULT, flate.DefaultCompression, true},
+		{COMPRESSION_BEST, flate.BestCompression, true},
+		{"0", flate.NoCompression, true},
+		{"9", flate.BestCompression, true},
+		{"10", 0, false},
+		{"-1", 0, false},
+		{"store", 0, false},
+	}
+	for _, test := range tests {
+		level, err := CompressionLevel(test.compression)
+		if test.ok != (nil == err) {
+			t.Errorf("%q: got error %v", test.compression, err)
+			continue
+		}
+		if test.ok && level != test.level {
+			t.Errorf("%q: got level %d, expected %d", test.compression, level, test.level)
+		}
+	}
+}
+
+// routers only read deflated zips, whatever the level
+func TestZipSeedsDeflated(t *testing.T) {
+	seeds := []routerInfo{{Name: "routerInfo-A.dat", ModTime: time.Now(), Data: []byte("routerInfo")}}
+
+	for level := flate.NoCompression; level <= flate.BestCompression; level++ {
+		zipped, err := zipSeeds(seeds, level)
+		if nil != err {
+			t.Fatal(err)
+		}
+		zipReader, err := zip.NewReader(bytes.NewReader(zipped), int64(len(zipped)))
+		if nil != err {
+			t.Fatal(err)
+		}
+		for _, f := range zipReader.File {
+			if f.Method != zip.Deflate {
+				t.Errorf("level %d: %s has method %d", level, f.Name, f.Method)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package su3
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// RSA key sizes in bytes of the signature types, the su3 header reserves
+// room for signatures of exactly this size
+var rsaSignatureLengths = map[uint16]int{
+	SIGTYPE_RSA_SHA256: 256,
+	SIGTYPE_RSA_SHA384: 384,
+	SIGTYPE_RSA_SHA512: 512,
+}
+
+// ECDSA signatures are stored raw, r and s padded to the curve size, like
+// routers expect: 64 bytes for P-256, 96 for P-384 and 132 for P-521
+var ecdsaSignatureLengths = map[uint16]int{
+	SIGTYPE_ECDSA_SHA256: 64,
+	SIGTYPE_ECDSA_SHA384: 96,
+	SIGTYPE_ECDSA_SHA512: 132,
+}
+
+// RSASignatureType returns the signature type of an RSA key by its size
+func RSASignatureType(pub *rsa.PublicKey) (uint16, error) {
+	for sigType, size := range rsaSignatureLengths {
+		if pub.Size() == size {
+			return sigType, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No su3 signature type for a %d bit RSA key, use 2048, 3072 or 4096 bits", pub.Size()*8)
+}
+
+// ReseedOptions are the settings of BuildReseed
+type ReseedOptions struct {
+	// su3 signing ID, ex. something@mail.i2p
+	SignerId string
+	// the su3 version and the date of the zip entries, default now
+	Time time.Time
+}
+
+// BuildReseed zips routers, I2P base64 router hash to routerInfo, and signs
+// them into a reseed su3 file. The zip is sorted by hash with all entries
+// dated opts.Time, so the same routers and time always give the same content.
+func BuildReseed(signer crypto.Signer, sigType uint16, routers map[string][]byte, opts ReseedOptions) ([]byte, error) {
+	if err := CheckSignerId(opts.SignerId); nil != err {
+		return nil, err
+	}
+	pub, ok := signer.Public().(*rsa.PublicKey)
+	if !ok || rsaSignatureLengths[sigType] == 0 {
+		return nil, fmt.Errorf("Reseed su3 files can only be signed with RSA signature types")
+	}
+	if pub.Size() != rsaSignatureLengths[sigType] {
+		return nil, fmt.Errorf("Signature type %d needs a %d bit RSA key", sigType, rsaSignatureLengths[sigType]*8)
+	}
+	if len(routers) == 0 {
+		return nil, fmt.Errorf("No routerInfos to build a reseed su3 from")
+	}
+	if opts.Time.IsZero() {
+		opts.Time = time.Now()
+	}
+
+	zipped, err := zipRouters(routers, opts.Time)
+	if nil != err {
+		return nil, err
+	}
+
+	su3File := NewSu3File()
+	su3File.Version = []byte(strconv.FormatInt(opts.Time.Unix(), 10))
+	su3File.SignatureType = sigType
+	su3File.FileType = FILE_TYPE_ZIP
+	su3File.ContentType = CONTENT_TYPE_RESEED
+	su3File.SignerId = []byte(opts.SignerId)
+	su3File.Content = zipped
+	if err := su3File.SignWith(signer); nil != err {
+		return nil, err
+	}
+
+	return su3File.MarshalBinary()
+}
+
+func zipRouters(routers map[string][]byte, modTime time.Time) ([]byte, error) {
+	var hashes []string
+	for hash := range routers {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	for _, hash := range hashes {
+		fileHeader := &zip.FileHeader{Name: "routerInfo-" + hash + ".dat", Method: zip.Deflate}
+		fileHeader.SetModTime(modTime)
+		zipFile, err := zipWriter.CreateHeader(fileHeader)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := zipFile.Write(routers[hash]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -10,6 +10,7 @@ import (
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
+	"fmt"
 	"math/big"
 	"time"
 )
@@ -20,7 +21,7 @@ type dsaSignature struct {
 
 type ecdsaSignature dsaSignature
 
-func checkSignature(c *x509.Certificate, algo x509.SignatureAlgorithm, signed, signature []byte) (err error) {
+func checkSignature(pub crypto.PublicKey, algo x509.SignatureAlgorithm, signed, signature []byte) (err error) {
 	var hashType crypto.Hash
 
 	switch algo {
@@ -44,7 +45,7 @@ func checkSignature(c *x509.Certificate, algo x509.SignatureAlgorithm, signed, s
 	h.Write(signed)
 	digest := h.Sum(nil)
 
-	switch pub := c.PublicKey.(type) {
+	switch pub := pub.(type) {
 	case *rsa.PublicKey:
 		// the digest is already hashed, so we force a 0 here
 		return rsa.VerifyPKCS1v15(pub, 0, digest, signature)
@@ -61,10 +62,12 @@ func checkSignature(c *x509.Certificate, algo x509.SignatureAlgorithm, signed, s
 		}
 		return
 	case *ecdsa.PublicKey:
-		ecdsaSig := new(ecdsaSignature)
-		if _, err := asn1.Unmarshal(signature, ecdsaSig); err != nil {
-			return err
+		// raw r and s of the curve size, not ASN.1
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return fmt.Errorf("ECDSA signature of %d bytes, %d expected for %s", len(signature), 2*size, pub.Curve.Params().Name)
 		}
+		ecdsaSig := &ecdsaSignature{R: new(big.Int).SetBytes(signature[:size]), S: new(big.Int).SetBytes(signature[size:])}
 		if ecdsaSig.R.Sign() <= 0 || ecdsaSig.S.Sign() <= 0 {
 			return errors.New("x509: ECDSA signature contained zero or negative values")
 		}
@@ -76,32 +79,66 @@ func checkSignature(c *x509.Certificate, algo x509.SignatureAlgorithm, signed, s
 	return x509.ErrUnsupportedAlgorithm
 }
 
-func NewSigningCertificate(signerId string, privateKey *rsa.PrivateKey) ([]byte, error) {
+// ecdsaRawSignature converts an ASN.1 ECDSA signature to r and s, each
+// padded to half of length
+func ecdsaRawSignature(der []byte, length int) ([]byte, error) {
+	ecdsaSig := new(ecdsaSignature)
+	if rest, err := asn1.Unmarshal(der, ecdsaSig); nil != err {
+		return nil, err
+	} else if len(rest) > 0 {
+		return nil, errors.New("trailing data after the ECDSA signature")
+	}
+	if ecdsaSig.R.Sign() <= 0 || ecdsaSig.S.Sign() <= 0 || ecdsaSig.R.BitLen() > length*4 || ecdsaSig.S.BitLen() > length*4 {
+		return nil, errors.New("ECDSA signature values out of range")
+	}
+
+	raw := make([]byte, length)
+	ecdsaSig.R.FillBytes(raw[:length/2])
+	ecdsaSig.S.FillBytes(raw[length/2:])
+
+	return raw, nil
+}
+
+// DefaultSubject returns the subject of certificates generated without any
+// custom subject fields, the CommonName is set per certificate. Locality,
+// street and country are left out rather than filled with placeholders.
+func DefaultSubject() pkix.Name {
+	return pkix.Name{
+		Organization:       []string{"I2P Anonymous Network"},
+		OrganizationalUnit: []string{"I2P"},
+	}
+}
+
+func NewSigningCertificate(signerId string, subject pkix.Name, privateKey *rsa.PrivateKey) ([]byte, error) {
 	serialNumberLimit := new(big.Int).Lsh(big.NewInt(1), 128)
 	serialNumber, err := rand.Int(rand.Reader, serialNumberLimit)
 	if err != nil {
 		return nil, err
 	}
 
-	template := &x509.Certificate{
+	return CreateSigningCertificate(SigningCertificateTemplate(signerId, subject, serialNumber, time.Now()), privateKey)
+}
+
+// SigningCertificateTemplate returns the template of a self-signed su3
+// signing certificate valid for 10 years from notBefore. The CommonName of
+// subject is replaced by signerId.
+func SigningCertificateTemplate(signerId string, subject pkix.Name, serialNumber *big.Int, notBefore time.Time) *x509.Certificate {
+	subject.CommonName = signerId
+
+	return &x509.Certificate{
 		BasicConstraintsValid: true,
 		IsCA:         true,
 		SubjectKeyId: []byte(signerId),
 		SerialNumber: serialNumber,
-		Subject: pkix.Name{
-			Organization:       []string{"I2P Anonymous Network"},
-			OrganizationalUnit: []string{"I2P"},
-			Locality:           []string{"XX"},
-			StreetAddress:      []string{"XX"},
-			Country:            []string{"XX"},
-			CommonName:         signerId,
-		},
-		NotBefore:   time.Now(),
-		NotAfter:    time.Now().AddDate(10, 0, 0),
+		Subject:      subject,
+		NotBefore:   notBefore,
+		NotAfter:    notBefore.AddDate(10, 0, 0),
 		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth, x509.ExtKeyUsageServerAuth},
-		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
 	}
+}
 
+func CreateSigningCertificate(template *x509.Certificate, privateKey *rsa.PrivateKey) ([]byte, error) {
 	publicKey := &privateKey.PublicKey
 
 	// create a self-signed certificate. template = parent
@@ -0,0 +1,139 @@
+package su3
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+)
+
+const (
+	DETACHED_VERSION = uint8(0)
+	// magic, version, signature type and signer id length
+	DETACHED_HEADER_LENGTH = 10
+)
+
+var (
+	DETACHED_MAGIC_BYTES = []byte("I2Psig")
+)
+
+// DetachedSignature signs the SHA-256 hash of a file that is distributed on
+// its own, with the same keys and signature types as su3 files:
+//
+//	magic "I2Psig" | version | signature type (2) | signer id length |
+//	signer id | SHA-256 of the content | signature
+//
+// The signature covers everything before it and runs to the end of the file.
+type DetachedSignature struct {
+	SignatureType uint16
+	SignerId      []byte
+	ContentHash   []byte
+	Signature     []byte
+}
+
+// SignDetached signs content with signer, choosing the signature type from
+// its public key
+func SignDetached(signer crypto.Signer, signerId string, content []byte) (*DetachedSignature, error) {
+	if err := CheckSignerId(signerId); nil != err {
+		return nil, err
+	}
+
+	sigType, err := keySignatureType(signer.Public())
+	if nil != err {
+		return nil, err
+	}
+
+	contentHash := sha256.Sum256(content)
+	d := &DetachedSignature{
+		SignatureType: sigType,
+		SignerId:      []byte(signerId),
+		ContentHash:   contentHash[:],
+	}
+
+	d.Signature, err = sign(signer, sigType, d.signedBytes())
+	if nil != err {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// ParseDetached reads a detached signature file
+func ParseDetached(data []byte) (*DetachedSignature, error) {
+	if !bytes.HasPrefix(data, DETACHED_MAGIC_BYTES) {
+		return nil, fmt.Errorf("Not a detached signature file")
+	}
+	if len(data) < DETACHED_HEADER_LENGTH {
+		return nil, fmt.Errorf("Truncated detached signature file")
+	}
+	if version := data[6]; version != DETACHED_VERSION {
+		return nil, fmt.Errorf("Unsupported detached signature version %d", version)
+	}
+
+	signerIdLength := int(data[9])
+	signatureStart := DETACHED_HEADER_LENGTH + signerIdLength + sha256.Size
+	if len(data) <= signatureStart {
+		return nil, fmt.Errorf("Truncated detached signature file")
+	}
+
+	return &DetachedSignature{
+		SignatureType: uint16(data[7])<<8 | uint16(data[8]),
+		SignerId:      data[DETACHED_HEADER_LENGTH : DETACHED_HEADER_LENGTH+signerIdLength],
+		ContentHash:   data[DETACHED_HEADER_LENGTH+signerIdLength : signatureStart],
+		Signature:     data[signatureStart:],
+	}, nil
+}
+
+func (d *DetachedSignature) MarshalBinary() ([]byte, error) {
+	return append(d.signedBytes(), d.Signature...), nil
+}
+
+// Verify checks that content is the signed file and that the signature is
+// valid for pub
+func (d *DetachedSignature) Verify(content []byte, pub crypto.PublicKey) error {
+	if contentHash := sha256.Sum256(content); !bytes.Equal(contentHash[:], d.ContentHash) {
+		return fmt.Errorf("The content doesn't match the signed hash")
+	}
+
+	sigAlg, err := signatureAlgorithm(d.SignatureType)
+	if nil != err {
+		return err
+	}
+
+	return checkSignature(pub, sigAlg, d.signedBytes(), d.Signature)
+}
+
+func (d *DetachedSignature) signedBytes() []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(DETACHED_MAGIC_BYTES)
+	buf.WriteByte(DETACHED_VERSION)
+	buf.WriteByte(byte(d.SignatureType >> 8))
+	buf.WriteByte(byte(d.SignatureType))
+	buf.WriteByte(byte(len(d.SignerId)))
+	buf.Write(d.SignerId)
+	buf.Write(d.ContentHash)
+
+	return buf.Bytes()
+}
+
+// keySignatureType returns the signature type used for a key, RSA keys by
+// their size like su3 files
+func keySignatureType(pub crypto.PublicKey) (uint16, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return RSASignatureType(pub)
+	case *ecdsa.PublicKey:
+		switch pub.Curve.Params().BitSize {
+		case 256:
+			return SIGTYPE_ECDSA_SHA256, nil
+		case 384:
+			return SIGTYPE_ECDSA_SHA384, nil
+		case 521:
+			return SIGTYPE_ECDSA_SHA512, nil
+		}
+	}
+
+	return 0, fmt.Errorf("No su3 signature type for a %T key", pub)
+}
@@ -0,0 +1,61 @@
+package su3
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+)
+
+func TestSignDetachedRSA2048(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	content := []byte("blocklist")
+
+	d, err := SignDetached(priv, "reseed@mail.i2p", content)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if d.SignatureType != SIGTYPE_RSA_SHA256 {
+		t.Errorf("signature type %d, %d expected", d.SignatureType, SIGTYPE_RSA_SHA256)
+	}
+	if len(d.Signature) != 256 {
+		t.Errorf("%d byte signature", len(d.Signature))
+	}
+
+	data, err := d.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+	parsed, err := ParseDetached(data)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if err := parsed.Verify(content, &priv.PublicKey); nil != err {
+		t.Error(err)
+	}
+	if err := parsed.Verify([]byte("blocklisT"), &priv.PublicKey); nil == err {
+		t.Error("verified with other content")
+	}
+}
+
+func TestSignDetachedECDSA(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	d, err := SignDetached(priv, "reseed@mail.i2p", []byte("blocklist"))
+	if nil != err {
+		t.Fatal(err)
+	}
+	if d.SignatureType != SIGTYPE_ECDSA_SHA384 {
+		t.Errorf("signature type %d, %d expected", d.SignatureType, SIGTYPE_ECDSA_SHA384)
+	}
+	if err := d.Verify([]byte("blocklist"), &priv.PublicKey); nil != err {
+		t.Error(err)
+	}
+}
@@ -5,9 +5,11 @@ import (
 	"crypto"
 	crypto_rand "crypto/rand"
 	//math_rand "math/rand"
+	"crypto/ecdsa"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
@@ -15,6 +17,12 @@ import (
 
 const (
 	MIN_VERSION_LENGTH = 16
+	// the header stores the version and signer id lengths in one byte
+	MAX_VERSION_LENGTH   = 255
+	MAX_SIGNER_ID_LENGTH = 255
+
+	// the only su3 format version routers know
+	FORMAT_VERSION_0 = uint8(0)
 
 	SIGTYPE_DSA          = uint16(0)
 	SIGTYPE_ECDSA_SHA256 = uint16(1)
@@ -38,6 +46,8 @@ const (
 
 var (
 	MAGIC_BYTES = []byte("I2Psu3")
+
+	ErrInvalidSignerId = errors.New("The signer id must be 1 to 255 bytes long")
 )
 
 type Su3File struct {
@@ -51,6 +61,10 @@ type Su3File struct {
 	Content     []byte
 	Signature   []byte
 	SignedBytes []byte
+
+	// the unused header bytes at offsets 6, 12, 14, 24, 26 and 28-39, kept
+	// as read so a parsed file is written back unchanged. Zero in new files.
+	Unused [17]byte
 }
 
 func NewSu3File() *Su3File {
@@ -66,26 +80,27 @@ func NewSu3File() *Su3File {
 	}
 }
 
+// Sign signs the file with an RSA key. The signature type follows the key
+// size: SHA-256 for 2048 bits, SHA-384 for 3072 and SHA-512 for 4096.
 func (s *Su3File) Sign(privkey *rsa.PrivateKey) error {
-	var hashType crypto.Hash
-	switch s.SignatureType {
-	case SIGTYPE_DSA:
-		hashType = crypto.SHA1
-	case SIGTYPE_ECDSA_SHA256, SIGTYPE_RSA_SHA256:
-		hashType = crypto.SHA256
-	case SIGTYPE_ECDSA_SHA384, SIGTYPE_RSA_SHA384:
-		hashType = crypto.SHA384
-	case SIGTYPE_ECDSA_SHA512, SIGTYPE_RSA_SHA512:
-		hashType = crypto.SHA512
-	default:
-		return fmt.Errorf("Unknown signature type.")
+	sigType, err := RSASignatureType(&privkey.PublicKey)
+	if nil != err {
+		return err
 	}
+	s.SignatureType = sigType
 
-	h := hashType.New()
-	h.Write(s.BodyBytes())
-	digest := h.Sum(nil)
+	return s.SignWith(privkey)
+}
 
-	sig, err := rsa.SignPKCS1v15(crypto_rand.Reader, privkey, 0, digest)
+// SignWith signs the file with any RSA or ECDSA signer matching its
+// signature type, ex. a key kept in an HSM. ECDSA signatures are written
+// raw, as routers read them.
+func (s *Su3File) SignWith(signer crypto.Signer) error {
+	if err := s.checkHeader(); nil != err {
+		return err
+	}
+
+	sig, err := sign(signer, s.SignatureType, s.BodyBytes())
 	if nil != err {
 		return err
 	}
@@ -95,13 +110,57 @@ func (s *Su3File) Sign(privkey *rsa.PrivateKey) error {
 	return nil
 }
 
+// sign signs data with the algorithm of sigType. RSA signatures are over the
+// bare digest without the DigestInfo prefix, like routers expect.
+func sign(signer crypto.Signer, sigType uint16, data []byte) ([]byte, error) {
+	hashType, err := signatureHash(sigType)
+	if nil != err {
+		return nil, err
+	}
+
+	var opts crypto.SignerOpts
+	switch pub := signer.Public().(type) {
+	case *rsa.PublicKey:
+		if sigType != SIGTYPE_RSA_SHA256 && sigType != SIGTYPE_RSA_SHA384 && sigType != SIGTYPE_RSA_SHA512 {
+			return nil, fmt.Errorf("Signature type %d can't be used with an RSA key", sigType)
+		}
+		// the header has room for a signature of exactly the key size
+		if pub.Size() != rsaSignatureLengths[sigType] {
+			return nil, fmt.Errorf("Signature type %d needs a %d bit RSA key", sigType, rsaSignatureLengths[sigType]*8)
+		}
+		opts = crypto.Hash(0)
+	case *ecdsa.PublicKey:
+		if sigType != SIGTYPE_ECDSA_SHA256 && sigType != SIGTYPE_ECDSA_SHA384 && sigType != SIGTYPE_ECDSA_SHA512 {
+			return nil, fmt.Errorf("Signature type %d can't be used with an ECDSA key", sigType)
+		}
+		// each signature type has its curve, P-256 for SHA-256 and so on
+		if 2*((pub.Curve.Params().BitSize+7)/8) != ecdsaSignatureLengths[sigType] {
+			return nil, fmt.Errorf("Signature type %d can't be used with a %s key", sigType, pub.Curve.Params().Name)
+		}
+		opts = hashType
+	default:
+		return nil, fmt.Errorf("Unsupported signing key %T", signer.Public())
+	}
+
+	h := hashType.New()
+	h.Write(data)
+
+	sig, err := signer.Sign(crypto_rand.Reader, h.Sum(nil), opts)
+	if nil != err {
+		return nil, err
+	}
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		// crypto.Signer returns ASN.1, the header has room for raw r and s
+		return ecdsaRawSignature(sig, ecdsaSignatureLengths[sigType])
+	}
+
+	return sig, nil
+}
+
 func (s *Su3File) BodyBytes() []byte {
 	var (
 		buf = new(bytes.Buffer)
 
-		skip    [1]byte
-		bigSkip [12]byte
-
 		versionLength   = uint8(len(s.Version))
 		signatureLength = uint16(512)
 		signerIdLength  = uint8(len(s.SignerId))
@@ -112,11 +171,13 @@ func (s *Su3File) BodyBytes() []byte {
 	switch s.SignatureType {
 	case SIGTYPE_DSA:
 		signatureLength = uint16(40)
-	case SIGTYPE_ECDSA_SHA256, SIGTYPE_RSA_SHA256:
+	case SIGTYPE_ECDSA_SHA256, SIGTYPE_ECDSA_SHA384, SIGTYPE_ECDSA_SHA512:
+		signatureLength = uint16(ecdsaSignatureLengths[s.SignatureType])
+	case SIGTYPE_RSA_SHA256:
 		signatureLength = uint16(256)
-	case SIGTYPE_ECDSA_SHA384, SIGTYPE_RSA_SHA384:
+	case SIGTYPE_RSA_SHA384:
 		signatureLength = uint16(384)
-	case SIGTYPE_ECDSA_SHA512, SIGTYPE_RSA_SHA512:
+	case SIGTYPE_RSA_SHA512:
 		signatureLength = uint16(512)
 	}
 
@@ -129,20 +190,20 @@ func (s *Su3File) BodyBytes() []byte {
 	}
 
 	binary.Write(buf, binary.BigEndian, MAGIC_BYTES)
-	binary.Write(buf, binary.BigEndian, skip)
+	binary.Write(buf, binary.BigEndian, s.Unused[0])
 	binary.Write(buf, binary.BigEndian, s.Format)
 	binary.Write(buf, binary.BigEndian, s.SignatureType)
 	binary.Write(buf, binary.BigEndian, signatureLength)
-	binary.Write(buf, binary.BigEndian, skip)
+	binary.Write(buf, binary.BigEndian, s.Unused[1])
 	binary.Write(buf, binary.BigEndian, versionLength)
-	binary.Write(buf, binary.BigEndian, skip)
+	binary.Write(buf, binary.BigEndian, s.Unused[2])
 	binary.Write(buf, binary.BigEndian, signerIdLength)
 	binary.Write(buf, binary.BigEndian, contentLength)
-	binary.Write(buf, binary.BigEndian, skip)
+	binary.Write(buf, binary.BigEndian, s.Unused[3])
 	binary.Write(buf, binary.BigEndian, s.FileType)
-	binary.Write(buf, binary.BigEndian, skip)
+	binary.Write(buf, binary.BigEndian, s.Unused[4])
 	binary.Write(buf, binary.BigEndian, s.ContentType)
-	binary.Write(buf, binary.BigEndian, bigSkip)
+	binary.Write(buf, binary.BigEndian, s.Unused[5:])
 	binary.Write(buf, binary.BigEndian, s.Version)
 	binary.Write(buf, binary.BigEndian, s.SignerId)
 	binary.Write(buf, binary.BigEndian, s.Content)
@@ -150,7 +211,34 @@ func (s *Su3File) BodyBytes() []byte {
 	return buf.Bytes()
 }
 
+// CheckSignerId returns ErrInvalidSignerId if signerId doesn't fit the su3
+// header
+func CheckSignerId(signerId string) error {
+	if signerId == "" || len(signerId) > MAX_SIGNER_ID_LENGTH {
+		return fmt.Errorf("%w, '%s' has %d bytes", ErrInvalidSignerId, signerId, len(signerId))
+	}
+
+	return nil
+}
+
+// checkHeader fails for fields that would overflow their length in the
+// header instead of writing a malformed file
+func (s *Su3File) checkHeader() error {
+	if err := CheckSignerId(string(s.SignerId)); nil != err {
+		return err
+	}
+	if len(s.Version) > MAX_VERSION_LENGTH {
+		return fmt.Errorf("The version must be at most %d bytes long", MAX_VERSION_LENGTH)
+	}
+
+	return nil
+}
+
 func (s *Su3File) MarshalBinary() ([]byte, error) {
+	if err := s.checkHeader(); nil != err {
+		return nil, err
+	}
+
 	buf := bytes.NewBuffer(s.BodyBytes())
 
 	// append the signature
@@ -163,9 +251,7 @@ func (s *Su3File) UnmarshalBinary(data []byte) error {
 	var (
 		r = bytes.NewReader(data)
 
-		magic   = MAGIC_BYTES
-		skip    [1]byte
-		bigSkip [12]byte
+		magic = MAGIC_BYTES
 
 		signatureLength uint16
 		versionLength   uint8
@@ -174,20 +260,20 @@ func (s *Su3File) UnmarshalBinary(data []byte) error {
 	)
 
 	binary.Read(r, binary.BigEndian, &magic)
-	binary.Read(r, binary.BigEndian, &skip)
+	binary.Read(r, binary.BigEndian, &s.Unused[0])
 	binary.Read(r, binary.BigEndian, &s.Format)
 	binary.Read(r, binary.BigEndian, &s.SignatureType)
 	binary.Read(r, binary.BigEndian, &signatureLength)
-	binary.Read(r, binary.BigEndian, &skip)
+	binary.Read(r, binary.BigEndian, &s.Unused[1])
 	binary.Read(r, binary.BigEndian, &versionLength)
-	binary.Read(r, binary.BigEndian, &skip)
+	binary.Read(r, binary.BigEndian, &s.Unused[2])
 	binary.Read(r, binary.BigEndian, &signerIdLength)
 	binary.Read(r, binary.BigEndian, &contentLength)
-	binary.Read(r, binary.BigEndian, &skip)
+	binary.Read(r, binary.BigEndian, &s.Unused[3])
 	binary.Read(r, binary.BigEndian, &s.FileType)
-	binary.Read(r, binary.BigEndian, &skip)
+	binary.Read(r, binary.BigEndian, &s.Unused[4])
 	binary.Read(r, binary.BigEndian, &s.ContentType)
-	binary.Read(r, binary.BigEndian, &bigSkip)
+	binary.Read(r, binary.BigEndian, s.Unused[5:])
 
 	s.Version = make([]byte, versionLength)
 	s.SignerId = make([]byte, signerIdLength)
@@ -203,27 +289,59 @@ func (s *Su3File) UnmarshalBinary(data []byte) error {
 }
 
 func (s *Su3File) VerifySignature(cert *x509.Certificate) error {
-	var sigAlg x509.SignatureAlgorithm
-	switch s.SignatureType {
+	return s.verifySignature(cert.PublicKey)
+}
+
+func (s *Su3File) verifySignature(pub crypto.PublicKey) error {
+	sigAlg, err := signatureAlgorithm(s.SignatureType)
+	if nil != err {
+		return err
+	}
+
+	signed := s.SignedBytes
+	if nil == signed {
+		signed = s.BodyBytes()
+	}
+
+	return checkSignature(pub, sigAlg, signed, s.Signature)
+}
+
+// signatureHash returns the hash signed by a signature type
+func signatureHash(sigType uint16) (crypto.Hash, error) {
+	switch sigType {
 	case SIGTYPE_DSA:
-		sigAlg = x509.DSAWithSHA1
+		return crypto.SHA1, nil
+	case SIGTYPE_ECDSA_SHA256, SIGTYPE_RSA_SHA256:
+		return crypto.SHA256, nil
+	case SIGTYPE_ECDSA_SHA384, SIGTYPE_RSA_SHA384:
+		return crypto.SHA384, nil
+	case SIGTYPE_ECDSA_SHA512, SIGTYPE_RSA_SHA512:
+		return crypto.SHA512, nil
+	}
+
+	return 0, fmt.Errorf("Unknown signature type.")
+}
+
+// signatureAlgorithm maps an su3 signature type to its x509 algorithm
+func signatureAlgorithm(sigType uint16) (x509.SignatureAlgorithm, error) {
+	switch sigType {
+	case SIGTYPE_DSA:
+		return x509.DSAWithSHA1, nil
 	case SIGTYPE_ECDSA_SHA256:
-		sigAlg = x509.ECDSAWithSHA256
+		return x509.ECDSAWithSHA256, nil
 	case SIGTYPE_ECDSA_SHA384:
-		sigAlg = x509.ECDSAWithSHA384
+		return x509.ECDSAWithSHA384, nil
 	case SIGTYPE_ECDSA_SHA512:
-		sigAlg = x509.ECDSAWithSHA512
+		return x509.ECDSAWithSHA512, nil
 	case SIGTYPE_RSA_SHA256:
-		sigAlg = x509.SHA256WithRSA
+		return x509.SHA256WithRSA, nil
 	case SIGTYPE_RSA_SHA384:
-		sigAlg = x509.SHA384WithRSA
+		return x509.SHA384WithRSA, nil
 	case SIGTYPE_RSA_SHA512:
-		sigAlg = x509.SHA512WithRSA
-	default:
-		return fmt.Errorf("Unknown signature type.")
+		return x509.SHA512WithRSA, nil
 	}
 
-	return checkSignature(cert, sigAlg, s.BodyBytes(), s.Signature)
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("Unknown signature type.")
 }
 
 func (s *Su3File) String() string {
@@ -0,0 +1,206 @@
+package su3
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// the unused header bytes, in the order of Su3File.Unused
+var unusedOffsets = []int{6, 12, 14, 24, 26, 28, 29, 30, 31, 32, 33, 34, 35, 36, 37, 38, 39}
+
+func TestMarshalBinaryRoundTrip(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	su3File := NewSu3File()
+	su3File.FileType = FILE_TYPE_ZIP
+	su3File.ContentType = CONTENT_TYPE_RESEED
+	su3File.SignerId = []byte("reseed@mail.i2p")
+	su3File.Content = []byte("content")
+	if err := su3File.Sign(priv); nil != err {
+		t.Fatal(err)
+	}
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+	for _, off := range unusedOffsets {
+		if 0 != data[off] {
+			t.Errorf("new file has %#x at unused offset %d", data[off], off)
+		}
+	}
+
+	// not signed over, the round trip must not change them either
+	for i, off := range unusedOffsets {
+		data[off] = byte(0xa0 + i)
+	}
+
+	parsed, err := Parse(data)
+	if nil != err {
+		t.Fatal(err)
+	}
+	for i, off := range unusedOffsets {
+		if parsed.Unused[i] != data[off] {
+			t.Errorf("unused byte %d is %#x, %#x expected", i, parsed.Unused[i], data[off])
+		}
+	}
+	marshaled, err := parsed.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(marshaled, data) {
+		t.Error("the marshaled file differs from the parsed one")
+	}
+}
+
+func TestMarshalBinaryUnusedSigned(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	su3File := NewSu3File()
+	su3File.FileType = FILE_TYPE_ZIP
+	su3File.ContentType = CONTENT_TYPE_RESEED
+	su3File.SignerId = []byte("reseed@mail.i2p")
+	su3File.Content = []byte("content")
+	for i := range su3File.Unused {
+		su3File.Unused[i] = byte(i + 1)
+	}
+	if err := su3File.Sign(priv); nil != err {
+		t.Fatal(err)
+	}
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+
+	verified, err := Verify(bytes.NewReader(data), &priv.PublicKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	marshaled, err := verified.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(marshaled, data) {
+		t.Error("the marshaled file differs from the verified one")
+	}
+	if _, err := Verify(bytes.NewReader(marshaled), &priv.PublicKey); nil != err {
+		t.Error(err)
+	}
+}
+
+func TestLongSignerId(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if nil != err {
+		t.Fatal(err)
+	}
+	longest := strings.Repeat("r", MAX_SIGNER_ID_LENGTH-len("@mail.i2p")) + "@mail.i2p"
+
+	su3File := NewSu3File()
+	su3File.SignerId = []byte(longest)
+	su3File.Content = []byte("content")
+	if err := su3File.Sign(priv); nil != err {
+		t.Fatal(err)
+	}
+	data, err := su3File.MarshalBinary()
+	if nil != err {
+		t.Fatal(err)
+	}
+	verified, err := Verify(bytes.NewReader(data), &priv.PublicKey)
+	if nil != err {
+		t.Fatal(err)
+	}
+	if string(verified.SignerId) != longest {
+		t.Errorf("signer id of %d bytes read back as %d bytes", len(longest), len(verified.SignerId))
+	}
+
+	// one byte more overflows the length field
+	tooLong := "r" + longest
+	su3File.SignerId = []byte(tooLong)
+	if err := su3File.Sign(priv); !errors.Is(err, ErrInvalidSignerId) {
+		t.Errorf("Sign: %v", err)
+	}
+	if _, err := su3File.MarshalBinary(); !errors.Is(err, ErrInvalidSignerId) {
+		t.Errorf("MarshalBinary: %v", err)
+	}
+	if _, err := SignDetached(priv, tooLong, []byte("content")); !errors.Is(err, ErrInvalidSignerId) {
+		t.Errorf("SignDetached: %v", err)
+	}
+	if _, err := BuildReseed(priv, SIGTYPE_RSA_SHA256, nil, ReseedOptions{SignerId: tooLong}); !errors.Is(err, ErrInvalidSignerId) {
+		t.Errorf("BuildReseed: %v", err)
+	}
+	if err := CheckSignerId(""); !errors.Is(err, ErrInvalidSignerId) {
+		t.Errorf("CheckSignerId of an empty signer id: %v", err)
+	}
+}
+
+func TestSignWithECDSA(t *testing.T) {
+	tests := []struct {
+		curve   elliptic.Curve
+		sigType uint16
+		length  int
+	}{
+		{elliptic.P256(), SIGTYPE_ECDSA_SHA256, 64},
+		{elliptic.P384(), SIGTYPE_ECDSA_SHA384, 96},
+		{elliptic.P521(), SIGTYPE_ECDSA_SHA512, 132},
+	}
+
+	for _, test := range tests {
+		name := test.curve.Params().Name
+		priv, err := ecdsa.GenerateKey(test.curve, rand.Reader)
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		su3File := NewSu3File()
+		su3File.SignatureType = test.sigType
+		su3File.SignerId = []byte("reseed@mail.i2p")
+		su3File.Content = []byte("content")
+		if err := su3File.SignWith(priv); nil != err {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if len(su3File.Signature) != test.length {
+			t.Errorf("%s: %d byte signature, %d expected", name, len(su3File.Signature), test.length)
+		}
+		data, err := su3File.MarshalBinary()
+		if nil != err {
+			t.Fatal(err)
+		}
+
+		verified, err := Verify(bytes.NewReader(data), &priv.PublicKey)
+		if nil != err {
+			t.Errorf("%s: %s", name, err)
+			continue
+		}
+		if !bytes.Equal(verified.Content, su3File.Content) {
+			t.Errorf("%s: content changed", name)
+		}
+
+		data[len(data)-1] ^= 0x01
+		if _, err := Verify(bytes.NewReader(data), &priv.PublicKey); nil == err {
+			t.Errorf("%s: verified with a changed signature", name)
+		}
+	}
+
+	// the curve must match the signature type
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if nil != err {
+		t.Fatal(err)
+	}
+	su3File := NewSu3File()
+	su3File.SignatureType = SIGTYPE_ECDSA_SHA256
+	su3File.SignerId = []byte("reseed@mail.i2p")
+	if err := su3File.SignWith(priv); nil == err {
+		t.Error("signed ECDSA-SHA256 with a P-384 key")
+	}
+}
@@ -0,0 +1,84 @@
+package su3
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+const (
+	// magic, lengths and types before the version
+	HEADER_LENGTH = 40
+)
+
+// VerificationError is returned by Verify if the su3 file was read but its
+// signature doesn't verify
+type VerificationError struct {
+	SignatureType uint16
+	SignerId      string
+	Err           error
+}
+
+func (e *VerificationError) Error() string {
+	return fmt.Sprintf("su3 signature (type %d) of signer '%s' is invalid: %s", e.SignatureType, e.SignerId, e.Err)
+}
+
+func (e *VerificationError) Unwrap() error {
+	return e.Err
+}
+
+// Verify reads an su3 file from r and verifies its signature with pub, using
+// the algorithm of its signature type. The file is only returned if the
+// signature is valid.
+func Verify(r io.Reader, pub crypto.PublicKey) (*Su3File, error) {
+	data, err := ioutil.ReadAll(r)
+	if nil != err {
+		return nil, err
+	}
+
+	su3File, err := Parse(data)
+	if nil != err {
+		return nil, err
+	}
+
+	if err := su3File.verifySignature(pub); nil != err {
+		return nil, &VerificationError{SignatureType: su3File.SignatureType, SignerId: string(su3File.SignerId), Err: err}
+	}
+
+	return su3File, nil
+}
+
+// Parse reads an su3 file, unlike UnmarshalBinary it fails on a wrong magic,
+// an unknown format version or a truncated file
+func Parse(data []byte) (*Su3File, error) {
+	if !bytes.HasPrefix(data, MAGIC_BYTES) {
+		return nil, fmt.Errorf("Not an su3 file")
+	}
+
+	// check the lengths in the header before allocating anything
+	if len(data) < HEADER_LENGTH {
+		return nil, fmt.Errorf("Truncated su3 file")
+	}
+	if format := data[7]; format != FORMAT_VERSION_0 {
+		return nil, fmt.Errorf("Unsupported su3 format version %d", format)
+	}
+	signatureLength := uint64(binary.BigEndian.Uint16(data[10:12]))
+	versionLength := uint64(data[13])
+	signerIdLength := uint64(data[15])
+	contentLength := binary.BigEndian.Uint64(data[16:24])
+	if contentLength > uint64(len(data)) || HEADER_LENGTH+versionLength+signerIdLength+contentLength+signatureLength != uint64(len(data)) {
+		return nil, fmt.Errorf("Truncated or malformed su3 file")
+	}
+
+	su3File := &Su3File{}
+	if err := su3File.UnmarshalBinary(data); nil != err {
+		return nil, err
+	}
+	// verify what was actually signed, not a re-encoding of it
+	su3File.SignedBytes = data[:uint64(len(data))-signatureLength]
+
+	return su3File, nil
+}